@@ -0,0 +1,341 @@
+package adb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// ActivityManager starts and stops apps on the device through am, the activity manager's
+// command line interface. Get one with Device.ActivityManager.
+type ActivityManager struct {
+	device *Device
+}
+
+// ActivityManager returns the device's activity manager.
+func (c *Device) ActivityManager() *ActivityManager {
+	return &ActivityManager{device: c}
+}
+
+// StartOptions configures ActivityManager.StartActivity.
+type StartOptions struct {
+	// Wait waits until the activity is launched, and reports how long it took.
+	Wait bool
+	// ForceStop force-stops the app before starting the activity, so it starts cold.
+	ForceStop bool
+	// Debug waits for a debugger to attach before running the app's code.
+	Debug bool
+}
+
+// StartResult is the outcome of ActivityManager.StartActivity. Only Warning is set unless
+// StartOptions.Wait is set.
+type StartResult struct {
+	// Status is "ok", or "timeout" if the launch didn't complete in time.
+	Status string
+	// LaunchState is "COLD", "WARM", "HOT" or "RELAUNCH". Devices older than Android 10 don't
+	// report it.
+	LaunchState string
+	// Activity is the activity that was launched, e.g. "com.example.app/.MainActivity".
+	Activity string
+
+	// ThisTime is the launch time of the last activity launched, if several were.
+	ThisTime time.Duration
+	// TotalTime is the time from the start of the launch until the activity was drawn.
+	TotalTime time.Duration
+	// WaitTime is TotalTime plus the time the system took to process the launch.
+	WaitTime time.Duration
+
+	// Warning is set if the activity wasn't started because it was already running, e.g.
+	// "Activity not started, its current task has been brought to the front".
+	Warning string
+}
+
+/*
+StartActivity starts the activity described by intent.
+
+Corresponds to the command:
+
+	adb shell am start [-W] [-S] [-D] <intent>
+*/
+func (am *ActivityManager) StartActivity(intent *Intent, opts StartOptions) (*StartResult, error) {
+	args := []string{"start"}
+	if opts.Wait {
+		args = append(args, "-W")
+	}
+	if opts.ForceStop {
+		args = append(args, "-S")
+	}
+	if opts.Debug {
+		args = append(args, "-D")
+	}
+	output, err := am.run(append(args, intent.args()...)...)
+	if err != nil {
+		return nil, wrapClientError(err, am.device, "StartActivity")
+	}
+	result, err := parseStartResult(output)
+	return result, wrapClientError(err, am.device, "StartActivity")
+}
+
+/*
+StartService starts the service described by intent. Android 8.0 and later only let apps in
+the background start services with foreground set, and the service must then call
+startForeground.
+
+Corresponds to the command:
+
+	adb shell am start-service <intent>
+	adb shell am start-foreground-service <intent>
+*/
+func (am *ActivityManager) StartService(intent *Intent, foreground bool) error {
+	command := "start-service"
+	if foreground {
+		command = "start-foreground-service"
+	}
+	_, err := am.run(append([]string{command}, intent.args()...)...)
+	return wrapClientError(err, am.device, "StartService")
+}
+
+/*
+StopService stops the service described by intent, and returns false if it wasn't running.
+
+Corresponds to the command:
+
+	adb shell am stop-service <intent>
+*/
+func (am *ActivityManager) StopService(intent *Intent) (bool, error) {
+	output, err := am.run(append([]string{"stop-service"}, intent.args()...)...)
+	if err != nil {
+		return false, wrapClientError(err, am.device, "StopService")
+	}
+	return !strings.Contains(output, "Service not stopped"), nil
+}
+
+// BroadcastResult is the result of an ordered broadcast, as set by its receivers.
+type BroadcastResult struct {
+	// Code is the result code. It's 0 if no receiver set it.
+	Code int
+	// Data is the result data, or "" if no receiver set it.
+	Data string
+	// Extras is the result extras bundle as printed by am, e.g. "Bundle[{key=value}]", or ""
+	// if no receiver set it.
+	Extras string
+}
+
+/*
+Broadcast sends intent to the broadcast receivers that match it, and waits for them to
+finish.
+
+Corresponds to the command:
+
+	adb shell am broadcast <intent>
+*/
+func (am *ActivityManager) Broadcast(intent *Intent) (*BroadcastResult, error) {
+	output, err := am.run(append([]string{"broadcast"}, intent.args()...)...)
+	if err != nil {
+		return nil, wrapClientError(err, am.device, "Broadcast")
+	}
+	result, err := parseBroadcastResult(output)
+	return result, wrapClientError(err, am.device, "Broadcast")
+}
+
+/*
+ForceStop stops everything associated with pkg: its processes, services and alarms.
+
+Corresponds to the command:
+
+	adb shell am force-stop <package>
+*/
+func (am *ActivityManager) ForceStop(pkg string) error {
+	_, err := am.run("force-stop", pkg)
+	return wrapClientError(err, am.device, "ForceStop(%s)", pkg)
+}
+
+/*
+Kill kills the processes of pkg that are safe to kill, i.e. that are in the background and
+can be restarted without the user noticing.
+
+Corresponds to the command:
+
+	adb shell am kill <package>
+*/
+func (am *ActivityManager) Kill(pkg string) error {
+	_, err := am.run("kill", pkg)
+	return wrapClientError(err, am.device, "Kill(%s)", pkg)
+}
+
+// WindowFocus is the window that has input focus.
+type WindowFocus struct {
+	// Window is the window's name, e.g. "com.example.app/com.example.app.MainActivity" for an
+	// activity, or "NotificationShade" for a system window.
+	Window string
+	// Package and Activity are set if the window is an activity's.
+	Package  string
+	Activity string
+}
+
+/*
+GetCurrentFocus returns the window that has input focus, or nil if none has, e.g. while the
+screen is off.
+
+Corresponds to the command:
+
+	adb shell dumpsys window windows
+*/
+func (am *ActivityManager) GetCurrentFocus() (*WindowFocus, error) {
+	cmdline := "dumpsys window windows"
+	result, err := am.device.runShellCommand(cmdline)
+	if err == nil {
+		err = commandError(cmdline, result)
+	}
+	if err != nil {
+		return nil, wrapClientError(err, am.device, "GetCurrentFocus")
+	}
+	focus, err := parseCurrentFocus(string(result.Stdout))
+	return focus, wrapClientError(err, am.device, "GetCurrentFocus")
+}
+
+// run runs am with args, and returns its output. am reports many errors with a zero exit
+// status, so its output is checked for them too.
+func (am *ActivityManager) run(args ...string) (string, error) {
+	cmdline := shellCommandLine("am", args...)
+	result, err := am.device.runShellCommand(cmdline)
+	if err != nil {
+		return "", err
+	}
+	if msg := amErrorMessage(string(result.Stdout) + "\n" + string(result.Stderr)); msg != "" {
+		return "", &errors.Err{
+			Code:    errors.CommandFailed,
+			Message: fmt.Sprintf("am %s failed: %s", args[0], msg),
+			Details: CommandErrorDetails{
+				Command:  cmdline,
+				ExitCode: result.ExitCode,
+				Stderr:   strings.TrimSpace(string(result.Stderr)),
+			},
+		}
+	}
+	if err := commandError(cmdline, result); err != nil {
+		return "", err
+	}
+	return string(result.Stdout), nil
+}
+
+// amErrorMessage returns the last error am printed, e.g. "Activity class
+// {com.example.app/.Missing} does not exist.", or "" if there's none.
+func amErrorMessage(output string) string {
+	var msg string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if m, ok := strings.CutPrefix(line, "Error: "); ok {
+			msg = m
+		} else if m, ok := strings.CutPrefix(line, "Exception occurred while executing"); ok && msg == "" {
+			msg = "exception occurred while executing" + m
+		}
+	}
+	return msg
+}
+
+/*
+parseStartResult parses the output of am start, which with -W looks like:
+
+	Starting: Intent { act=android.intent.action.MAIN cmp=com.example.app/.MainActivity }
+	Status: ok
+	LaunchState: COLD
+	Activity: com.example.app/.MainActivity
+	TotalTime: 512
+	WaitTime: 520
+	Complete
+*/
+func parseStartResult(output string) (*StartResult, error) {
+	result := &StartResult{}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ": ")
+		if !ok {
+			continue
+		}
+		var err error
+		switch key {
+		case "Warning":
+			result.Warning = value
+		case "Status":
+			result.Status = value
+		case "LaunchState":
+			result.LaunchState = value
+		case "Activity":
+			result.Activity = value
+		case "ThisTime":
+			result.ThisTime, err = parseMilliseconds(key, value)
+		case "TotalTime":
+			result.TotalTime, err = parseMilliseconds(key, value)
+		case "WaitTime":
+			result.WaitTime, err = parseMilliseconds(key, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func parseMilliseconds(key, value string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.WrapErrorf(err, errors.ParseError, "invalid %s %q", key, value)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+var broadcastResultRegex = regexp.MustCompile(`^Broadcast completed: result=(-?\d+)(.*)$`)
+
+/*
+parseBroadcastResult parses the output of am broadcast:
+
+	Broadcasting: Intent { act=com.example.PING flg=0x400000 }
+	Broadcast completed: result=1, data="pong", extras: Bundle[{count=2}]
+
+data and extras are only printed if set, and data can contain quotes.
+*/
+func parseBroadcastResult(output string) (*BroadcastResult, error) {
+	for _, line := range strings.Split(output, "\n") {
+		m := broadcastResultRegex.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		result := &BroadcastResult{}
+		result.Code, _ = strconv.Atoi(m[1])
+
+		rest := m[2]
+		if i := strings.LastIndex(rest, ", extras: "); i >= 0 {
+			result.Extras, rest = rest[i+len(", extras: "):], rest[:i]
+		}
+		if data, ok := strings.CutPrefix(rest, `, data="`); ok {
+			result.Data = strings.TrimSuffix(data, `"`)
+		}
+		return result, nil
+	}
+	return nil, errors.Errorf(errors.ParseError, "am broadcast didn't report a result: %q", output)
+}
+
+var currentFocusRegex = regexp.MustCompile(`mCurrentFocus=(?:Window\{\S+ (?:u\d+ )?(.*)\}|null)`)
+
+// parseCurrentFocus finds the mCurrentFocus line in dumpsys window windows, e.g.
+//
+//	mCurrentFocus=Window{4a1b2c3 u0 com.example.app/com.example.app.MainActivity}
+func parseCurrentFocus(output string) (*WindowFocus, error) {
+	m := currentFocusRegex.FindStringSubmatch(output)
+	if m == nil {
+		return nil, errors.Errorf(errors.ParseError, "dumpsys window didn't report mCurrentFocus")
+	}
+	if m[0] == "mCurrentFocus=null" {
+		return nil, nil
+	}
+
+	focus := &WindowFocus{Window: m[1]}
+	if pkg, activity, ok := strings.Cut(m[1], "/"); ok {
+		focus.Package, focus.Activity = pkg, activity
+	}
+	return focus, nil
+}
@@ -0,0 +1,179 @@
+package adb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeAmDevice answers every shell command with stdout, and records the command lines.
+func newFakeAmDevice(stdout string, cmdlines *[]string) *Device {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		*cmdlines = append(*cmdlines, cmdline)
+		return stdout, "", 0
+	})
+	return device
+}
+
+func TestStartActivity(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice(`Starting: Intent { act=android.intent.action.MAIN cmp=com.example.app/.MainActivity }
+Status: ok
+LaunchState: COLD
+Activity: com.example.app/.MainActivity
+TotalTime: 512
+WaitTime: 520
+Complete
+`, &cmdlines)
+
+	intent := NewIntent(ActionMain).
+		SetComponent("com.example.app/.MainActivity").
+		PutString("json", `{"a": [1, 2]}`)
+	result, err := device.ActivityManager().StartActivity(intent, StartOptions{Wait: true, ForceStop: true})
+	require.NoError(t, err)
+	assert.Equal(t, &StartResult{
+		Status:      "ok",
+		LaunchState: "COLD",
+		Activity:    "com.example.app/.MainActivity",
+		TotalTime:   512 * time.Millisecond,
+		WaitTime:    520 * time.Millisecond,
+	}, result)
+	assert.Equal(t, []string{
+		`am start -W -S -a android.intent.action.MAIN -n com.example.app/.MainActivity --es json '{"a": [1, 2]}'`,
+	}, cmdlines)
+}
+
+func TestStartActivityLongExtra(t *testing.T) {
+	config := `{"tags": [` + strings.Repeat(`"tag", `, 150) + `"last"]}`
+	require.Greater(t, len(config), 255)
+	intent := NewIntent("").SetComponent("com.example.app/.MainActivity").PutString("config", config)
+	want := `am start -n com.example.app/.MainActivity --es config '` + config + `'`
+
+	// On devices without shell_v2, the command is wrapped to echo its exit status.
+	for _, v2 := range []bool{true, false} {
+		features, wrapped := []string{"shell_v2"}, want
+		if !v2 {
+			features, wrapped = nil, "("+want+") 2>&1; echo; echo $?"
+		}
+		var cmdlines []string
+		_, device := newFakeShellDevice(features, func(cmdline string) (string, string, int) {
+			cmdlines = append(cmdlines, cmdline)
+			stdout := "Starting: Intent { cmp=com.example.app/.MainActivity }\n"
+			if !v2 {
+				stdout += "\n0\n"
+			}
+			return stdout, "", 0
+		})
+		_, err := device.ActivityManager().StartActivity(intent, StartOptions{})
+		require.NoError(t, err, "shell_v2: %t", v2)
+		assert.Equal(t, []string{wrapped}, cmdlines, "shell_v2: %t", v2)
+	}
+
+	var cmdlines []string
+	device := newFakeAmDevice("", &cmdlines)
+	_, err := device.ActivityManager().StartActivity(NewIntent("").PutString("config", strings.Repeat("x", 5000)), StartOptions{})
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+	assert.Contains(t, ErrorWithCauseChain(err), "exceeds the maximum")
+	assert.Empty(t, cmdlines)
+}
+
+func TestStartActivityWarning(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice(`Starting: Intent { cmp=com.example.app/.MainActivity }
+Warning: Activity not started, its current task has been brought to the front
+`, &cmdlines)
+
+	result, err := device.ActivityManager().StartActivity(NewIntent("").SetComponent("com.example.app/.MainActivity"), StartOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Activity not started, its current task has been brought to the front", result.Warning)
+}
+
+func TestStartActivityError(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice(`Starting: Intent { cmp=com.example.app/.Missing }
+Error type 3
+Error: Activity class {com.example.app/com.example.app.Missing} does not exist.
+`, &cmdlines)
+
+	_, err := device.ActivityManager().StartActivity(NewIntent("").SetComponent("com.example.app/.Missing"), StartOptions{})
+	assert.True(t, HasErrCode(err, CommandFailed), "%v", err)
+	assert.Contains(t, ErrorWithCauseChain(err), "does not exist")
+}
+
+func TestStartAndStopService(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice("Stopping service: Intent { cmp=com.example.app/.Sync }\nService not stopped: Unable to find service to stop.\n", &cmdlines)
+	am := device.ActivityManager()
+	intent := NewIntent("").SetComponent("com.example.app/.Sync")
+
+	require.NoError(t, am.StartService(intent, true))
+	stopped, err := am.StopService(intent)
+	require.NoError(t, err)
+	assert.False(t, stopped)
+	assert.Equal(t, []string{
+		"am start-foreground-service -n com.example.app/.Sync",
+		"am stop-service -n com.example.app/.Sync",
+	}, cmdlines)
+}
+
+func TestBroadcast(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice(`Broadcasting: Intent { act=com.example.PING flg=0x400000 }
+Broadcast completed: result=-1, data="say "pong"", extras: Bundle[{count=2}]
+`, &cmdlines)
+
+	result, err := device.ActivityManager().Broadcast(NewIntent("com.example.PING").AddFlags(FlagReceiverForeground))
+	require.NoError(t, err)
+	assert.Equal(t, &BroadcastResult{Code: -1, Data: `say "pong"`, Extras: "Bundle[{count=2}]"}, result)
+	assert.Equal(t, "am broadcast -a com.example.PING -f 0x10000000", cmdlines[0])
+}
+
+func TestParseBroadcastResult(t *testing.T) {
+	result, err := parseBroadcastResult("Broadcasting: Intent { act=x }\r\nBroadcast completed: result=0\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, &BroadcastResult{}, result)
+
+	_, err = parseBroadcastResult("Broadcasting: Intent { act=x }\n")
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+}
+
+func TestForceStopAndKill(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice("", &cmdlines)
+	am := device.ActivityManager()
+
+	require.NoError(t, am.ForceStop("com.example.app"))
+	require.NoError(t, am.Kill("com.example.app"))
+	assert.Equal(t, []string{"am force-stop com.example.app", "am kill com.example.app"}, cmdlines)
+}
+
+func TestGetCurrentFocus(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice(`WINDOW MANAGER WINDOWS (dumpsys window windows)
+  Window #0 Window{1a2b3c u0 NavigationBar0}:
+  mCurrentFocus=Window{4a1b2c3 u0 com.example.app/com.example.app.MainActivity}
+  mFocusedApp=ActivityRecord{5d6e7f u0 com.example.app/.MainActivity t12}
+`, &cmdlines)
+
+	focus, err := device.ActivityManager().GetCurrentFocus()
+	require.NoError(t, err)
+	assert.Equal(t, &WindowFocus{
+		Window:   "com.example.app/com.example.app.MainActivity",
+		Package:  "com.example.app",
+		Activity: "com.example.app.MainActivity",
+	}, focus)
+
+	focus, err = parseCurrentFocus("  mCurrentFocus=Window{1a2b3c u0 NotificationShade}\n")
+	require.NoError(t, err)
+	assert.Equal(t, &WindowFocus{Window: "NotificationShade"}, focus)
+
+	focus, err = parseCurrentFocus("  mCurrentFocus=null\n")
+	require.NoError(t, err)
+	assert.Nil(t, focus)
+
+	_, err = parseCurrentFocus("")
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+}
@@ -0,0 +1,210 @@
+/*
+Package adbkey loads and generates the RSA key adb uses to authenticate to devices, and
+encodes it in the formats devices expect.
+
+The adb server keeps its key in ~/.android/adbkey, with the public key in adbkey.pub.
+Devices remember the public keys they have authorized, so using the same key as the adb
+server avoids having to authorize this host again.
+*/
+package adbkey
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+const (
+	// KeySize is the size in bits of the keys adb uses. Devices only accept keys of this size.
+	KeySize = 2048
+
+	// FileName is the name of the private key file in the adb user directory. The public key
+	// is stored next to it, with ".pub" appended.
+	FileName = "adbkey"
+
+	modulusWords = KeySize / 32
+)
+
+// DefaultPath returns the path of the adb server's private key: $ANDROID_USER_HOME/adbkey if
+// ANDROID_USER_HOME is set, else ~/.android/adbkey.
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("ANDROID_USER_HOME"); dir != "" {
+		return filepath.Join(dir, FileName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.WrapErrorf(err, errors.LocalFileError, "can't find the home directory")
+	}
+	return filepath.Join(home, ".android", FileName), nil
+}
+
+// Generate generates a new key.
+func Generate() (*rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, KeySize)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.AssertionError, "error generating key")
+	}
+	return key, nil
+}
+
+// Load reads a PEM-encoded private key, in either the PKCS #8 format the adb server writes or
+// PKCS #1.
+func Load(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.LocalFileError, "error reading key %s", path)
+	}
+	key, err := Parse(data)
+	if err != nil {
+		return nil, errors.WrapErrf(err, "error parsing key %s", path)
+	}
+	return key, nil
+}
+
+// Parse parses a PEM-encoded private key. See Load.
+func Parse(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf(errors.ParseError, "no PEM data found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, errors.Errorf(errors.ParseError, "unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid private key")
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf(errors.ParseError, "not an RSA key: %T", key)
+	}
+	if rsaKey.N.BitLen() != KeySize {
+		return nil, errors.Errorf(errors.ParseError, "key is %d bits, adb requires %d", rsaKey.N.BitLen(), KeySize)
+	}
+	return rsaKey, nil
+}
+
+// Save writes key to path in the same format as the adb server, and its public key to
+// path.pub.
+func Save(path string, key *rsa.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return errors.WrapErrorf(err, errors.AssertionError, "error encoding key")
+	}
+	pub, err := EncodePublicKey(&key.PublicKey, DefaultName())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error creating %s", filepath.Dir(path))
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error writing key %s", path)
+	}
+	if err := os.WriteFile(path+".pub", []byte(pub+"\n"), 0o644); err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error writing key %s.pub", path)
+	}
+	return nil
+}
+
+// DefaultName returns the name the adb server appends to its public key, user@host.
+func DefaultName() string {
+	username, hostname := "unknown", "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	if h, err := os.Hostname(); err == nil {
+		hostname = h
+	}
+	return username + "@" + hostname
+}
+
+/*
+EncodePublicKey encodes pub in the format of adbkey.pub: the base64 encoding of the public
+key in the layout of Android's RSAPublicKey struct, then a space and name. All numbers are
+little-endian:
+
+	uint32     modulus size in 32-bit words
+	uint32     -1 / n[0] mod 2^32
+	uint32[64] modulus
+	uint32[64] 2^4096 mod n, the Montgomery constant R^2
+	uint32     public exponent
+*/
+func EncodePublicKey(pub *rsa.PublicKey, name string) (string, error) {
+	if pub.N.BitLen() != KeySize {
+		return "", errors.Errorf(errors.AssertionError, "key is %d bits, adb requires %d", pub.N.BitLen(), KeySize)
+	}
+
+	buf := make([]byte, 0, 4*(3+2*modulusWords))
+	buf = binary.LittleEndian.AppendUint32(buf, modulusWords)
+
+	word := new(big.Int).Lsh(big.NewInt(1), 32)
+	n0inv := new(big.Int).ModInverse(new(big.Int).Mod(pub.N, word), word)
+	n0inv.Sub(word, n0inv)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(n0inv.Uint64()))
+
+	buf = appendLittleEndian(buf, pub.N)
+	rr := new(big.Int).Exp(big.NewInt(2), big.NewInt(2*KeySize), pub.N)
+	buf = appendLittleEndian(buf, rr)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(pub.E))
+
+	return base64.StdEncoding.EncodeToString(buf) + " " + name, nil
+}
+
+// appendLittleEndian appends x to buf as a KeySize-bit little-endian number.
+func appendLittleEndian(buf []byte, x *big.Int) []byte {
+	be := x.FillBytes(make([]byte, KeySize/8))
+	for i := len(be) - 1; i >= 0; i-- {
+		buf = append(buf, be[i])
+	}
+	return buf
+}
+
+/*
+Certificate returns a self-signed certificate for key, like the one the adb server presents
+when pairing and when connecting over TLS. Devices identify the host by the certificate's
+public key, not by anything else in it.
+*/
+func Certificate(key *rsa.PrivateKey) (tls.Certificate, error) {
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:      []string{"US"},
+			Organization: []string{"Android"},
+			CommonName:   "Adb",
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		SignatureAlgorithm:    x509.SHA256WithRSA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, errors.WrapErrorf(err, errors.AssertionError, "error creating certificate")
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
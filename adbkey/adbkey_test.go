@@ -0,0 +1,124 @@
+package adbkey
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+func generateTestKey(t *testing.T) *rsa.PrivateKey {
+	testKeyOnce.Do(func() {
+		var err error
+		testKey, err = Generate()
+		require.NoError(t, err)
+	})
+	return testKey
+}
+
+func TestEncodePublicKey(t *testing.T) {
+	key := generateTestKey(t)
+
+	encoded, err := EncodePublicKey(&key.PublicKey, "user@host")
+	require.NoError(t, err)
+	b64, name, ok := strings.Cut(encoded, " ")
+	require.True(t, ok)
+	assert.Equal(t, "user@host", name)
+
+	data, err := base64.StdEncoding.DecodeString(b64)
+	require.NoError(t, err)
+	require.Len(t, data, 524)
+
+	assert.Equal(t, uint32(64), binary.LittleEndian.Uint32(data))
+	n0inv := binary.LittleEndian.Uint32(data[4:])
+	n := readLittleEndian(data[8 : 8+256])
+	rr := readLittleEndian(data[8+256 : 8+512])
+	assert.Equal(t, key.N, n)
+	assert.Equal(t, uint32(0xffffffff), n0inv*uint32(key.N.Uint64()), "n0inv * n[0] should be -1 mod 2^32")
+	assert.Equal(t, new(big.Int).Exp(big.NewInt(2), big.NewInt(4096), key.N), rr)
+	assert.Equal(t, uint32(key.E), binary.LittleEndian.Uint32(data[520:]))
+}
+
+func TestEncodePublicKeyWrongSize(t *testing.T) {
+	_, err := EncodePublicKey(&rsa.PublicKey{N: big.NewInt(3233), E: 17}, "user@host")
+	assert.True(t, errors.HasErrCode(err, errors.AssertionError))
+}
+
+func TestSaveLoad(t *testing.T) {
+	key := generateTestKey(t)
+	path := filepath.Join(t.TempDir(), ".android", FileName)
+
+	require.NoError(t, Save(path, key))
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(loaded))
+
+	pub, err := os.ReadFile(path + ".pub")
+	require.NoError(t, err)
+	expected, err := EncodePublicKey(&key.PublicKey, DefaultName())
+	require.NoError(t, err)
+	assert.Equal(t, expected+"\n", string(pub))
+}
+
+func TestParsePKCS1(t *testing.T) {
+	key := generateTestKey(t)
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	parsed, err := Parse(data)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(parsed))
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte("not a key"))
+	assert.True(t, errors.HasErrCode(err, errors.ParseError))
+
+	_, err = Parse(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}))
+	assert.True(t, errors.HasErrCode(err, errors.ParseError))
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing"))
+	assert.True(t, errors.HasErrCode(err, errors.LocalFileError))
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("ANDROID_USER_HOME", "/tmp/android")
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/tmp/android", FileName), path)
+}
+
+func TestCertificate(t *testing.T) {
+	key := generateTestKey(t)
+
+	cert, err := Certificate(key)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Adb", parsed.Subject.CommonName)
+	assert.True(t, key.PublicKey.Equal(parsed.PublicKey))
+	assert.NoError(t, parsed.CheckSignatureFrom(parsed))
+}
+
+func readLittleEndian(data []byte) *big.Int {
+	be := make([]byte, len(data))
+	for i := range data {
+		be[len(data)-1-i] = data[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
@@ -0,0 +1,173 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"slices"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/internal/protobuf"
+)
+
+// featureTrackApp is the feature of devices that support the track-app service, Android 12
+// and later.
+const featureTrackApp = "track_app"
+
+// AppProcess is a process reported by Device.TrackApp.
+type AppProcess struct {
+	Pid int
+	// Debuggable is true if a debugger can attach to the process with Device.DialJdwp.
+	Debuggable bool
+	// Profileable is true if profilers such as simpleperf can attach to the process.
+	Profileable bool
+	// Architecture is the ABI the process runs as, e.g. "arm64" or "x86".
+	Architecture string
+}
+
+/*
+TrackApp reports the device's debuggable and profileable processes. The tracker keeps
+reporting changes until ctx is done or it's closed.
+
+Devices older than Android 12 don't support track-app. On those, TrackApp falls back to
+track-jdwp, which only reports debuggable processes: see AppTracker.JdwpOnly.
+
+Corresponds to the command:
+
+	adb track-app
+*/
+func (c *Device) TrackApp(ctx context.Context) (*AppTracker, error) {
+	trackApp, err := c.hasFeature(featureTrackApp)
+	if err != nil {
+		return nil, wrapClientError(err, c, "TrackApp")
+	}
+	if !trackApp {
+		jdwp, err := c.TrackJdwp(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &AppTracker{stream: jdwp.stream, JdwpOnly: true}, nil
+	}
+
+	stream, err := c.openCommandStream(ctx, "track-app", "TrackApp")
+	if err != nil {
+		return nil, err
+	}
+	return &AppTracker{stream: stream}, nil
+}
+
+// AppTracker reports the processes tracked by Device.TrackApp.
+type AppTracker struct {
+	stream *CommandStream
+
+	// JdwpOnly is true if the device doesn't support track-app, and the tracker uses
+	// track-jdwp instead. Only debuggable processes are reported then, with Profileable
+	// false and no Architecture.
+	JdwpOnly bool
+}
+
+// Next waits until the set of processes changes, and returns all of them, ordered by PID.
+// The first call returns the processes running when tracking started. It returns io.EOF if
+// the device disconnects.
+func (t *AppTracker) Next() ([]AppProcess, error) {
+	msg, err := readHexMessage(t.stream)
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errors.WrapErrf(err, "error reading %s", t.service())
+	}
+
+	var processes []AppProcess
+	if t.JdwpOnly {
+		var pids []int
+		pids, err = parseJdwpPids(string(msg))
+		processes = make([]AppProcess, len(pids))
+		for i, pid := range pids {
+			processes[i] = AppProcess{Pid: pid, Debuggable: true}
+		}
+	} else {
+		processes, err = parseAppProcesses(msg)
+	}
+	return processes, wrapClientError(err, t.stream.device, "TrackApp")
+}
+
+func (t *AppTracker) service() string {
+	if t.JdwpOnly {
+		return "track-jdwp"
+	}
+	return "track-app"
+}
+
+func (t *AppTracker) Close() error {
+	return t.stream.Close()
+}
+
+/*
+parseAppProcesses decodes a track-app message, an AppProcesses protobuf:
+
+	message ProcessEntry {
+		int64 pid = 1;
+		bool debuggable = 2;
+		bool profileable = 3;
+		string architecture = 4;
+	}
+
+	message AppProcesses {
+		repeated ProcessEntry process = 1;
+	}
+
+Fields added by later versions are ignored.
+*/
+func parseAppProcesses(msg []byte) ([]AppProcess, error) {
+	processes := []AppProcess{}
+	d := protobuf.NewDecoder(msg)
+	for {
+		field, err := d.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.WrapErrf(err, "error decoding track-app message")
+		}
+		if field.Number != 1 || field.Type != protobuf.WireBytes {
+			continue
+		}
+
+		process, err := parseProcessEntry(field.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		processes = append(processes, process)
+	}
+	slices.SortFunc(processes, func(a, b AppProcess) int {
+		return a.Pid - b.Pid
+	})
+	return processes, nil
+}
+
+func parseProcessEntry(entry []byte) (AppProcess, error) {
+	var process AppProcess
+	d := protobuf.NewDecoder(entry)
+	for {
+		field, err := d.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return AppProcess{}, errors.WrapErrf(err, "error decoding track-app process")
+		}
+
+		switch {
+		case field.Number == 1 && field.Type == protobuf.WireVarint:
+			process.Pid = int(field.Int64())
+		case field.Number == 2 && field.Type == protobuf.WireVarint:
+			process.Debuggable = field.Bool()
+		case field.Number == 3 && field.Type == protobuf.WireVarint:
+			process.Profileable = field.Bool()
+		case field.Number == 4 && field.Type == protobuf.WireBytes:
+			process.Architecture = string(field.Bytes)
+		}
+	}
+	if process.Pid <= 0 {
+		return AppProcess{}, errors.Errorf(errors.ParseError, "track-app process has invalid PID %d", process.Pid)
+	}
+	return process, nil
+}
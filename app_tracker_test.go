@@ -0,0 +1,115 @@
+package adb
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendProtoField appends a protobuf field with a varint (if value is a uint64) or bytes
+// value.
+func appendProtoField(b []byte, number int, value any) []byte {
+	switch v := value.(type) {
+	case uint64:
+		b = binary.AppendUvarint(b, uint64(number)<<3)
+		return binary.AppendUvarint(b, v)
+	case []byte:
+		b = binary.AppendUvarint(b, uint64(number)<<3|2)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		return append(b, v...)
+	}
+	panic("unsupported value")
+}
+
+func encodeProcessEntry(p AppProcess) []byte {
+	var entry []byte
+	entry = appendProtoField(entry, 1, uint64(p.Pid))
+	if p.Debuggable {
+		entry = appendProtoField(entry, 2, uint64(1))
+	}
+	if p.Profileable {
+		entry = appendProtoField(entry, 3, uint64(1))
+	}
+	return appendProtoField(entry, 4, []byte(p.Architecture))
+}
+
+func newFakeTrackAppDevice(features string, handler func(conn net.Conn, req string)) (*fakeDevice, *Device) {
+	d := newFakeDevice()
+	d.services["host-serial:serial:features"] = func(conn net.Conn, req string) {
+		writeFakeMessage(conn, features)
+	}
+	d.services["track-"] = handler
+	return d, newFakeDeviceClient(d)
+}
+
+func TestTrackApp(t *testing.T) {
+	app := AppProcess{Pid: 4321, Debuggable: true, Architecture: "arm64"}
+	profiled := AppProcess{Pid: 1234, Profileable: true, Architecture: "arm"}
+
+	var msg []byte
+	msg = appendProtoField(msg, 1, encodeProcessEntry(app))
+	// Fields of later versions, e.g. waiting_for_debugger, are skipped.
+	msg = appendProtoField(msg, 1, appendProtoField(encodeProcessEntry(profiled), 5, uint64(1)))
+	msg = appendProtoField(msg, 2, []byte("unknown"))
+
+	d, device := newFakeTrackAppDevice("shell_v2,track_app", func(conn net.Conn, req string) {
+		writeFakeMessage(conn, string(msg))
+		writeFakeMessage(conn, "")
+	})
+
+	tracker, err := device.TrackApp(context.Background())
+	require.NoError(t, err)
+	defer tracker.Close()
+	assert.False(t, tracker.JdwpOnly)
+
+	processes, err := tracker.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []AppProcess{profiled, app}, processes)
+
+	processes, err = tracker.Next()
+	require.NoError(t, err)
+	assert.Empty(t, processes)
+
+	_, err = tracker.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "track-app", d.requests[1])
+}
+
+func TestTrackAppFallsBackToJdwp(t *testing.T) {
+	d, device := newFakeTrackAppDevice("shell_v2", func(conn net.Conn, req string) {
+		writeFakeMessage(conn, "4321\n1234\n")
+	})
+
+	tracker, err := device.TrackApp(context.Background())
+	require.NoError(t, err)
+	defer tracker.Close()
+	assert.True(t, tracker.JdwpOnly)
+
+	processes, err := tracker.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []AppProcess{{Pid: 1234, Debuggable: true}, {Pid: 4321, Debuggable: true}}, processes)
+	assert.Equal(t, "track-jdwp", d.requests[1])
+}
+
+func TestTrackAppInvalid(t *testing.T) {
+	_, device := newFakeTrackAppDevice("track_app", func(conn net.Conn, req string) {
+		// A process without a PID.
+		writeFakeMessage(conn, string(appendProtoField(nil, 1, appendProtoField(nil, 4, []byte("x86")))))
+		// A truncated message.
+		writeFakeMessage(conn, "\x0a\x05\x08")
+	})
+
+	tracker, err := device.TrackApp(context.Background())
+	require.NoError(t, err)
+	defer tracker.Close()
+
+	_, err = tracker.Next()
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+	_, err = tracker.Next()
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+}
@@ -0,0 +1,220 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// BugReportEventType identifies the lines bugreportz prints while it generates a report.
+type BugReportEventType int
+
+const (
+	// BugReportBegin is reported once bugreportz starts, with the Path it writes the report to.
+	BugReportBegin BugReportEventType = iota
+	// BugReportProgress reports how much of the report has been generated, in Done and Total.
+	BugReportProgress
+	// BugReportOK is reported once the report is complete, with its Path. The report is
+	// pulled from the device next.
+	BugReportOK
+	// BugReportFail is reported with the error Message if bugreportz fails.
+	BugReportFail
+)
+
+func (t BugReportEventType) String() string {
+	switch t {
+	case BugReportBegin:
+		return "BEGIN"
+	case BugReportProgress:
+		return "PROGRESS"
+	case BugReportOK:
+		return "OK"
+	case BugReportFail:
+		return "FAIL"
+	}
+	return "BugReportEventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// BugReportEvent is a line printed by bugreportz -p.
+type BugReportEvent struct {
+	Type BugReportEventType
+
+	// Path is the path of the zip on the device, for BugReportBegin and BugReportOK.
+	Path string
+
+	// Done and Total are in arbitrary units, for BugReportProgress. Total can grow as the
+	// report is generated.
+	Done  int
+	Total int
+
+	// Message is the error message, for BugReportFail.
+	Message string
+}
+
+// BugReportResult describes a report written by BugReport.
+type BugReportResult struct {
+	// Zipped is true if the report is a zip file, with the text report, the dumpstate logs
+	// and other files such as ANR traces. It's false if the device doesn't have bugreportz,
+	// and the report is only the text of bugreport.
+	Zipped bool
+
+	// Path is the path of the zip on the device. bugreportz leaves it there after it has been
+	// pulled.
+	Path string
+
+	// Size is the number of bytes written.
+	Size int64
+}
+
+/*
+BugReport generates a bug report and writes it to w. Generating a report takes minutes.
+
+Devices with bugreportz, Android 7.0 and later, generate a zip, which is then pulled from the
+device. progress, if non-nil, is called with each line bugreportz prints. Older devices only
+generate the text of bugreport, which is written to w as it's generated.
+
+Returns a CommandFailed error with bugreportz's message if it fails, or a CommandCanceled
+error if ctx is done first.
+
+Corresponds to the command:
+
+	adb bugreport
+*/
+func (c *Device) BugReport(ctx context.Context, w io.Writer, progress func(BugReportEvent)) (*BugReportResult, error) {
+	version, err := c.bugreportzVersion()
+	if err != nil {
+		return nil, wrapClientError(err, c, "BugReport")
+	}
+	if version == "" {
+		return c.textBugReport(ctx, w)
+	}
+
+	args := []string{"-p"}
+	if version == "1.0" {
+		// -p was added in version 1.1.
+		args = nil
+	}
+
+	var path, failure string
+	err = c.RunCommandLines(ctx, func(line string) error {
+		event, ok := parseBugReportEvent(line)
+		if !ok {
+			return nil
+		}
+		switch event.Type {
+		case BugReportOK:
+			path = event.Path
+		case BugReportFail:
+			failure = event.Message
+		}
+		if progress != nil {
+			progress(event)
+		}
+		return nil
+	}, "bugreportz", args...)
+	if err != nil {
+		return nil, err
+	}
+	if failure != "" {
+		return nil, wrapClientError(errors.Errorf(errors.CommandFailed, "bugreportz failed: %s", failure), c, "BugReport")
+	}
+	if path == "" {
+		return nil, wrapClientError(errors.Errorf(errors.CommandFailed, "bugreportz exited without reporting the report's path"), c, "BugReport")
+	}
+
+	size, err := c.pullBugReport(ctx, path, w)
+	if err != nil {
+		return nil, wrapClientError(err, c, "BugReport")
+	}
+	return &BugReportResult{Zipped: true, Path: path, Size: size}, nil
+}
+
+// bugreportzVersion returns the version bugreportz prints, e.g. "1.1", or "" if the device
+// doesn't have bugreportz.
+func (c *Device) bugreportzVersion() (string, error) {
+	result, err := c.runShellCommand("bugreportz -v")
+	if err != nil {
+		return "", err
+	}
+	// The version is printed to stderr.
+	output := string(result.Stderr) + "\n" + string(result.Stdout)
+	for _, line := range strings.Split(output, "\n") {
+		if version, ok := strings.CutPrefix(strings.TrimSpace(line), "bugreportz version "); ok {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *Device) textBugReport(ctx context.Context, w io.Writer) (*BugReportResult, error) {
+	stream, err := c.RunCommandStream(ctx, "bugreport")
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	size, err := io.Copy(w, stream)
+	if err != nil {
+		return nil, wrapClientError(localFileError(err, "error writing bug report"), c, "BugReport")
+	}
+	return &BugReportResult{Size: size}, nil
+}
+
+func (c *Device) pullBugReport(ctx context.Context, path string, w io.Writer) (int64, error) {
+	r, err := c.OpenRead(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Printf("[Device] error closing connection: %s", err)
+		}
+	}()
+
+	size, err := io.Copy(w, &contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return 0, localFileError(err, "error writing bug report")
+	}
+	return size, nil
+}
+
+/*
+parseBugReportEvent parses a line printed by bugreportz -p:
+
+	BEGIN:/bugreports/bugreport-x-2024-01-02-10-11-12.zip
+	PROGRESS:125/1000
+	OK:/bugreports/bugreport-x-2024-01-02-10-11-12.zip
+	FAIL:error message
+
+Other lines are ignored.
+*/
+func parseBugReportEvent(line string) (BugReportEvent, bool) {
+	prefix, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+	if !ok {
+		return BugReportEvent{}, false
+	}
+
+	switch prefix {
+	case "BEGIN":
+		return BugReportEvent{Type: BugReportBegin, Path: value}, true
+	case "OK":
+		return BugReportEvent{Type: BugReportOK, Path: value}, true
+	case "FAIL":
+		return BugReportEvent{Type: BugReportFail, Message: value}, true
+	case "PROGRESS":
+		done, total, ok := strings.Cut(value, "/")
+		if !ok {
+			return BugReportEvent{}, false
+		}
+		event := BugReportEvent{Type: BugReportProgress}
+		var err1, err2 error
+		event.Done, err1 = strconv.Atoi(done)
+		event.Total, err2 = strconv.Atoi(total)
+		return event, err1 == nil && err2 == nil
+	}
+	return BugReportEvent{}, false
+}
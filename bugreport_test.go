@@ -0,0 +1,108 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBugReportPath = "/bugreports/bugreport-sdk-2024-01-02-10-11-12.zip"
+
+func TestBugReport(t *testing.T) {
+	d, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		switch cmdline {
+		case "bugreportz -v":
+			return "", "bugreportz version 1.2\n", 0
+		case "bugreportz -p":
+			return "BEGIN:" + testBugReportPath + "\n" +
+				"PROGRESS:0/1000\n" +
+				"PROGRESS:500/1200\n" +
+				"OK:" + testBugReportPath + "\n", "", 0
+		}
+		return "", "unexpected command " + cmdline, 1
+	})
+	d.addFile(testBugReportPath, "PK zip", 0644)
+
+	var events []BugReportEvent
+	var buf bytes.Buffer
+	result, err := device.BugReport(context.Background(), &buf, func(event BugReportEvent) {
+		events = append(events, event)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &BugReportResult{Zipped: true, Path: testBugReportPath, Size: 6}, result)
+	assert.Equal(t, "PK zip", buf.String())
+	assert.Equal(t, []BugReportEvent{
+		{Type: BugReportBegin, Path: testBugReportPath},
+		{Type: BugReportProgress, Done: 0, Total: 1000},
+		{Type: BugReportProgress, Done: 500, Total: 1200},
+		{Type: BugReportOK, Path: testBugReportPath},
+	}, events)
+}
+
+func TestBugReportFailed(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		if cmdline == "bugreportz -v" {
+			return "", "bugreportz version 1.1\n", 0
+		}
+		return "BEGIN:" + testBugReportPath + "\nFAIL:dumpstate failed\n", "", 0
+	})
+
+	var events []BugReportEvent
+	_, err := device.BugReport(context.Background(), &bytes.Buffer{}, func(event BugReportEvent) {
+		events = append(events, event)
+	})
+	assert.True(t, HasErrCode(err, CommandFailed), "%v", err)
+	assert.Contains(t, ErrorWithCauseChain(err), "dumpstate failed")
+	assert.Equal(t, BugReportFail, events[len(events)-1].Type)
+}
+
+func TestBugReportWithoutProgress(t *testing.T) {
+	var commands []string
+	d, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		commands = append(commands, cmdline)
+		if cmdline == "bugreportz -v" {
+			return "", "bugreportz version 1.0\n", 0
+		}
+		return "OK:" + testBugReportPath + "\n", "", 0
+	})
+	d.addFile(testBugReportPath, "PK", 0644)
+
+	result, err := device.BugReport(context.Background(), &bytes.Buffer{}, nil)
+	require.NoError(t, err)
+	assert.True(t, result.Zipped)
+	assert.Equal(t, []string{"bugreportz -v", "bugreportz"}, commands)
+}
+
+func TestBugReportText(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		switch cmdline {
+		case "bugreportz -v":
+			return "", "/system/bin/sh: bugreportz: not found\n", 127
+		case "bugreport":
+			return "== dumpstate: 2016-01-02 10:11:12\n", "", 0
+		}
+		return "", "unexpected command " + cmdline, 1
+	})
+
+	var buf bytes.Buffer
+	result, err := device.BugReport(context.Background(), &buf, nil)
+	require.NoError(t, err)
+	assert.False(t, result.Zipped)
+	assert.Equal(t, "== dumpstate: 2016-01-02 10:11:12\n", buf.String())
+	assert.Equal(t, int64(buf.Len()), result.Size)
+}
+
+func TestParseBugReportEvent(t *testing.T) {
+	event, ok := parseBugReportEvent("PROGRESS:12/100\r")
+	assert.True(t, ok)
+	assert.Equal(t, BugReportEvent{Type: BugReportProgress, Done: 12, Total: 100}, event)
+
+	for _, line := range []string{"", "dumpstate starting", "PROGRESS:12", "PROGRESS:a/100"} {
+		_, ok := parseBugReportEvent(line)
+		assert.False(t, ok, line)
+	}
+	assert.Equal(t, "PROGRESS", BugReportProgress.String())
+}
@@ -0,0 +1,203 @@
+package adb
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/wire"
+)
+
+/*
+CommandStream is a command started by Device.Exec or Device.RunCommandStream. Reading from
+it returns the command's output as it's produced, and writing to it writes to the command's
+stdin. Reads block until the command writes more output, and a command that writes faster
+than its output is read is blocked in turn.
+
+Close must be called when done with the stream, even if the command has exited.
+*/
+type CommandStream struct {
+	device    *Device
+	operation string
+	conn      *wire.Conn
+	ctx       context.Context
+
+	// stop unregisters the func that closes conn when ctx is done.
+	stop      func() bool
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var _ io.ReadWriteCloser = &CommandStream{}
+
+/*
+Exec runs argv on the device using the exec: service and returns its output and stdin as a
+stream. Every element of argv is quoted, as for RunCommand.
+
+Unlike the shell: service used by RunCommand, exec: never allocates a PTY, so the output
+isn't subject to line-ending translation. Use it to stream binary output, e.g.
+
+	stream, err := device.Exec(ctx, "screencap", "-p")
+	...
+	defer stream.Close()
+	_, err = io.Copy(pngFile, stream)
+
+The exec: service merges stderr into the output and doesn't report the exit status of the
+command. Once ctx is done, the connection is closed, and reads and writes return a
+CommandCanceled error.
+
+Corresponds to the commands:
+
+	adb exec-out
+	adb exec-in
+*/
+func (c *Device) Exec(ctx context.Context, argv ...string) (*CommandStream, error) {
+	if len(argv) == 0 || isBlank(argv[0]) {
+		return nil, wrapClientError(errors.AssertionErrorf("command cannot be empty"), c, "Exec")
+	}
+	return c.openCommandStream(ctx, "exec:"+shellCommandLine(argv[0], argv[1:]...), "Exec")
+}
+
+/*
+RunCommandStream runs a command in a shell on the device like RunCommand, but returns its
+output as a stream instead of waiting for the command to exit. Use it for long-running
+commands such as logcat, top or getevent, or see RunCommandLines.
+
+Output goes through the shell: service, so on older devices line endings are translated to
+\r\n; use Exec for binary output. Once ctx is done, the connection is closed, and reads
+return a CommandCanceled error.
+*/
+func (c *Device) RunCommandStream(ctx context.Context, cmd string, args ...string) (*CommandStream, error) {
+	cmd, err := prepareCommandLine(cmd, args...)
+	if err != nil {
+		return nil, wrapClientError(err, c, "RunCommandStream")
+	}
+	return c.openCommandStream(ctx, "shell:"+cmd, "RunCommandStream")
+}
+
+/*
+RunCommandLines runs a command in a shell on the device like RunCommandStream, and calls
+handler with each line of output, without the line ending, as it arrives. The command isn't
+read from while handler runs, so a slow handler slows down the command rather than
+buffering its output.
+
+RunCommandLines returns when the command exits, when ctx is done, or when handler returns
+an error, which is returned as is.
+
+Eg.
+
+	err := device.RunCommandLines(ctx, func(line string) error {
+		if strings.Contains(line, "Displayed") {
+			return errFound
+		}
+		return nil
+	}, "logcat", "-s", "ActivityTaskManager")
+*/
+func (c *Device) RunCommandLines(ctx context.Context, handler func(line string) error, cmd string, args ...string) error {
+	stream, err := c.RunCommandStream(ctx, cmd, args...)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	r := bufio.NewReader(stream)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			if err := handler(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// openCommandStream opens the device service req, whose output isn't framed, as a stream.
+func (c *Device) openCommandStream(ctx context.Context, req, operation string) (*CommandStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, wrapClientError(errors.WrapErrorf(err, errors.CommandCanceled, "command canceled"), c, "%s", operation)
+	}
+
+	conn, err := c.dialDevice()
+	if err != nil {
+		return nil, wrapClientError(err, c, "%s", operation)
+	}
+
+	if err = conn.SendMessage([]byte(req)); err == nil {
+		_, err = conn.ReadStatus(req)
+	}
+	if err != nil {
+		if err := conn.Close(); err != nil {
+			log.Printf("[Device] error closing connection: %s", err)
+		}
+		return nil, wrapClientError(err, c, "%s", operation)
+	}
+
+	s := &CommandStream{device: c, operation: operation, conn: conn, ctx: ctx}
+	s.stop = context.AfterFunc(ctx, func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("[Device] error closing connection: %s", err)
+		}
+	})
+	return s, nil
+}
+
+// Read reads the command's output. It returns io.EOF once the command exits and all its
+// output has been read.
+func (s *CommandStream) Read(p []byte) (int, error) {
+	n, err := s.conn.Read(p)
+	if err != nil && err != io.EOF {
+		err = s.wrapError(err)
+	}
+	return n, err
+}
+
+// Write writes p to the command's stdin.
+func (s *CommandStream) Write(p []byte) (int, error) {
+	n, err := s.conn.Write(p)
+	return n, s.wrapError(err)
+}
+
+/*
+CloseWrite closes the command's stdin, while leaving its output open for reading.
+
+This relies on the connection to the adb server supporting half-closing, as TCP
+connections do, and on the adb server passing it on to the device. Older adb servers
+close the whole stream instead.
+*/
+func (s *CommandStream) CloseWrite() error {
+	return s.wrapError(s.conn.CloseWrite())
+}
+
+// Close closes the stream. A command that's still running normally exits with SIGPIPE the
+// next time it writes any output.
+func (s *CommandStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.stop()
+		s.closeErr = wrapClientError(s.conn.Close(), s.device, "%s", s.operation)
+	})
+	return s.closeErr
+}
+
+// wrapError reports errors as CommandCanceled once the context is done, since they're
+// caused by the connection having been closed.
+func (s *CommandStream) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		err = errors.WrapErrorf(ctxErr, errors.CommandCanceled, "command canceled")
+	} else if _, ok := err.(*errors.Err); !ok {
+		err = errors.WrapErrorf(err, errors.NetworkError, "error using command stream")
+	}
+	return wrapClientError(err, s.device, "%s", s.operation)
+}
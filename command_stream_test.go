@@ -0,0 +1,185 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecOutputIsBinarySafe(t *testing.T) {
+	d := newFakeDevice()
+	output := "\x89PNG\r\n\x1a\n\x00\xff\r\n"
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, output)
+	}
+	device := newFakeDeviceClient(d)
+
+	stream, err := device.Exec(context.Background(), "screencap", "-p")
+	require.NoError(t, err)
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.NoError(t, stream.Close())
+	assert.NoError(t, stream.Close())
+	d.wait()
+
+	assert.Equal(t, output, string(data))
+	assert.Equal(t, []string{"exec:screencap -p"}, d.requests)
+}
+
+func TestExecQuotesArgs(t *testing.T) {
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {}
+	device := newFakeDeviceClient(d)
+
+	stream, err := device.Exec(context.Background(), "cat", "/sdcard/my file's.bin")
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	d.wait()
+
+	assert.Equal(t, []string{`exec:cat '/sdcard/my file'\''s.bin'`}, d.requests)
+}
+
+func TestExecWritesStdin(t *testing.T) {
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err == nil {
+			io.WriteString(conn, strings.ToUpper(string(buf)))
+		}
+	}
+	device := newFakeDeviceClient(d)
+
+	stream, err := device.Exec(context.Background(), "tr", "a-z", "A-Z")
+	require.NoError(t, err)
+	defer stream.Close()
+
+	n, err := stream.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO", string(data))
+
+	// net.Pipe can't be half-closed.
+	assert.True(t, HasErrCode(stream.CloseWrite(), AssertionError))
+}
+
+func TestExecCanceled(t *testing.T) {
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		io.Copy(io.Discard, conn)
+	}
+	device := newFakeDeviceClient(d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := device.Exec(ctx, "logcat")
+	require.NoError(t, err)
+	defer stream.Close()
+
+	cancel()
+	_, err = stream.Read(make([]byte, 10))
+	assert.True(t, HasErrCode(err, CommandCanceled))
+	d.wait()
+
+	_, err = device.Exec(ctx, "logcat")
+	assert.True(t, HasErrCode(err, CommandCanceled))
+}
+
+func TestExecErrors(t *testing.T) {
+	d := newFakeDevice()
+	device := newFakeDeviceClient(d)
+
+	_, err := device.Exec(context.Background())
+	assert.True(t, HasErrCode(err, AssertionError))
+
+	_, err = device.Exec(context.Background(), "ls")
+	assert.True(t, HasErrCode(err, AdbError))
+	assert.Contains(t, ErrorWithCauseChain(err), "unknown service exec:ls")
+	d.wait()
+}
+
+func TestRunCommandStream(t *testing.T) {
+	d := newFakeDevice()
+	d.services["shell:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "first\r\n")
+		io.WriteString(conn, "second\r\n")
+	}
+	device := newFakeDeviceClient(d)
+
+	stream, err := device.RunCommandStream(context.Background(), "logcat", "-s", "My Tag")
+	require.NoError(t, err)
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	d.wait()
+
+	assert.Equal(t, "first\r\nsecond\r\n", string(data))
+	assert.Equal(t, []string{"shell:logcat -s 'My Tag'"}, d.requests)
+}
+
+func TestRunCommandLines(t *testing.T) {
+	d := newFakeDevice()
+	d.services["shell:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "one\r\ntw")
+		io.WriteString(conn, "o\n\nthree")
+	}
+	device := newFakeDeviceClient(d)
+
+	var lines []string
+	err := device.RunCommandLines(context.Background(), func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}, "top", "-d", "1")
+	require.NoError(t, err)
+	d.wait()
+
+	assert.Equal(t, []string{"one", "two", "", "three"}, lines)
+}
+
+func TestRunCommandLinesHandlerError(t *testing.T) {
+	d := newFakeDevice()
+	d.services["shell:"] = func(conn net.Conn, req string) {
+		for {
+			if _, err := io.WriteString(conn, "line\n"); err != nil {
+				return
+			}
+		}
+	}
+	device := newFakeDeviceClient(d)
+
+	errStop := errors.New("stop")
+	count := 0
+	err := device.RunCommandLines(context.Background(), func(line string) error {
+		count++
+		if count == 3 {
+			return errStop
+		}
+		return nil
+	}, "yes", "line")
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, 3, count)
+	d.wait()
+}
+
+func TestRunCommandLinesCanceled(t *testing.T) {
+	d := newFakeDevice()
+	d.services["shell:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "started\n")
+		io.Copy(io.Discard, conn)
+	}
+	device := newFakeDeviceClient(d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := device.RunCommandLines(ctx, func(line string) error {
+		cancel()
+		return nil
+	}, "logcat")
+	assert.True(t, HasErrCode(err, CommandCanceled))
+	d.wait()
+}
@@ -0,0 +1,145 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+const (
+	// TombstoneDir is where debuggerd writes tombstones of native crashes.
+	TombstoneDir = "/data/tombstones"
+	// ANRDir is where the system writes the stack traces of apps that aren't responding.
+	ANRDir = "/data/anr"
+)
+
+// CrashFile is a tombstone or ANR trace on the device.
+type CrashFile struct {
+	Path       string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+/*
+ListTombstones returns the tombstones in TombstoneDir, oldest first. The protobuf copies
+Android 12 and later write next to the text tombstones are skipped.
+
+Reading TombstoneDir requires root, or adbd running as root on a userdebug or eng build.
+Otherwise, the list is empty.
+*/
+func (c *Device) ListTombstones() ([]CrashFile, error) {
+	files, err := c.listCrashFiles(TombstoneDir, func(name string) bool {
+		return strings.HasPrefix(name, "tombstone_") && !strings.HasSuffix(name, ".pb")
+	})
+	return files, wrapClientError(err, c, "ListTombstones")
+}
+
+/*
+ListANRTraces returns the ANR traces in ANRDir, oldest first.
+
+Like TombstoneDir, reading ANRDir requires root.
+*/
+func (c *Device) ListANRTraces() ([]CrashFile, error) {
+	files, err := c.listCrashFiles(ANRDir, func(name string) bool {
+		return strings.HasPrefix(name, "anr_") || name == "traces.txt"
+	})
+	return files, wrapClientError(err, c, "ListANRTraces")
+}
+
+func (c *Device) listCrashFiles(dir string, match func(name string) bool) ([]CrashFile, error) {
+	entries, err := c.ListDirEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+	all, err := entries.ReadAll()
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.NetworkError, "error listing %s", dir)
+	}
+
+	files := []CrashFile{}
+	for _, entry := range all {
+		if entry.Mode.IsRegular() && match(entry.Name) {
+			files = append(files, CrashFile{
+				Path:       path.Join(dir, entry.Name),
+				Size:       int64(entry.Size),
+				ModifiedAt: entry.ModifiedAt,
+			})
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].ModifiedAt.Equal(files[j].ModifiedAt) {
+			return files[i].ModifiedAt.Before(files[j].ModifiedAt)
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+/*
+PullCrashFiles copies files, as listed by ListTombstones or ListANRTraces, into localDir,
+keeping their names. Errors are reported the same way as PullDir.
+
+Eg. to collect the crashes of a test run:
+
+	before := time.Now()
+	...
+	tombstones, err := device.ListTombstones()
+	...
+	var recent []adb.CrashFile
+	for _, f := range tombstones {
+		if f.ModifiedAt.After(before) {
+			recent = append(recent, f)
+		}
+	}
+	_, err = device.PullCrashFiles(ctx, recent, outputDir, adb.TransferOptions{})
+*/
+func (c *Device) PullCrashFiles(ctx context.Context, files []CrashFile, localDir string, opts TransferOptions) (*TransferResult, error) {
+	jobs := make([]*transferJob, len(files))
+	for i, f := range files {
+		jobs[i] = &transferJob{
+			file: TransferredFile{
+				LocalPath:  filepath.Join(localDir, path.Base(f.Path)),
+				RemotePath: f.Path,
+				Size:       f.Size,
+			},
+			mode:  0644,
+			mtime: f.ModifiedAt,
+		}
+	}
+
+	result, err := c.runTransfers(ctx, jobs, opts, func(session *SyncSession, job *transferJob) (bool, error) {
+		return false, pullFile(ctx, session, job)
+	})
+	return result, wrapClientError(err, c, "PullCrashFiles(%s)", localDir)
+}
+
+// ReadTombstone reads the tombstone at path, e.g. from ListTombstones, and parses it with
+// ParseTombstone.
+func (c *Device) ReadTombstone(path string) (*Tombstone, error) {
+	r, err := c.OpenRead(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Printf("[Device] error closing connection: %s", err)
+		}
+	}()
+
+	text, err := io.ReadAll(r)
+	if err != nil {
+		if _, ok := err.(*errors.Err); !ok {
+			err = errors.WrapErrorf(err, errors.NetworkError, "error reading %s", path)
+		}
+		return nil, wrapClientError(err, c, "ReadTombstone(%s)", path)
+	}
+	tombstone, err := ParseTombstone(string(text))
+	return tombstone, wrapClientError(err, c, "ReadTombstone(%s)", path)
+}
@@ -0,0 +1,66 @@
+package adb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeCrashDevice() (*fakeDevice, *Device) {
+	d := newFakeDevice()
+	d.addFile("/data/tombstones/tombstone_01", testTombstone, 0600)
+	d.addFile("/data/tombstones/tombstone_01.pb", "protobuf", 0600)
+	d.addFile("/data/tombstones/tombstone_00", "pid: 1, tid: 1, name: init  >>> /system/bin/init <<<\n", 0600)
+	d.addFile("/data/anr/anr_2024-01-02-10-11-12-345", "----- pid 1234 at 2024-01-02 10:11:12 -----", 0600)
+	d.addFile("/data/anr/other", "", 0600)
+	d.files["/data/tombstones/tombstone_01"].mtime = someTime.Add(time.Hour)
+	return d, newFakeDeviceClient(d)
+}
+
+func TestListCrashFiles(t *testing.T) {
+	_, device := newFakeCrashDevice()
+
+	tombstones, err := device.ListTombstones()
+	require.NoError(t, err)
+	assert.Equal(t, []CrashFile{
+		{Path: "/data/tombstones/tombstone_00", Size: 53, ModifiedAt: someTime},
+		{Path: "/data/tombstones/tombstone_01", Size: int64(len(testTombstone)), ModifiedAt: someTime.Add(time.Hour)},
+	}, tombstones)
+
+	anrs, err := device.ListANRTraces()
+	require.NoError(t, err)
+	require.Len(t, anrs, 1)
+	assert.Equal(t, "/data/anr/anr_2024-01-02-10-11-12-345", anrs[0].Path)
+}
+
+func TestPullCrashFiles(t *testing.T) {
+	_, device := newFakeCrashDevice()
+	tombstones, err := device.ListTombstones()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	result, err := device.PullCrashFiles(context.Background(), tombstones, dir, TransferOptions{Concurrency: 2})
+	require.NoError(t, err)
+	assert.Len(t, result.Files, 2)
+
+	data, err := os.ReadFile(filepath.Join(dir, "tombstone_01"))
+	require.NoError(t, err)
+	assert.Equal(t, testTombstone, string(data))
+}
+
+func TestReadTombstone(t *testing.T) {
+	_, device := newFakeCrashDevice()
+
+	tombstone, err := device.ReadTombstone("/data/tombstones/tombstone_01")
+	require.NoError(t, err)
+	assert.Equal(t, 1250, tombstone.Tid)
+	assert.Len(t, tombstone.Backtrace, 3)
+
+	_, err = device.ReadTombstone("/data/tombstones/tombstone_01.pb")
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+}
@@ -98,8 +98,10 @@ From the Android docs:
 
 Source: https://android.googlesource.com/platform/system/core/+/master/adb/SERVICES.TXT
 
-This method quotes the arguments for you, and will return an error if any of them
-contain double quotes.
+This method quotes the arguments for you, using POSIX single quotes where needed, so each
+arg reaches cmd verbatim: quotes, $, backticks, ;, * and & are not interpreted by the shell.
+cmd itself is passed to the shell as is. To run a command line that uses shell syntax such
+as pipes or redirection, use RunCommandRaw.
 */
 func (c *Device) RunCommand(cmd string, args ...string) (string, error) {
 	cmd, err := prepareCommandLine(cmd, args...)
@@ -133,6 +135,23 @@ func (c *Device) RunCommand(cmd string, args ...string) (string, error) {
 	return string(resp), wrapClientError(err, c, "RunCommand")
 }
 
+/*
+RunCommandRaw runs cmdline in a shell on the device exactly as given, so the shell
+interprets any quoting, variables, pipes or redirections in it. Callers are responsible for
+quoting untrusted input.
+
+Eg.
+
+	output, err := device.RunCommandRaw("logcat -d | grep -c ActivityManager")
+*/
+func (c *Device) RunCommandRaw(cmdline string) (string, error) {
+	if isBlank(cmdline) {
+		return "", wrapClientError(errors.AssertionErrorf("command cannot be empty"), c, "RunCommandRaw")
+	}
+	resp, err := c.readShellService("shell:" + cmdline)
+	return string(resp), wrapClientError(err, c, "RunCommandRaw")
+}
+
 /*
 RunCommandWithTimeout runs the specified commands on a shell on the device with a timeout.
 
@@ -391,26 +410,21 @@ func (c *Device) dialDevice() (*wire.Conn, error) {
 
 // prepareCommandLine validates the command and argument strings, quotes
 // arguments if required, and joins them into a valid adb command string.
+// See shellQuote for how arguments are quoted.
 func prepareCommandLine(cmd string, args ...string) (string, error) {
 	if isBlank(cmd) {
 		return "", errors.AssertionErrorf("command cannot be empty")
 	}
 
-	for i, arg := range args {
-		if strings.ContainsRune(arg, '"') {
-			return "", errors.Errorf(errors.ParseError, "arg at index %d contains an invalid double quote: %s", i, arg)
-		}
-		if containsWhitespace(arg) {
-			args[i] = fmt.Sprintf("\"%s\"", arg)
-		}
+	if len(args) == 0 {
+		return cmd, nil
 	}
 
-	// Prepend the command to the args array.
-	if len(args) > 0 {
-		cmd = fmt.Sprintf("%s %s", cmd, strings.Join(args, " "))
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
 	}
-
-	return cmd, nil
+	return fmt.Sprintf("%s %s", cmd, strings.Join(quoted, " ")), nil
 }
 
 func (c *Device) ForwardPort(port uint16) error {
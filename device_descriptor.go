@@ -14,6 +14,8 @@ const (
 	DeviceUsb
 	// host:transport-local and host-local:<request>
 	DeviceLocal
+	// host:transport-id:<id> and host-transport-id:<id>:<request>
+	DeviceTransportID
 )
 
 type DeviceDescriptor struct {
@@ -21,6 +23,9 @@ type DeviceDescriptor struct {
 
 	// Only used if Type is DeviceSerial.
 	serial string
+
+	// Only used if Type is DeviceTransportID.
+	transportID uint64
 }
 
 func AnyDevice() DeviceDescriptor {
@@ -42,10 +47,22 @@ func DeviceWithSerial(serial string) DeviceDescriptor {
 	}
 }
 
+// deviceWithTransportID matches the device connected over the transport with id.
+// Unlike a serial, a transport id isn't reused when the device reconnects.
+func deviceWithTransportID(id uint64) DeviceDescriptor {
+	return DeviceDescriptor{
+		descriptorType: DeviceTransportID,
+		transportID:    id,
+	}
+}
+
 func (d DeviceDescriptor) String() string {
 	if d.descriptorType == DeviceSerial {
 		return fmt.Sprintf("%s[%s]", d.descriptorType, d.serial)
 	}
+	if d.descriptorType == DeviceTransportID {
+		return fmt.Sprintf("%s[%d]", d.descriptorType, d.transportID)
+	}
 	return d.descriptorType.String()
 }
 
@@ -59,6 +76,8 @@ func (d DeviceDescriptor) getHostPrefix() string {
 		return "host-local"
 	case DeviceSerial:
 		return fmt.Sprintf("host-serial:%s", d.serial)
+	case DeviceTransportID:
+		return fmt.Sprintf("host-transport-id:%d", d.transportID)
 	default:
 		panic(fmt.Sprintf("invalid DeviceDescriptorType: %v", d.descriptorType))
 	}
@@ -74,6 +93,37 @@ func (d DeviceDescriptor) getTransportDescriptor() string {
 		return "transport-local"
 	case DeviceSerial:
 		return fmt.Sprintf("transport:%s", d.serial)
+	case DeviceTransportID:
+		return fmt.Sprintf("transport-id:%d", d.transportID)
+	default:
+		panic(fmt.Sprintf("invalid DeviceDescriptorType: %v", d.descriptorType))
+	}
+}
+
+// getWaitForTransport returns the transport used in wait-for-<transport>-<state> requests.
+func (d DeviceDescriptor) getWaitForTransport() string {
+	switch d.descriptorType {
+	case DeviceUsb:
+		return "usb"
+	case DeviceLocal:
+		return "local"
+	default:
+		return "any"
+	}
+}
+
+// getTportDescriptor returns the descriptor for host:tport: requests, which switch to the
+// device's transport like host:transport but also reply with the transport's id.
+func (d DeviceDescriptor) getTportDescriptor() string {
+	switch d.descriptorType {
+	case DeviceAny:
+		return "tport:any"
+	case DeviceUsb:
+		return "tport:usb"
+	case DeviceLocal:
+		return "tport:local"
+	case DeviceSerial:
+		return fmt.Sprintf("tport:serial:%s", d.serial)
 	default:
 		panic(fmt.Sprintf("invalid DeviceDescriptorType: %v", d.descriptorType))
 	}
@@ -0,0 +1,255 @@
+package adb
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// FileInfo returns e as an fs.FileInfo.
+// DirEntry can't implement fs.FileInfo itself, since its fields use the same names
+// as the interface's methods.
+func (e *DirEntry) FileInfo() fs.FileInfo {
+	return dirEntryInfo{e}
+}
+
+// DirEntry returns e as an fs.DirEntry.
+func (e *DirEntry) DirEntry() fs.DirEntry {
+	return dirEntryInfo{e}
+}
+
+// dirEntryInfo adapts a DirEntry to fs.FileInfo and fs.DirEntry.
+type dirEntryInfo struct {
+	entry *DirEntry
+}
+
+var (
+	_ fs.FileInfo = dirEntryInfo{}
+	_ fs.DirEntry = dirEntryInfo{}
+)
+
+func (i dirEntryInfo) Name() string               { return i.entry.Name }
+func (i dirEntryInfo) Size() int64                { return int64(uint32(i.entry.Size)) }
+func (i dirEntryInfo) Mode() fs.FileMode          { return i.entry.Mode }
+func (i dirEntryInfo) ModTime() time.Time         { return i.entry.ModifiedAt }
+func (i dirEntryInfo) IsDir() bool                { return i.entry.Mode.IsDir() }
+func (i dirEntryInfo) Sys() any                   { return i.entry }
+func (i dirEntryInfo) Type() fs.FileMode          { return i.entry.Mode.Type() }
+func (i dirEntryInfo) Info() (fs.FileInfo, error) { return i, nil }
+func (i dirEntryInfo) String() string             { return fs.FormatFileInfo(i) }
+
+/*
+FS returns a read-only fs.FS for the directory root on the device. It also implements
+fs.StatFS, fs.ReadDirFS and fs.ReadFileFS, so it can be used with fs.WalkDir, fs.Glob,
+template.ParseFS, http.FS and the like.
+
+Every operation opens its own sync connection, as Device.Stat, Device.ListDirEntries and
+Device.OpenRead do. Symlinks are reported as symlinks rather than followed, except when
+reading a file's contents.
+
+Errors are *fs.PathError values. Missing files are reported with fs.ErrNotExist as the
+underlying error; anything else carries the original *errors.Err.
+*/
+func (c *Device) FS(root string) fs.FS {
+	return &deviceFS{device: c, root: root}
+}
+
+type deviceFS struct {
+	device *Device
+	root   string
+}
+
+var (
+	_ fs.StatFS     = &deviceFS{}
+	_ fs.ReadDirFS  = &deviceFS{}
+	_ fs.ReadFileFS = &deviceFS{}
+)
+
+func (f *deviceFS) Open(name string) (fs.File, error) {
+	entry, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &deviceFile{fsys: f, name: name, entry: entry}, nil
+}
+
+func (f *deviceFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := f.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.FileInfo(), nil
+}
+
+func (f *deviceFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := f.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.Errorf(errors.AssertionError, "not a directory")}
+	}
+	return f.readDir(name)
+}
+
+func (f *deviceFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	r, err := f.device.OpenRead(f.fullPath(name))
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+	return data, nil
+}
+
+func (f *deviceFS) fullPath(name string) string {
+	return path.Join(f.root, name)
+}
+
+func (f *deviceFS) stat(op, name string) (*DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, err := f.device.Stat(f.fullPath(name))
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	entry.Name = path.Base(name)
+	return entry, nil
+}
+
+// readDir lists the directory name, sorted by file name and without "." and "..".
+func (f *deviceFS) readDir(name string) ([]fs.DirEntry, error) {
+	entries, err := f.device.ListDirEntries(f.fullPath(name))
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+	all, err := entries.ReadAll()
+	if err != nil {
+		if _, ok := err.(*errors.Err); !ok {
+			err = errors.WrapErrorf(err, errors.NetworkError, "error reading dir entries")
+		}
+		return nil, pathError("readdir", name, err)
+	}
+
+	result := make([]fs.DirEntry, 0, len(all))
+	for _, entry := range all {
+		if entry.Name != "." && entry.Name != ".." {
+			result = append(result, entry.DirEntry())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+// pathError wraps err in an *fs.PathError, translating FileNoExistError to fs.ErrNotExist.
+func pathError(op, name string, err error) error {
+	if HasErrCode(err, FileNoExistError) {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// deviceFile is a file or directory opened from a deviceFS.
+// File contents aren't requested until the first Read, so opening a file just to Stat it
+// only costs a single sync request.
+type deviceFile struct {
+	fsys  *deviceFS
+	name  string
+	entry *DirEntry
+
+	reader io.ReadCloser
+
+	// Directory entries not returned by ReadDir yet, loaded by the first call.
+	entries    []fs.DirEntry
+	entriesErr error
+	listed     bool
+
+	closed bool
+}
+
+var _ fs.ReadDirFile = &deviceFile{}
+
+func (f *deviceFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.entry.FileInfo(), nil
+}
+
+func (f *deviceFile) Read(buf []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.entry.Mode.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.Errorf(errors.AssertionError, "is a directory")}
+	}
+	if f.reader == nil {
+		reader, err := f.fsys.device.OpenRead(f.fsys.fullPath(f.name))
+		if err != nil {
+			return 0, pathError("read", f.name, err)
+		}
+		f.reader = reader
+	}
+
+	n, err := f.reader.Read(buf)
+	if err != nil && err != io.EOF {
+		err = pathError("read", f.name, err)
+	}
+	return n, err
+}
+
+func (f *deviceFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.entry.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.Errorf(errors.AssertionError, "not a directory")}
+	}
+	if !f.listed {
+		f.entries, f.entriesErr = f.fsys.readDir(f.name)
+		f.listed = true
+	}
+	if f.entriesErr != nil {
+		return nil, f.entriesErr
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+func (f *deviceFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
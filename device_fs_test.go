@@ -0,0 +1,109 @@
+package adb
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeFS() (*fakeDevice, fs.FS) {
+	d := newFakeDevice()
+	d.addFile("/sdcard/root/hello.txt", "hello world", 0644)
+	d.addFile("/sdcard/root/templates/a.tmpl", "{{.A}}", 0644)
+	d.addFile("/sdcard/root/templates/b.tmpl", "{{.B}}", 0600)
+	d.addDir("/sdcard/root/empty")
+	return d, newFakeDeviceClient(d).FS("/sdcard/root")
+}
+
+func TestDeviceFS(t *testing.T) {
+	_, fsys := newFakeFS()
+	err := fstest.TestFS(fsys, "hello.txt", "templates/a.tmpl", "templates/b.tmpl", "empty")
+	assert.NoError(t, err)
+}
+
+func TestDeviceFSReadFile(t *testing.T) {
+	_, fsys := newFakeFS()
+
+	data, err := fs.ReadFile(fsys, "hello.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	_, err = fs.ReadFile(fsys, "missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fs.ReadFile(fsys, "../escape")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestDeviceFSGlobAndWalk(t *testing.T) {
+	_, fsys := newFakeFS()
+
+	matches, err := fs.Glob(fsys, "templates/*.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"templates/a.tmpl", "templates/b.tmpl"}, matches)
+
+	var walked []string
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		walked = append(walked, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".", "empty", "hello.txt", "templates", "templates/a.tmpl", "templates/b.tmpl"}, walked)
+}
+
+func TestDeviceFSStat(t *testing.T) {
+	_, fsys := newFakeFS()
+
+	info, err := fs.Stat(fsys, "templates/b.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "b.tmpl", info.Name())
+	assert.Equal(t, int64(6), info.Size())
+	assert.Equal(t, fs.FileMode(0600), info.Mode())
+	assert.Equal(t, someTime, info.ModTime())
+	assert.IsType(t, &DirEntry{}, info.Sys())
+
+	_, err = fs.Stat(fsys, "nope")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestDeviceFSOpenDoesNotReadUntilNeeded(t *testing.T) {
+	d, fsys := newFakeFS()
+
+	f, err := fsys.Open("hello.txt")
+	require.NoError(t, err)
+	_, err = f.Stat()
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	d.wait()
+	assert.Equal(t, []string{"STAT"}, d.syncRequests)
+
+	f, err = fsys.Open("hello.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	require.NoError(t, f.Close())
+
+	_, err = f.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, fs.ErrClosed)
+}
+
+func TestDirEntryFileInfo(t *testing.T) {
+	entry := &DirEntry{Name: "dir", Mode: fs.ModeDir | 0755, Size: 4096, ModifiedAt: someTime}
+
+	info := entry.FileInfo()
+	assert.True(t, info.IsDir())
+	assert.Equal(t, "dir", info.Name())
+	assert.Equal(t, int64(4096), info.Size())
+
+	de := entry.DirEntry()
+	assert.Equal(t, fs.ModeDir, de.Type())
+	info, err := de.Info()
+	require.NoError(t, err)
+	assert.Equal(t, someTime, info.ModTime())
+}
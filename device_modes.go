@@ -0,0 +1,274 @@
+package adb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/wire"
+)
+
+// RebootMode selects what the device reboots into.
+type RebootMode string
+
+const (
+	RebootNormal     RebootMode = ""
+	RebootBootloader RebootMode = "bootloader"
+	RebootRecovery   RebootMode = "recovery"
+	// RebootSideload reboots into recovery, ready for adb sideload.
+	RebootSideload RebootMode = "sideload"
+	// RebootSideloadAutoReboot is like RebootSideload, but reboots normally once the
+	// sideload finishes.
+	RebootSideloadAutoReboot RebootMode = "sideload-auto-reboot"
+	// RebootFastboot reboots into fastbootd, the userspace fastboot of Android 10 and later.
+	RebootFastboot RebootMode = "fastboot"
+)
+
+// rebootStates is the state the device reconnects to adb in after rebooting into each mode.
+// The device doesn't come back to adb in the other modes.
+var rebootStates = map[RebootMode]DeviceState{
+	RebootNormal:             StateOnline,
+	RebootRecovery:           StateRecovery,
+	RebootSideload:           StateSideload,
+	RebootSideloadAutoReboot: StateSideload,
+}
+
+// ServiceResult is the outcome of a request that changes how the device or adbd runs.
+type ServiceResult struct {
+	// Message is adbd's reply, e.g. "restarting adbd as root".
+	Message string
+
+	// Restarted is true if adbd or the device restarted. The method waited for the device
+	// to disconnect and, unless documented otherwise, to reconnect.
+	Restarted bool
+
+	// RebootRequired is true if the change only takes effect once the device reboots.
+	RebootRequired bool
+}
+
+/*
+Reboot reboots the device into mode, then waits for it to disconnect. For RebootNormal,
+RebootRecovery, RebootSideload and RebootSideloadAutoReboot, it also waits for the device to
+reconnect in that mode. Use WaitForBootComplete to wait for a normal reboot to finish booting.
+
+Corresponds to the command:
+
+	adb reboot [mode]
+*/
+func (c *Device) Reboot(ctx context.Context, mode RebootMode) (*ServiceResult, error) {
+	operation := fmt.Sprintf("Reboot(%s)", mode)
+	message, transportID, err := c.runModeService(ctx, "reboot:"+string(mode))
+	if err != nil {
+		return nil, wrapClientError(err, c, "%s", operation)
+	}
+
+	result := &ServiceResult{Message: message, Restarted: true}
+	state, reconnects := rebootStates[mode]
+	if !reconnects {
+		state = StateDisconnected
+	}
+	return result, wrapClientError(c.waitForRestart(ctx, transportID, state), c, "%s", operation)
+}
+
+/*
+Root restarts adbd with root permissions, and waits for the device to reconnect.
+Production builds don't allow it, in which case a PermissionDenied error is returned.
+
+Corresponds to the command:
+
+	adb root
+*/
+func (c *Device) Root(ctx context.Context) (*ServiceResult, error) {
+	return c.restartAdbd(ctx, "root:", "Root", StateOnline)
+}
+
+/*
+Unroot restarts adbd without root permissions, and waits for the device to reconnect.
+
+Corresponds to the command:
+
+	adb unroot
+*/
+func (c *Device) Unroot(ctx context.Context) (*ServiceResult, error) {
+	return c.restartAdbd(ctx, "unroot:", "Unroot", StateOnline)
+}
+
+/*
+Tcpip restarts adbd listening for TCP connections on port, and waits for the device to
+reconnect over its current transport. Connect to the device over the network with
+Adb.Connect.
+
+Corresponds to the command:
+
+	adb tcpip <port>
+*/
+func (c *Device) Tcpip(ctx context.Context, port int) (*ServiceResult, error) {
+	return c.restartAdbd(ctx, "tcpip:"+strconv.Itoa(port), fmt.Sprintf("Tcpip(%d)", port), StateOnline)
+}
+
+/*
+Usb restarts adbd listening only on USB. It only waits for the device to disconnect, since
+a device connected over the network won't reconnect until it's plugged in.
+
+Corresponds to the command:
+
+	adb usb
+*/
+func (c *Device) Usb(ctx context.Context) (*ServiceResult, error) {
+	return c.restartAdbd(ctx, "usb:", "Usb", StateDisconnected)
+}
+
+/*
+DisableVerity disables dm-verity checking on userdebug builds, so that partitions can be
+remounted read-write. The device must be rebooted for it to take effect.
+
+Corresponds to the command:
+
+	adb disable-verity
+*/
+func (c *Device) DisableVerity(ctx context.Context) (*ServiceResult, error) {
+	return c.setVerity(ctx, "disable-verity:", "DisableVerity")
+}
+
+/*
+EnableVerity re-enables dm-verity checking. The device must be rebooted for it to take effect.
+
+Corresponds to the command:
+
+	adb enable-verity
+*/
+func (c *Device) EnableVerity(ctx context.Context) (*ServiceResult, error) {
+	return c.setVerity(ctx, "enable-verity:", "EnableVerity")
+}
+
+func (c *Device) restartAdbd(ctx context.Context, req, operation string, state DeviceState) (*ServiceResult, error) {
+	message, transportID, err := c.runModeService(ctx, req)
+	if err != nil {
+		return nil, wrapClientError(err, c, "%s", operation)
+	}
+
+	result := &ServiceResult{Message: message, Restarted: strings.HasPrefix(message, "restarting")}
+	if strings.Contains(message, "cannot run as root") {
+		return result, wrapClientError(modeServiceError(errors.PermissionDenied, req, message), c, "%s", operation)
+	}
+	if !result.Restarted {
+		return result, nil
+	}
+	return result, wrapClientError(c.waitForRestart(ctx, transportID, state), c, "%s", operation)
+}
+
+func (c *Device) setVerity(ctx context.Context, req, operation string) (*ServiceResult, error) {
+	message, _, err := c.runModeService(ctx, req)
+	if err != nil {
+		return nil, wrapClientError(err, c, "%s", operation)
+	}
+
+	result := &ServiceResult{Message: message, RebootRequired: strings.Contains(message, "reboot your device")}
+	if strings.Contains(message, "USER build") || strings.Contains(message, "must be bootloader unlocked") {
+		return result, wrapClientError(modeServiceError(errors.PermissionDenied, req, message), c, "%s", operation)
+	}
+	return result, nil
+}
+
+func modeServiceError(code errors.ErrCode, req, message string) error {
+	return &errors.Err{
+		Code:    code,
+		Message: fmt.Sprintf("%s failed: %s", strings.TrimSuffix(req, ":"), message),
+		Details: CommandErrorDetails{Command: req, ExitCode: -1, Stderr: message},
+	}
+}
+
+/*
+runModeService opens the device service req, and returns its reply along with the id of the
+device's transport. The id is 0 if the adb server is too old to report it.
+
+Services that reboot the device or restart adbd can drop the connection before the reply
+has been read, so errors after the service has been accepted are ignored.
+*/
+func (c *Device) runModeService(ctx context.Context, req string) (string, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return "", 0, errors.WrapErrorf(err, errors.CommandCanceled, "command canceled")
+	}
+
+	conn, transportID, err := c.dialDeviceWithTransportID()
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("[Device] error closing connection: %s", err)
+		}
+	}()
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	if err = conn.SendMessage([]byte(req)); err != nil {
+		return "", 0, err
+	}
+	if _, err = conn.ReadStatus(req); err != nil {
+		return "", 0, err
+	}
+
+	resp, _ := conn.ReadUntilEof()
+	if err := ctx.Err(); err != nil {
+		return "", 0, errors.WrapErrorf(err, errors.CommandCanceled, "command canceled")
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(resp), "\r\n", "\n")), transportID, nil
+}
+
+// dialDeviceWithTransportID is like dialDevice, but also returns the id of the device's
+// transport, or 0 if the adb server doesn't support host:tport.
+func (c *Device) dialDeviceWithTransportID() (*wire.Conn, uint64, error) {
+	conn, err := c.server.Dial()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req := "host:" + c.descriptor.getTportDescriptor()
+	if err = wire.SendMessageString(conn, req); err == nil {
+		_, err = conn.ReadStatus(req)
+	}
+	if err == nil {
+		var transportID uint64
+		err = binary.Read(conn, binary.LittleEndian, &transportID)
+		if err == nil {
+			return conn, transportID, nil
+		} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = errors.WrapErrorf(err, errors.ConnectionResetError, "error reading transport id")
+		}
+	}
+	if err := conn.Close(); err != nil {
+		log.Printf("[Device] error closing connection: %s", err)
+	}
+	if !HasErrCode(err, AdbError) {
+		return nil, 0, err
+	}
+
+	// Servers older than adb 1.0.40 don't know host:tport.
+	conn, err = c.dialDevice()
+	return conn, 0, err
+}
+
+// waitForRestart waits for the device to disconnect, then for it to be in state.
+// If transportID is 0, it waits for any device matching the descriptor to disconnect,
+// which can miss a device that restarts quickly enough to have already reconnected.
+func (c *Device) waitForRestart(ctx context.Context, transportID uint64, state DeviceState) error {
+	descriptor := c.descriptor
+	if transportID != 0 {
+		descriptor = deviceWithTransportID(transportID)
+	}
+	if err := waitFor(ctx, c.server, descriptor, StateDisconnected); err != nil {
+		return err
+	}
+	if state == StateDisconnected {
+		return nil
+	}
+	return waitFor(ctx, c.server, c.descriptor, state)
+}
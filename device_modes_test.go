@@ -0,0 +1,173 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handleWaitFor answers every wait-for request as soon as it's made.
+func (d *fakeDevice) handleWaitFor(requests ...string) {
+	for _, req := range requests {
+		d.services[req] = func(conn net.Conn, req string) {
+			writeFakeOkay(conn)
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	d := newFakeDevice()
+	d.transportID = 7
+	d.services["root:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "restarting adbd as root\n")
+	}
+	d.handleWaitFor("host-transport-id:7:wait-for-any-disconnect", "host-serial:serial:wait-for-any-device")
+	device := newFakeDeviceClient(d)
+
+	result, err := device.Root(context.Background())
+	require.NoError(t, err)
+	d.wait()
+	assert.Equal(t, &ServiceResult{Message: "restarting adbd as root", Restarted: true}, result)
+	assert.Equal(t, []string{
+		"root:",
+		"host-transport-id:7:wait-for-any-disconnect",
+		"host-serial:serial:wait-for-any-device",
+	}, d.requests)
+}
+
+func TestRootWithoutTransportIDs(t *testing.T) {
+	d := newFakeDevice()
+	d.services["unroot:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "restarting adbd as non root\n")
+	}
+	d.handleWaitFor("host-serial:serial:wait-for-any-")
+	device := newFakeDeviceClient(d)
+
+	result, err := device.Unroot(context.Background())
+	require.NoError(t, err)
+	d.wait()
+	assert.True(t, result.Restarted)
+	assert.Equal(t, []string{
+		"host:tport:serial:serial",
+		"unroot:",
+		"host-serial:serial:wait-for-any-disconnect",
+		"host-serial:serial:wait-for-any-device",
+	}, d.requests)
+}
+
+func TestRootTransportIDCutShort(t *testing.T) {
+	for _, sent := range []string{"", "\x07\x00\x00"} {
+		d := newFakeDevice()
+		d.services["host:tport:"] = func(conn net.Conn, req string) {
+			io.WriteString(conn, sent)
+		}
+		device := newFakeDeviceClient(d)
+
+		_, err := device.Root(context.Background())
+		assert.True(t, HasErrCode(err, ConnectionResetError), "%q: %v", sent, err)
+		d.wait()
+		assert.Equal(t, []string{"host:tport:serial:serial"}, d.requests)
+	}
+}
+
+func TestRootNotRestarted(t *testing.T) {
+	d := newFakeDevice()
+	d.transportID = 7
+	d.services["root:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "adbd is already running as root\n")
+	}
+	device := newFakeDeviceClient(d)
+
+	result, err := device.Root(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &ServiceResult{Message: "adbd is already running as root"}, result)
+
+	d.services["root:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "adbd cannot run as root in production builds\n")
+	}
+	result, err = device.Root(context.Background())
+	assert.True(t, HasErrCode(err, PermissionDenied))
+	assert.Equal(t, "adbd cannot run as root in production builds", result.Message)
+	d.wait()
+	assert.Equal(t, []string{"root:", "root:"}, d.requests)
+}
+
+func TestTcpipAndUsb(t *testing.T) {
+	d := newFakeDevice()
+	d.transportID = 3
+	d.services["tcpip:5555"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "restarting in TCP mode port: 5555\n")
+	}
+	d.services["usb:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "restarting in USB mode\n")
+	}
+	d.handleWaitFor("host-transport-id:3:wait-for-any-disconnect", "host-serial:serial:wait-for-any-device")
+	device := newFakeDeviceClient(d)
+
+	result, err := device.Tcpip(context.Background(), 5555)
+	require.NoError(t, err)
+	assert.Equal(t, "restarting in TCP mode port: 5555", result.Message)
+
+	result, err = device.Usb(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.Restarted)
+	d.wait()
+
+	assert.Equal(t, []string{
+		"tcpip:5555",
+		"host-transport-id:3:wait-for-any-disconnect",
+		"host-serial:serial:wait-for-any-device",
+		"usb:",
+		"host-transport-id:3:wait-for-any-disconnect",
+	}, d.requests)
+}
+
+func TestReboot(t *testing.T) {
+	for _, test := range []struct {
+		mode  RebootMode
+		waits []string
+	}{
+		{RebootNormal, []string{"host-transport-id:1:wait-for-any-disconnect", "host-serial:serial:wait-for-any-device"}},
+		{RebootRecovery, []string{"host-transport-id:1:wait-for-any-disconnect", "host-serial:serial:wait-for-any-recovery"}},
+		{RebootSideloadAutoReboot, []string{"host-transport-id:1:wait-for-any-disconnect", "host-serial:serial:wait-for-any-sideload"}},
+		{RebootBootloader, []string{"host-transport-id:1:wait-for-any-disconnect"}},
+	} {
+		d := newFakeDevice()
+		d.transportID = 1
+		d.services["reboot:"] = func(conn net.Conn, req string) {}
+		d.handleWaitFor(test.waits...)
+		device := newFakeDeviceClient(d)
+
+		result, err := device.Reboot(context.Background(), test.mode)
+		require.NoError(t, err, test.mode)
+		d.wait()
+		assert.True(t, result.Restarted)
+		assert.Equal(t, append([]string{"reboot:" + string(test.mode)}, test.waits...), d.requests, test.mode)
+	}
+}
+
+func TestVerity(t *testing.T) {
+	d := newFakeDevice()
+	d.services["disable-verity:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "Verity disabled on /system\nNow reboot your device for settings to take effect\n")
+	}
+	d.services["enable-verity:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "verity cannot be enabled/disabled - USER build\n")
+	}
+	device := newFakeDeviceClient(d)
+
+	result, err := device.DisableVerity(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.RebootRequired)
+	assert.False(t, result.Restarted)
+	assert.Equal(t, "Verity disabled on /system\nNow reboot your device for settings to take effect", result.Message)
+
+	_, err = device.EnableVerity(context.Background())
+	assert.True(t, HasErrCode(err, PermissionDenied))
+	assert.Contains(t, ErrorWithCauseChain(err), "USER build")
+	d.wait()
+}
@@ -2,7 +2,7 @@ package adb
 
 import "github.com/basiooo/goadb/internal/errors"
 
-// DeviceState represents one of the 3 possible states adb will report devices.
+// DeviceState represents one of the possible states adb will report devices.
 // A device can be communicated with when it's in StateOnline.
 // A USB device will make the following state transitions:
 //
@@ -20,6 +20,9 @@ const (
 	StateOnline
 	StateAuthorizing
 	StateRecovery
+	StateSideload
+	StateBootloader
+	StateRescue
 )
 
 func (s DeviceState) String() string {
@@ -36,6 +39,12 @@ func (s DeviceState) String() string {
 		return "Authorizing"
 	case StateRecovery:
 		return "Recovery"
+	case StateSideload:
+		return "Sideload"
+	case StateBootloader:
+		return "Bootloader"
+	case StateRescue:
+		return "Rescue"
 	case StateInvalid:
 		return "Invalid"
 	default:
@@ -50,6 +59,9 @@ var deviceStateStrings = map[string]DeviceState{
 	"unauthorized": StateUnauthorized,
 	"authorizing":  StateAuthorizing,
 	"recovery":     StateRecovery,
+	"sideload":     StateSideload,
+	"bootloader":   StateBootloader,
+	"rescue":       StateRescue,
 }
 
 func parseDeviceState(str string) (DeviceState, error) {
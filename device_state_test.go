@@ -18,6 +18,9 @@ func TestParseDeviceState(t *testing.T) {
 		{"offline", StateOffline, "Offline", nil},
 		{"device", StateOnline, "Online", nil},
 		{"unauthorized", StateUnauthorized, "Unauthorized", nil},
+		{"sideload", StateSideload, "Sideload", nil},
+		{"bootloader", StateBootloader, "Bootloader", nil},
+		{"rescue", StateRescue, "Rescue", nil},
 		{"bad", StateInvalid, "Invalid", errors.New(`ParseError: invalid device state: "Invalid"`)},
 	} {
 		state, err := parseDeviceState(test.String)
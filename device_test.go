@@ -118,13 +118,50 @@ func TestPrepareCommandLineCleanArgs(t *testing.T) {
 func TestPrepareCommandLineArgWithWhitespaceQuotes(t *testing.T) {
 	result, err := prepareCommandLine("cmd", "arg with spaces")
 	assert.NoError(t, err)
-	assert.Equal(t, "cmd \"arg with spaces\"", result)
+	assert.Equal(t, "cmd 'arg with spaces'", result)
 }
 
-func TestPrepareCommandLineArgWithDoubleQuoteFails(t *testing.T) {
-	_, err := prepareCommandLine("cmd", "quoted\"arg")
-	assert.Equal(t, errors.ParseError, code(err))
-	assert.Equal(t, "arg at index 0 contains an invalid double quote: quoted\"arg", message(err))
+func TestPrepareCommandLineArgWithDoubleQuote(t *testing.T) {
+	result, err := prepareCommandLine("am", "broadcast", "--es", "json", `{"a":1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `am broadcast --es json '{"a":1}'`, result)
+}
+
+func TestPrepareCommandLineArgWithShellSyntax(t *testing.T) {
+	args := []string{"$HOME", "`id`", "a;b", "*", "x&y", "it's", ""}
+	result, err := prepareCommandLine("echo", args...)
+	assert.NoError(t, err)
+	assert.Equal(t, `echo '$HOME' '`+"`id`"+`' 'a;b' '*' 'x&y' 'it'\''s' ''`, result)
+	assert.Equal(t, "$HOME", args[0], "args must not be modified")
+}
+
+func TestRunCommandQuotesArgs(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"output"},
+	}
+	client := (&Adb{s}).Device(AnyDevice())
+
+	_, err := client.RunCommand("am", "broadcast", "--es", "json", `{"a":1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `shell:am broadcast --es json '{"a":1}'`, s.Requests[1])
+}
+
+func TestRunCommandRaw(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"3\n"},
+	}
+	client := (&Adb{s}).Device(AnyDevice())
+
+	v, err := client.RunCommandRaw("logcat -d | grep -c \"a b\"")
+	assert.NoError(t, err)
+	assert.Equal(t, "host:transport-any", s.Requests[0])
+	assert.Equal(t, `shell:logcat -d | grep -c "a b"`, s.Requests[1])
+	assert.Equal(t, "3\n", v)
+
+	_, err = client.RunCommandRaw(" ")
+	assert.True(t, HasErrCode(err, AssertionError))
 }
 
 func TestRunCommandWithTimeoutSuccess(t *testing.T) {
@@ -304,3 +341,15 @@ func TestDevice_RunShellLoop_Success(t *testing.T) {
 	err := dev.RunShellLoop(ctx, "echo", "hello")
 	assert.NoError(t, err)
 }
+
+func TestEmulatorConsoleNotEmulator(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"0123456789ABCDEF"},
+	}
+	client := (&Adb{s}).Device(AnyUsbDevice())
+
+	_, err := client.EmulatorConsole(context.Background())
+	assert.Equal(t, "host-usb:get-serialno", s.Requests[0])
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+}
@@ -4,9 +4,9 @@ package adb
 
 import "fmt"
 
-const _deviceDescriptorType_name = "DeviceAnyDeviceSerialDeviceUsbDeviceLocal"
+const _deviceDescriptorType_name = "DeviceAnyDeviceSerialDeviceUsbDeviceLocalDeviceTransportID"
 
-var _deviceDescriptorType_index = [...]uint8{0, 9, 21, 30, 41}
+var _deviceDescriptorType_index = [...]uint8{0, 9, 21, 30, 41, 58}
 
 func (i deviceDescriptorType) String() string {
 	if i < 0 || i >= deviceDescriptorType(len(_deviceDescriptorType_index)-1) {
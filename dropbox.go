@@ -0,0 +1,162 @@
+package adb
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// Tags of the dropbox entries the system adds for crashes.
+const (
+	DropBoxAppCrash          = "data_app_crash"
+	DropBoxSystemAppCrash    = "system_app_crash"
+	DropBoxAppANR            = "data_app_anr"
+	DropBoxSystemAppANR      = "system_app_anr"
+	DropBoxAppNativeCrash    = "data_app_native_crash"
+	DropBoxSystemServerCrash = "system_server_crash"
+	DropBoxTombstone         = "SYSTEM_TOMBSTONE"
+)
+
+// DropBoxEntry is an entry of the system's dropbox, where crashes, ANRs and other events are
+// recorded.
+type DropBoxEntry struct {
+	Tag  string
+	Time time.Time
+
+	// Text is true for text entries, which are the only ones whose Contents are printed.
+	Text       bool
+	Compressed bool
+	// Size is the size of the stored entry, compressed if Compressed is true.
+	Size int64
+	// Lost is true if the entry's contents were deleted to save space.
+	Lost bool
+
+	Contents string
+}
+
+const dropBoxSeparator = "========================================"
+
+var dropBoxHeaderRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) (.+?)(?: \((.*)\))?$`)
+
+/*
+DropBoxEntries returns the dropbox entries added at or after since, oldest first. If tags are
+given, only entries with one of them are returned.
+
+Corresponds to the command:
+
+	adb shell dumpsys dropbox --print [tag]
+*/
+func (c *Device) DropBoxEntries(since time.Time, tags ...string) ([]DropBoxEntry, error) {
+	args := []string{"--print"}
+	if len(tags) == 1 {
+		// dumpsys only returns entries that match all of its arguments.
+		args = append(args, tags[0])
+	}
+	// The entries' times are printed in the device's time zone.
+	cmdline := "date +%z; " + shellCommandLine("dumpsys", append([]string{"dropbox"}, args...)...)
+	result, err := c.runShellCommand(cmdline)
+	if err == nil {
+		err = commandError(cmdline, result)
+	}
+	if err != nil {
+		return nil, wrapClientError(err, c, "DropBoxEntries")
+	}
+
+	zone, output, _ := strings.Cut(string(result.Stdout), "\n")
+	loc, err := parseTimeZoneOffset(strings.TrimSpace(zone))
+	if err != nil {
+		return nil, wrapClientError(err, c, "DropBoxEntries")
+	}
+	entries, err := parseDropBox(output, loc)
+	if err != nil {
+		return nil, wrapClientError(err, c, "DropBoxEntries")
+	}
+
+	filtered := []DropBoxEntry{}
+	for _, entry := range entries {
+		if entry.Time.Before(since) || (len(tags) > 0 && !slices.Contains(tags, entry.Tag)) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered, nil
+}
+
+// parseTimeZoneOffset parses the output of date +%z, e.g. "-0800".
+func parseTimeZoneOffset(offset string) (*time.Location, error) {
+	t, err := time.Parse("-0700", offset)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid time zone offset %q", offset)
+	}
+	_, seconds := t.Zone()
+	return time.FixedZone(offset, seconds), nil
+}
+
+/*
+parseDropBox parses the output of dumpsys dropbox --print. Each entry starts with a separator
+and a header with its time, tag and format, followed by its contents if it's text:
+
+	========================================
+	2024-01-02 10:11:12 data_app_crash (text, 1234 bytes)
+	Process: com.example.app
+	...
+	========================================
+	2024-01-02 10:11:13 SYSTEM_TOMBSTONE (compressed data, 5678 bytes)
+*/
+func parseDropBox(output string, loc *time.Location) ([]DropBoxEntry, error) {
+	var entries []DropBoxEntry
+	var contents []string
+	var entry *DropBoxEntry
+	finish := func() {
+		if entry != nil {
+			entry.Contents = strings.TrimRight(strings.Join(contents, "\n"), "\n")
+			entries = append(entries, *entry)
+		}
+		entry, contents = nil, nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		if lines[i] != dropBoxSeparator {
+			if entry != nil {
+				contents = append(contents, lines[i])
+			}
+			continue
+		}
+
+		finish()
+		if i+1 == len(lines) {
+			break
+		}
+		i++
+		m := dropBoxHeaderRegex.FindStringSubmatch(lines[i])
+		if m == nil {
+			return nil, errors.Errorf(errors.ParseError, "invalid dropbox entry header %q", lines[i])
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", m[1], loc)
+		if err != nil {
+			return nil, errors.WrapErrorf(err, errors.ParseError, "invalid dropbox entry time %q", m[1])
+		}
+		entry = &DropBoxEntry{Tag: m[2], Time: t}
+		parseDropBoxFormat(entry, m[3])
+	}
+	finish()
+	return entries, nil
+}
+
+// parseDropBoxFormat parses the format in an entry's header, e.g. "compressed text, 1234 bytes"
+// or "contents lost".
+func parseDropBoxFormat(entry *DropBoxEntry, format string) {
+	kind, size, ok := strings.Cut(format, ", ")
+	if !ok {
+		entry.Lost = format == "contents lost" || format == "no file"
+		return
+	}
+	entry.Compressed = strings.HasPrefix(kind, "compressed ")
+	entry.Text = strings.HasSuffix(kind, "text")
+	entry.Size, _ = strconv.ParseInt(strings.TrimSuffix(size, " bytes"), 10, 64)
+}
@@ -0,0 +1,70 @@
+package adb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDropBox = `Drop box contents: 3 entries
+Max entries: 1000
+
+========================================
+2024-01-02 10:11:12 data_app_crash (text, 120 bytes)
+Process: com.example.app
+java.lang.IllegalStateException: boom
+	at com.example.app.Main.onCreate(Main.java:12)
+
+========================================
+2024-01-02 10:11:13.250 SYSTEM_TOMBSTONE (compressed data, 5678 bytes)
+========================================
+2024-01-02 10:15:00 data_app_anr (contents lost)
+`
+
+func TestDropBoxEntries(t *testing.T) {
+	var cmdlines []string
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		cmdlines = append(cmdlines, cmdline)
+		return "-0800\n" + testDropBox, "", 0
+	})
+	zone := time.FixedZone("-0800", -8*60*60)
+
+	entries, err := device.DropBoxEntries(time.Date(2024, 1, 2, 10, 11, 13, 0, zone))
+	require.NoError(t, err)
+	assert.Equal(t, []DropBoxEntry{
+		{
+			Tag:        DropBoxTombstone,
+			Time:       time.Date(2024, 1, 2, 10, 11, 13, 250e6, zone),
+			Compressed: true,
+			Size:       5678,
+		},
+		{Tag: DropBoxAppANR, Time: time.Date(2024, 1, 2, 10, 15, 0, 0, zone), Lost: true},
+	}, entries)
+	assert.Equal(t, "date +%z; dumpsys dropbox --print", cmdlines[0])
+
+	entries, err = device.DropBoxEntries(time.Time{}, DropBoxAppCrash)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, DropBoxEntry{
+		Tag:      DropBoxAppCrash,
+		Time:     time.Date(2024, 1, 2, 10, 11, 12, 0, zone),
+		Text:     true,
+		Size:     120,
+		Contents: "Process: com.example.app\njava.lang.IllegalStateException: boom\n\tat com.example.app.Main.onCreate(Main.java:12)",
+	}, entries[0])
+	assert.Equal(t, "date +%z; dumpsys dropbox --print data_app_crash", cmdlines[1])
+
+	entries, err = device.DropBoxEntries(time.Time{}, DropBoxAppCrash, DropBoxAppANR)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestParseDropBoxInvalid(t *testing.T) {
+	_, err := parseDropBox(dropBoxSeparator+"\nnot a header\n", time.UTC)
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+
+	_, err = parseTimeZoneOffset("PST")
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+}
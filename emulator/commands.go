@@ -0,0 +1,227 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// NetworkSpeed is a network speed the emulator can simulate.
+type NetworkSpeed string
+
+const (
+	NetworkSpeedGSM   NetworkSpeed = "gsm"
+	NetworkSpeedHSCSD NetworkSpeed = "hscsd"
+	NetworkSpeedGPRS  NetworkSpeed = "gprs"
+	NetworkSpeedEDGE  NetworkSpeed = "edge"
+	NetworkSpeedUMTS  NetworkSpeed = "umts"
+	NetworkSpeedHSDPA NetworkSpeed = "hsdpa"
+	NetworkSpeedLTE   NetworkSpeed = "lte"
+	NetworkSpeed5G    NetworkSpeed = "5g"
+	// NetworkSpeedFull doesn't limit the speed.
+	NetworkSpeedFull NetworkSpeed = "full"
+)
+
+// NetworkDelay is a network latency the emulator can simulate.
+type NetworkDelay string
+
+const (
+	NetworkDelayGPRS NetworkDelay = "gprs"
+	NetworkDelayEDGE NetworkDelay = "edge"
+	NetworkDelayUMTS NetworkDelay = "umts"
+	// NetworkDelayNone doesn't add any latency.
+	NetworkDelayNone NetworkDelay = "none"
+)
+
+// Snapshot is a saved state of the emulator.
+type Snapshot struct {
+	ID   string
+	Name string
+	// Size is the size of the saved memory, as printed by the emulator, e.g. "224M".
+	Size string
+}
+
+/*
+GeoFix sets the emulator's GPS location. altitude is in meters, and is optional.
+
+Corresponds to the console command:
+
+	geo fix <longitude> <latitude> [<altitude>]
+*/
+func (c *Console) GeoFix(ctx context.Context, longitude, latitude float64, altitude ...float64) error {
+	cmd := "geo fix " + formatFloat(longitude) + " " + formatFloat(latitude)
+	if len(altitude) > 0 {
+		cmd += " " + formatFloat(altitude[0])
+	}
+	_, err := c.Command(ctx, cmd)
+	return err
+}
+
+/*
+SendSMS simulates an incoming SMS from the phone number from.
+
+Corresponds to the console command:
+
+	sms send <from> <text>
+*/
+func (c *Console) SendSMS(ctx context.Context, from, text string) error {
+	if from == "" || strings.ContainsAny(from, " \t") {
+		return errors.AssertionErrorf("invalid phone number %q", from)
+	}
+	_, err := c.Command(ctx, "sms send "+from+" "+text)
+	return err
+}
+
+/*
+SetPowerCapacity sets the battery level, from 0 to 100.
+
+Corresponds to the console command:
+
+	power capacity <percent>
+*/
+func (c *Console) SetPowerCapacity(ctx context.Context, percent int) error {
+	if percent < 0 || percent > 100 {
+		return errors.AssertionErrorf("invalid battery level %d", percent)
+	}
+	_, err := c.Command(ctx, "power capacity "+strconv.Itoa(percent))
+	return err
+}
+
+/*
+SetNetworkSpeed limits the emulator's network speed.
+
+Corresponds to the console command:
+
+	network speed <speed>
+*/
+func (c *Console) SetNetworkSpeed(ctx context.Context, speed NetworkSpeed) error {
+	_, err := c.Command(ctx, "network speed "+string(speed))
+	return err
+}
+
+/*
+SetNetworkDelay adds latency to the emulator's network.
+
+Corresponds to the console command:
+
+	network delay <delay>
+*/
+func (c *Console) SetNetworkDelay(ctx context.Context, delay NetworkDelay) error {
+	_, err := c.Command(ctx, "network delay "+string(delay))
+	return err
+}
+
+/*
+Rotate rotates the emulator 90 degrees counterclockwise.
+
+Corresponds to the console command:
+
+	rotate
+*/
+func (c *Console) Rotate(ctx context.Context) error {
+	_, err := c.Command(ctx, "rotate")
+	return err
+}
+
+/*
+SaveSnapshot saves the emulator's state as the snapshot name, replacing any snapshot with the
+same name.
+
+Corresponds to the console command:
+
+	avd snapshot save <name>
+*/
+func (c *Console) SaveSnapshot(ctx context.Context, name string) error {
+	return c.snapshotCommand(ctx, "save", name)
+}
+
+/*
+LoadSnapshot restores the emulator's state from the snapshot name.
+
+Corresponds to the console command:
+
+	avd snapshot load <name>
+*/
+func (c *Console) LoadSnapshot(ctx context.Context, name string) error {
+	return c.snapshotCommand(ctx, "load", name)
+}
+
+/*
+DeleteSnapshot deletes the snapshot name.
+
+Corresponds to the console command:
+
+	avd snapshot delete <name>
+*/
+func (c *Console) DeleteSnapshot(ctx context.Context, name string) error {
+	return c.snapshotCommand(ctx, "delete", name)
+}
+
+func (c *Console) snapshotCommand(ctx context.Context, action, name string) error {
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return errors.AssertionErrorf("invalid snapshot name %q", name)
+	}
+	_, err := c.Command(ctx, fmt.Sprintf("avd snapshot %s %s", action, name))
+	return err
+}
+
+/*
+ListSnapshots returns the emulator's snapshots.
+
+Corresponds to the console command:
+
+	avd snapshot list
+*/
+func (c *Console) ListSnapshots(ctx context.Context) ([]Snapshot, error) {
+	output, err := c.Command(ctx, "avd snapshot list")
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshots(output), nil
+}
+
+/*
+parseSnapshots parses the output of avd snapshot list, a table like:
+
+	List of snapshots present on all disks:
+	ID        TAG                 VM SIZE                DATE       VM CLOCK
+	--        default_boot           224M 2024-01-02 10:11:12   00:01:02.345
+*/
+func parseSnapshots(output string) []Snapshot {
+	var snapshots []Snapshot
+	inTable := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "ID" {
+			// Without any snapshots, the emulator prints a message instead of the table.
+			inTable = true
+			continue
+		}
+		if inTable && len(fields) >= 3 {
+			snapshots = append(snapshots, Snapshot{ID: fields[0], Name: fields[1], Size: fields[2]})
+		}
+	}
+	return snapshots
+}
+
+/*
+Kill stops the emulator. The console is closed afterwards.
+
+Corresponds to the console command:
+
+	kill
+*/
+func (c *Console) Kill(ctx context.Context) error {
+	_, err := c.Command(ctx, "kill")
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,217 @@
+/*
+Package emulator controls Android emulators through their console.
+
+Each emulator listens for console connections on localhost, on the even port its adb serial
+is named after: emulator-5554 has its console on port 5554. Recent emulators require the
+auth token from ~/.emulator_console_auth_token before accepting commands.
+
+	console, err := emulator.DialSerial(ctx, "emulator-5554")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer console.Close()
+	err = console.GeoFix(ctx, -122.084, 37.422)
+
+adb.Device.EmulatorConsole opens the console of an emulator known to the adb server.
+*/
+package emulator
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+const (
+	// DefaultPort is the console port of the first emulator started.
+	DefaultPort = 5554
+
+	// AuthTokenFileName is the name of the auth token file in the home directory.
+	AuthTokenFileName = ".emulator_console_auth_token"
+
+	serialPrefix = "emulator-"
+)
+
+// AuthTokenPath returns the path of the file the emulator reads its console auth token from.
+func AuthTokenPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.WrapErrorf(err, errors.LocalFileError, "can't find the home directory")
+	}
+	return filepath.Join(home, AuthTokenFileName), nil
+}
+
+// ReadAuthToken reads the console auth token from AuthTokenPath.
+func ReadAuthToken() (string, error) {
+	path, err := AuthTokenPath()
+	if err != nil {
+		return "", err
+	}
+	token, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.WrapErrorf(err, errors.LocalFileError, "error reading console auth token %s", path)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// PortFromSerial returns the console port of the emulator with the adb serial serial, e.g.
+// 5554 for "emulator-5554".
+func PortFromSerial(serial string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimPrefix(serial, serialPrefix))
+	if !strings.HasPrefix(serial, serialPrefix) || err != nil || port <= 0 || port > 65535 {
+		return 0, errors.Errorf(errors.AssertionError, "%s is not an emulator serial", serial)
+	}
+	return port, nil
+}
+
+// Console is a connection to an emulator's console. Commands can be sent concurrently, and
+// are run one at a time.
+type Console struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+
+	// Banner is the text the console sent when the connection was opened.
+	Banner string
+}
+
+// DialSerial connects to the console of the emulator with the adb serial serial, e.g.
+// "emulator-5554", authenticating with the token from ReadAuthToken if required.
+func DialSerial(ctx context.Context, serial string) (*Console, error) {
+	port, err := PortFromSerial(serial)
+	if err != nil {
+		return nil, err
+	}
+	return Dial(ctx, net.JoinHostPort("localhost", strconv.Itoa(port)), "")
+}
+
+/*
+Dial connects to the console at addr. If the console requires authentication, it
+authenticates with token, or with the token from ReadAuthToken if token is empty.
+
+A wrong token returns an AuthenticationFailed error.
+*/
+func Dial(ctx context.Context, addr, token string) (*Console, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, errors.WrapErrorf(ctxErr, errors.CommandCanceled, "dial canceled")
+		}
+		return nil, errors.WrapErrorf(err, errors.ServerNotAvailable, "error connecting to emulator console %s", addr)
+	}
+
+	c := &Console{conn: conn, r: bufio.NewReader(conn)}
+	if err := c.start(ctx, token); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Console) start(ctx context.Context, token string) error {
+	banner, err := c.roundTrip(ctx, "")
+	if err != nil {
+		return err
+	}
+	c.Banner = banner
+	if !strings.Contains(banner, "Authentication required") {
+		return nil
+	}
+
+	if token == "" {
+		if token, err = ReadAuthToken(); err != nil {
+			return err
+		}
+	}
+	if _, err := c.roundTrip(ctx, "auth "+token); err != nil {
+		if errors.HasErrCode(err, errors.CommandFailed) {
+			return errors.WrapErrorf(err, errors.AuthenticationFailed, "emulator console authentication failed")
+		}
+		return err
+	}
+	return nil
+}
+
+/*
+Command runs a console command, e.g. "help" or "sensor get acceleration", and returns its
+output without the final OK line. If the console replies with a KO line, a CommandFailed
+error with the console's message is returned.
+
+If ctx is done before the reply is read, the console is closed, since its replies can't be
+matched to commands anymore.
+*/
+func (c *Console) Command(ctx context.Context, cmd string) (string, error) {
+	if cmd == "" || strings.ContainsAny(cmd, "\r\n") {
+		return "", errors.AssertionErrorf("invalid console command %q", cmd)
+	}
+	return c.roundTrip(ctx, cmd)
+}
+
+// roundTrip sends cmd, unless it's empty, and reads the reply.
+func (c *Console) roundTrip(ctx context.Context, cmd string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", errors.WrapErrorf(err, errors.CommandCanceled, "command canceled")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.Close()
+	})
+	defer stop()
+
+	output, err := c.exchange(cmd)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return "", errors.WrapErrorf(ctxErr, errors.CommandCanceled, "command canceled")
+	}
+	return output, err
+}
+
+// exchange sends cmd and reads lines until one that starts with OK or KO.
+func (c *Console) exchange(cmd string) (string, error) {
+	if cmd != "" {
+		if _, err := c.conn.Write([]byte(cmd + "\r\n")); err != nil {
+			return "", errors.WrapErrorf(err, errors.NetworkError, "error sending console command")
+		}
+	}
+
+	var output []string
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			if line != "" || len(output) > 0 || cmd != "kill" {
+				return "", errors.WrapErrorf(err, errors.ConnectionResetError, "console closed the connection")
+			}
+			return "", nil
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "OK" || strings.HasPrefix(line, "OK:"):
+			return strings.Join(output, "\n"), nil
+		case strings.HasPrefix(line, "KO"):
+			msg := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "KO"), ":"))
+			return "", errors.Errorf(errors.CommandFailed, "console command %q failed: %s", redact(cmd), msg)
+		}
+		output = append(output, line)
+	}
+}
+
+// redact removes the token from auth commands, so that it isn't included in errors.
+func redact(cmd string) string {
+	if strings.HasPrefix(cmd, "auth ") {
+		return "auth"
+	}
+	return cmd
+}
+
+func (c *Console) Close() error {
+	return c.conn.Close()
+}
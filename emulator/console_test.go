@@ -0,0 +1,263 @@
+package emulator
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken  = "s3cr3t"
+	authBanner = "Android Console: Authentication required\r\n" +
+		"Android Console: type 'auth <auth_token>' to authenticate\r\n" +
+		"Android Console: you can find your <auth_token> in\r\n" +
+		"'/home/user/.emulator_console_auth_token'\r\n" +
+		"OK\r\n"
+	openBanner = "Android Console: type 'help' for a list of commands\r\nOK\r\n"
+)
+
+// fakeConsole stands in for an emulator console. replies maps commands to their reply,
+// including the final OK or KO line. Unknown commands get a KO.
+type fakeConsole struct {
+	banner  string
+	replies map[string]string
+
+	commands chan string
+}
+
+func (f *fakeConsole) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.Write([]byte(f.banner))
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		f.commands <- cmd
+
+		switch {
+		case cmd == "auth "+testToken:
+			conn.Write([]byte("Android Console: type 'help' for a list of commands\r\nOK\r\n"))
+		case strings.HasPrefix(cmd, "auth "):
+			conn.Write([]byte("KO: authentication token does not match ~/.emulator_console_auth_token\r\n"))
+		case cmd == "kill":
+			conn.Write([]byte("OK: killing emulator, bye bye\r\n"))
+			return
+		case cmd == "hang":
+		default:
+			reply, ok := f.replies[cmd]
+			if !ok {
+				reply = "KO: unknown command, try 'help'\r\n"
+			}
+			conn.Write([]byte(reply))
+		}
+	}
+}
+
+// startConsole serves connections with f on a local port.
+func startConsole(t *testing.T, f *fakeConsole) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	f.commands = make(chan string, 100)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func nextCommand(t *testing.T, f *fakeConsole) string {
+	select {
+	case cmd := <-f.commands:
+		return cmd
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a command")
+		return ""
+	}
+}
+
+func TestDialAuthenticates(t *testing.T) {
+	f := &fakeConsole{banner: authBanner}
+	addr := startConsole(t, f)
+
+	console, err := Dial(context.Background(), addr, testToken)
+	require.NoError(t, err)
+	defer console.Close()
+
+	assert.Contains(t, console.Banner, "Authentication required")
+	assert.Equal(t, "auth "+testToken, nextCommand(t, f))
+}
+
+func TestDialReadsAuthToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.WriteFile(filepath.Join(home, AuthTokenFileName), []byte(testToken+"\n"), 0600))
+
+	f := &fakeConsole{banner: authBanner}
+	console, err := Dial(context.Background(), startConsole(t, f), "")
+	require.NoError(t, err)
+	defer console.Close()
+	assert.Equal(t, "auth "+testToken, nextCommand(t, f))
+}
+
+func TestDialWrongToken(t *testing.T) {
+	addr := startConsole(t, &fakeConsole{banner: authBanner})
+
+	_, err := Dial(context.Background(), addr, "wrong")
+	assert.True(t, errors.HasErrCode(err, errors.AuthenticationFailed), "%v", err)
+	assert.NotContains(t, errors.ErrorWithCauseChain(err), "wrong")
+}
+
+func TestDialWithoutAuth(t *testing.T) {
+	f := &fakeConsole{banner: openBanner}
+	console, err := Dial(context.Background(), startConsole(t, f), "")
+	require.NoError(t, err)
+	defer console.Close()
+	assert.Equal(t, "Android Console: type 'help' for a list of commands", console.Banner)
+}
+
+func TestDialUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = Dial(context.Background(), addr, testToken)
+	assert.True(t, errors.HasErrCode(err, errors.ServerNotAvailable), "%v", err)
+}
+
+func newTestConsole(t *testing.T, replies map[string]string) (*fakeConsole, *Console) {
+	f := &fakeConsole{banner: openBanner, replies: replies}
+	console, err := Dial(context.Background(), startConsole(t, f), "")
+	require.NoError(t, err)
+	t.Cleanup(func() { console.Close() })
+	return f, console
+}
+
+func TestCommand(t *testing.T) {
+	_, console := newTestConsole(t, map[string]string{
+		"sensor get acceleration": "acceleration = 0:9.77622:0.812349\r\nOK\r\n",
+		"rotate":                  "OK\r\n",
+	})
+
+	output, err := console.Command(context.Background(), "sensor get acceleration")
+	require.NoError(t, err)
+	assert.Equal(t, "acceleration = 0:9.77622:0.812349", output)
+
+	output, err = console.Command(context.Background(), "rotate")
+	require.NoError(t, err)
+	assert.Empty(t, output)
+
+	_, err = console.Command(context.Background(), "bogus")
+	assert.True(t, errors.HasErrCode(err, errors.CommandFailed), "%v", err)
+	assert.Contains(t, err.Error(), "unknown command, try 'help'")
+
+	_, err = console.Command(context.Background(), "rotate\r\nkill")
+	assert.True(t, errors.HasErrCode(err, errors.AssertionError), "%v", err)
+	_, err = console.Command(context.Background(), "")
+	assert.True(t, errors.HasErrCode(err, errors.AssertionError), "%v", err)
+}
+
+func TestCommandCanceled(t *testing.T) {
+	_, console := newTestConsole(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := console.Command(ctx, "hang")
+	assert.True(t, errors.HasErrCode(err, errors.CommandCanceled), "%v", err)
+}
+
+func TestCommands(t *testing.T) {
+	f, console := newTestConsole(t, map[string]string{
+		"geo fix -122.084 37.422":          "OK\r\n",
+		"geo fix -122.084 37.422 10.5":     "OK\r\n",
+		"sms send 5551234 hello there":     "OK\r\n",
+		"power capacity 42":                "OK\r\n",
+		"network speed lte":                "OK\r\n",
+		"network delay none":               "OK\r\n",
+		"rotate":                           "OK\r\n",
+		"avd snapshot save clean":          "OK\r\n",
+		"avd snapshot load clean":          "OK\r\n",
+		"avd snapshot delete clean":        "OK\r\n",
+		"avd snapshot load does-not-exist": "KO: 'does-not-exist' snapshot not found\r\n",
+	})
+	ctx := context.Background()
+
+	require.NoError(t, console.GeoFix(ctx, -122.084, 37.422))
+	require.NoError(t, console.GeoFix(ctx, -122.084, 37.422, 10.5))
+	require.NoError(t, console.SendSMS(ctx, "5551234", "hello there"))
+	require.NoError(t, console.SetPowerCapacity(ctx, 42))
+	require.NoError(t, console.SetNetworkSpeed(ctx, NetworkSpeedLTE))
+	require.NoError(t, console.SetNetworkDelay(ctx, NetworkDelayNone))
+	require.NoError(t, console.Rotate(ctx))
+	require.NoError(t, console.SaveSnapshot(ctx, "clean"))
+	require.NoError(t, console.LoadSnapshot(ctx, "clean"))
+	require.NoError(t, console.DeleteSnapshot(ctx, "clean"))
+	for range 10 {
+		nextCommand(t, f)
+	}
+
+	err := console.LoadSnapshot(ctx, "does-not-exist")
+	assert.True(t, errors.HasErrCode(err, errors.CommandFailed), "%v", err)
+
+	assert.True(t, errors.HasErrCode(console.SetPowerCapacity(ctx, 101), errors.AssertionError))
+	assert.True(t, errors.HasErrCode(console.SendSMS(ctx, "555 1234", "hi"), errors.AssertionError))
+	assert.True(t, errors.HasErrCode(console.SaveSnapshot(ctx, ""), errors.AssertionError))
+}
+
+func TestListSnapshots(t *testing.T) {
+	_, console := newTestConsole(t, map[string]string{
+		"avd snapshot list": "List of snapshots present on all disks:\r\n" +
+			"ID        TAG                 VM SIZE                DATE       VM CLOCK\r\n" +
+			"--        default_boot           224M 2024-01-02 10:11:12   00:01:02.345\r\n" +
+			"--        clean                  231M 2024-01-03 09:00:00   00:10:00.000\r\n" +
+			"OK\r\n",
+	})
+
+	snapshots, err := console.ListSnapshots(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Snapshot{
+		{ID: "--", Name: "default_boot", Size: "224M"},
+		{ID: "--", Name: "clean", Size: "231M"},
+	}, snapshots)
+}
+
+func TestParseSnapshotsNone(t *testing.T) {
+	assert.Empty(t, parseSnapshots("There is no snapshot available."))
+}
+
+func TestKill(t *testing.T) {
+	f, console := newTestConsole(t, nil)
+
+	require.NoError(t, console.Kill(context.Background()))
+	assert.Equal(t, "kill", nextCommand(t, f))
+}
+
+func TestPortFromSerial(t *testing.T) {
+	port, err := PortFromSerial("emulator-5556")
+	require.NoError(t, err)
+	assert.Equal(t, 5556, port)
+
+	for _, serial := range []string{"0123456789ABCDEF", "emulator-", "emulator-abc", "192.168.1.2:5555"} {
+		_, err := PortFromSerial(serial)
+		assert.True(t, errors.HasErrCode(err, errors.AssertionError), serial)
+	}
+}
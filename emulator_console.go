@@ -0,0 +1,23 @@
+package adb
+
+import (
+	"context"
+
+	"github.com/basiooo/goadb/emulator"
+)
+
+/*
+EmulatorConsole connects to the console of the device, which must be an emulator running on
+this host. The console authenticates with the token from emulator.ReadAuthToken if required.
+
+The device's serial is asked to the server, so that devices selected with AnyLocalDevice work.
+Returns an AssertionError if the device isn't an emulator.
+*/
+func (c *Device) EmulatorConsole(ctx context.Context) (*emulator.Console, error) {
+	serial, err := c.Serial()
+	if err != nil {
+		return nil, err
+	}
+	console, err := emulator.DialSerial(ctx, serial)
+	return console, wrapClientError(err, c, "EmulatorConsole")
+}
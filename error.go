@@ -21,6 +21,18 @@ const (
 	FileNoExistError = ErrCode(errors.FileNoExistError)
 	// Command execution timed out.
 	CommandTimeout = ErrCode(errors.CommandTimeout)
+	// Command execution was canceled.
+	CommandCanceled = ErrCode(errors.CommandCanceled)
+	// Reading or writing a file on the host failed.
+	LocalFileError = ErrCode(errors.LocalFileError)
+	// Tried to create a file on the device that already exists.
+	FileExistError = ErrCode(errors.FileExistError)
+	// The device refused an operation because of file permissions or a read-only filesystem.
+	PermissionDenied = ErrCode(errors.PermissionDenied)
+	// A command run on the device exited with a non-zero status.
+	CommandFailed = ErrCode(errors.CommandFailed)
+	// Pairing with or authenticating to the device failed, e.g. because of a wrong pairing code.
+	AuthenticationFailed = ErrCode(errors.AuthenticationFailed)
 )
 
 // HasErrCode returns true if err is an *errors.Err and err.Code == code.
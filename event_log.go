@@ -0,0 +1,342 @@
+package adb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// EventLogTagsPath is where devices describe the tags used in the events buffer.
+const EventLogTagsPath = "/system/etc/event-log-tags"
+
+// EventValueType is the type of a field of an event, as given in event-log-tags.
+type EventValueType int
+
+const (
+	EventTypeInt EventValueType = iota + 1
+	EventTypeLong
+	EventTypeString
+	EventTypeList
+	EventTypeFloat
+)
+
+// EventField describes one of the values logged with an event.
+type EventField struct {
+	Name string
+	Type EventValueType
+	// Unit is the unit code from event-log-tags, e.g. 3 for milliseconds, or 0 if it's not given.
+	Unit int
+}
+
+// EventTag describes an event tag from event-log-tags.
+type EventTag struct {
+	Number int
+	Name   string
+	// Fields describes the values logged with the event. It's empty if the tag doesn't
+	// describe them.
+	Fields []EventField
+}
+
+// EventTags maps event tag numbers to their descriptions.
+type EventTags map[int]*EventTag
+
+/*
+ParseEventLogTags parses the contents of an event-log-tags file. Each line describes a tag:
+
+	30008 am_anr (User|1|5),(pid|1|5),(Package Name|3),(Flags|1|5),(reason|3)
+
+The number, name and description are separated by spaces or tabs. Blank lines and comments,
+starting with #, are skipped, as are invalid lines, so that one tag the parser doesn't
+understand doesn't hide the rest. If there are any, the first is logged, once per file.
+*/
+func ParseEventLogTags(r io.Reader) (EventTags, error) {
+	tags := EventTags{}
+	invalid, firstInvalid := 0, 0
+	var firstErr error
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tag, err := parseEventLogTag(line)
+		if err != nil {
+			if invalid == 0 {
+				firstInvalid, firstErr = lineNum, err
+			}
+			invalid++
+			continue
+		}
+		tags[tag.Number] = tag
+	}
+	if invalid > 0 {
+		log.Printf("[EventLogTags] ignoring %d invalid lines, the first at line %d: %s", invalid, firstInvalid, firstErr)
+	}
+	if err := scanner.Err(); err != nil {
+		if _, ok := err.(*errors.Err); ok {
+			return nil, err
+		}
+		return nil, errors.WrapErrorf(err, errors.NetworkError, "error reading event-log-tags")
+	}
+	return tags, nil
+}
+
+func parseEventLogTag(line string) (*EventTag, error) {
+	numberStr, rest := cutSpace(line)
+	number, err := strconv.Atoi(numberStr)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid tag number %q", numberStr)
+	}
+	name, desc := cutSpace(rest)
+	if name == "" {
+		return nil, errors.Errorf(errors.ParseError, "tag %d has no name", number)
+	}
+
+	tag := &EventTag{Number: number, Name: name}
+	desc = strings.TrimSpace(desc)
+	for desc != "" {
+		if !strings.HasPrefix(desc, "(") {
+			return nil, errors.Errorf(errors.ParseError, "invalid field description %q", desc)
+		}
+		end := strings.Index(desc, ")")
+		if end < 0 {
+			return nil, errors.Errorf(errors.ParseError, "unterminated field description %q", desc)
+		}
+		field, err := parseEventField(desc[1:end])
+		if err != nil {
+			return nil, err
+		}
+		tag.Fields = append(tag.Fields, field)
+		desc = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(desc[end+1:]), ","))
+	}
+	return tag, nil
+}
+
+// cutSpace splits s around its first run of spaces or tabs.
+func cutSpace(s string) (before, after string) {
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimLeft(s[i:], " \t")
+}
+
+// parseEventField parses "name|type|unit", where unit is optional.
+func parseEventField(desc string) (EventField, error) {
+	parts := strings.Split(desc, "|")
+	if len(parts) < 2 || len(parts) > 3 {
+		return EventField{}, errors.Errorf(errors.ParseError, "invalid field description %q", desc)
+	}
+	field := EventField{Name: parts[0]}
+	typ, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return EventField{}, errors.WrapErrorf(err, errors.ParseError, "invalid type in field description %q", desc)
+	}
+	field.Type = EventValueType(typ)
+	if len(parts) == 3 {
+		if field.Unit, err = strconv.Atoi(parts[2]); err != nil {
+			return EventField{}, errors.WrapErrorf(err, errors.ParseError, "invalid unit in field description %q", desc)
+		}
+	}
+	return field, nil
+}
+
+// EventLogTags reads and parses the device's event-log-tags file.
+func (c *Device) EventLogTags() (EventTags, error) {
+	r, err := c.OpenRead(EventLogTagsPath)
+	if err != nil {
+		return nil, wrapClientError(err, c, "EventLogTags")
+	}
+	defer r.Close()
+
+	tags, err := ParseEventLogTags(r)
+	return tags, wrapClientError(err, c, "EventLogTags")
+}
+
+/*
+Event is an entry from the events buffer.
+
+Value holds the decoded payload, as an int32, int64, float32, string, or a []any of those
+values for lists. Most events with more than one value log them as a list.
+*/
+type Event struct {
+	TagNumber int
+	// Tag describes the event, or is nil if the tag isn't in event-log-tags.
+	Tag   *EventTag
+	Value any
+}
+
+// Name returns the name of the event's tag, e.g. "am_anr", or its number if the tag is unknown.
+func (e *Event) Name() string {
+	if e.Tag == nil {
+		return strconv.Itoa(e.TagNumber)
+	}
+	return e.Tag.Name
+}
+
+// Field returns the value of the field named name in the event's tag description.
+// Returns false if the tag doesn't describe such a field or the event is missing its value.
+func (e *Event) Field(name string) (any, bool) {
+	if e.Tag == nil {
+		return nil, false
+	}
+	values, isList := e.Value.([]any)
+	if !isList || (len(e.Tag.Fields) == 1 && e.Tag.Fields[0].Type == EventTypeList) {
+		values = []any{e.Value}
+	}
+	for i, field := range e.Tag.Fields {
+		if field.Name == name && i < len(values) {
+			return values[i], true
+		}
+	}
+	return nil, false
+}
+
+/*
+EventDecoder decodes entries from the events buffer, using the device's event-log-tags file
+to name them. The file is read the first time it's needed.
+
+Eg.
+
+	decoder := device.NewEventDecoder()
+	logcat, err := device.Logcat(ctx, adb.LogcatOptions{Buffers: []adb.LogBuffer{adb.LogBufferEvents}})
+	...
+	for {
+		entry, err := logcat.Next()
+		...
+		event, err := decoder.Decode(entry)
+		...
+		if event.Name() == "am_proc_start" {
+			pid, _ := event.Field("PID")
+		}
+	}
+*/
+type EventDecoder struct {
+	device *Device
+
+	once sync.Once
+	tags EventTags
+	err  error
+}
+
+func (c *Device) NewEventDecoder() *EventDecoder {
+	return &EventDecoder{device: c}
+}
+
+// Tags returns the device's event tags, reading them if they haven't been yet.
+func (d *EventDecoder) Tags() (EventTags, error) {
+	d.once.Do(func() {
+		d.tags, d.err = d.device.EventLogTags()
+	})
+	return d.tags, d.err
+}
+
+// Decode decodes the payload of entry, which must be from the events buffer.
+func (d *EventDecoder) Decode(entry *LogEntry) (*Event, error) {
+	tags, err := d.Tags()
+	if err != nil {
+		return nil, err
+	}
+	event, err := DecodeEvent(entry.Payload)
+	if err != nil {
+		return nil, err
+	}
+	event.Tag = tags[event.TagNumber]
+	return event, nil
+}
+
+// Types of the values in an event payload. These differ from EventValueType.
+const (
+	eventPayloadInt    = 0
+	eventPayloadLong   = 1
+	eventPayloadString = 2
+	eventPayloadList   = 3
+	eventPayloadFloat  = 4
+)
+
+// DecodeEvent decodes the payload of an entry from the events buffer, without naming it.
+// See EventDecoder.
+func DecodeEvent(payload []byte) (*Event, error) {
+	if len(payload) < 4 {
+		return nil, errors.Errorf(errors.ParseError, "event payload too short: %d bytes", len(payload))
+	}
+	event := &Event{TagNumber: int(int32(binary.LittleEndian.Uint32(payload)))}
+	if len(payload) == 4 {
+		return event, nil
+	}
+
+	value, _, err := decodeEventValue(payload[4:])
+	if err != nil {
+		return nil, errors.WrapErrf(err, "error decoding event %d", event.TagNumber)
+	}
+	event.Value = value
+	return event, nil
+}
+
+// decodeEventValue decodes the typed value at the start of data, and returns the rest of data.
+func decodeEventValue(data []byte) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errors.Errorf(errors.ParseError, "missing event value")
+	}
+	typ, data := data[0], data[1:]
+
+	need := func(n int) error {
+		if len(data) < n {
+			return errors.Errorf(errors.ParseError, "truncated event value: need %d bytes, have %d", n, len(data))
+		}
+		return nil
+	}
+
+	switch typ {
+	case eventPayloadInt:
+		if err := need(4); err != nil {
+			return nil, nil, err
+		}
+		return int32(binary.LittleEndian.Uint32(data)), data[4:], nil
+	case eventPayloadLong:
+		if err := need(8); err != nil {
+			return nil, nil, err
+		}
+		return int64(binary.LittleEndian.Uint64(data)), data[8:], nil
+	case eventPayloadFloat:
+		if err := need(4); err != nil {
+			return nil, nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), data[4:], nil
+	case eventPayloadString:
+		if err := need(4); err != nil {
+			return nil, nil, err
+		}
+		n := int(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+		if err := need(n); err != nil {
+			return nil, nil, err
+		}
+		return string(data[:n]), data[n:], nil
+	case eventPayloadList:
+		if err := need(1); err != nil {
+			return nil, nil, err
+		}
+		count := int(data[0])
+		data = data[1:]
+		values := make([]any, 0, count)
+		for i := 0; i < count; i++ {
+			var value any
+			var err error
+			if value, data, err = decodeEventValue(data); err != nil {
+				return nil, nil, err
+			}
+			values = append(values, value)
+		}
+		return values, data, nil
+	default:
+		return nil, nil, errors.Errorf(errors.ParseError, "unknown event value type %d", typ)
+	}
+}
@@ -0,0 +1,188 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEventLogTags = `# The entries in this file map a sparse set of log tag numbers to tag names.
+42 answer (to life the universe etc|3)
+2718 e
+3000	boot_progress_start	(time|2|3)
+30008 am_anr (User|1|5),(pid|1|5),(Package Name|3),(Flags|1|5),(reason|3)
+30014 am_proc_start (User|1|5),(PID|1|5),(UID|1|5),(Process Name|3),(Type|3),(Component|3)
+50000 list_tag (values|4)
+`
+
+// eventPayload encodes values in the events buffer's binary format.
+type eventPayload struct {
+	bytes.Buffer
+}
+
+func newEventPayload(tag int32) *eventPayload {
+	p := &eventPayload{}
+	binary.Write(p, binary.LittleEndian, tag)
+	return p
+}
+
+func (p *eventPayload) int(v int32) *eventPayload {
+	p.WriteByte(eventPayloadInt)
+	binary.Write(p, binary.LittleEndian, v)
+	return p
+}
+
+func (p *eventPayload) long(v int64) *eventPayload {
+	p.WriteByte(eventPayloadLong)
+	binary.Write(p, binary.LittleEndian, v)
+	return p
+}
+
+func (p *eventPayload) float(v float32) *eventPayload {
+	p.WriteByte(eventPayloadFloat)
+	binary.Write(p, binary.LittleEndian, math.Float32bits(v))
+	return p
+}
+
+func (p *eventPayload) string(v string) *eventPayload {
+	p.WriteByte(eventPayloadString)
+	binary.Write(p, binary.LittleEndian, int32(len(v)))
+	p.WriteString(v)
+	return p
+}
+
+func (p *eventPayload) list(count int) *eventPayload {
+	p.WriteByte(eventPayloadList)
+	p.WriteByte(byte(count))
+	return p
+}
+
+func TestParseEventLogTags(t *testing.T) {
+	tags, err := ParseEventLogTags(strings.NewReader(testEventLogTags))
+	require.NoError(t, err)
+	require.Len(t, tags, 6)
+
+	assert.Equal(t, &EventTag{Number: 2718, Name: "e"}, tags[2718])
+	assert.Equal(t, []EventField{{Name: "to life the universe etc", Type: EventTypeString}}, tags[42].Fields)
+	assert.Equal(t, &EventTag{
+		Number: 30008,
+		Name:   "am_anr",
+		Fields: []EventField{
+			{Name: "User", Type: EventTypeInt, Unit: 5},
+			{Name: "pid", Type: EventTypeInt, Unit: 5},
+			{Name: "Package Name", Type: EventTypeString},
+			{Name: "Flags", Type: EventTypeInt, Unit: 5},
+			{Name: "reason", Type: EventTypeString},
+		},
+	}, tags[30008])
+}
+
+func TestParseEventLogTagsInvalidLines(t *testing.T) {
+	for _, line := range []string{"abc name", "12", "12 name (field)", "12 name (field|x)", "12 name (field|1"} {
+		_, err := parseEventLogTag(line)
+		assert.True(t, HasErrCode(err, ParseError), line)
+
+		tags, err := ParseEventLogTags(strings.NewReader("1 ok\n" + line + "\n2 also_ok"))
+		require.NoError(t, err, line)
+		assert.Equal(t, EventTags{1: {Number: 1, Name: "ok"}, 2: {Number: 2, Name: "also_ok"}}, tags, line)
+	}
+}
+
+func TestParseEventLogTagsLogsOnce(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	tags, err := ParseEventLogTags(strings.NewReader("1 ok\nbad\n2 also_ok\nworse\nnope\n"))
+	require.NoError(t, err)
+	assert.Len(t, tags, 2)
+	assert.Equal(t, 1, strings.Count(logged.String(), "\n"), logged.String())
+	assert.Contains(t, logged.String(), "ignoring 3 invalid lines, the first at line 2")
+}
+
+func TestDecodeEvent(t *testing.T) {
+	payload := newEventPayload(30014).list(6).
+		int(0).int(1234).int(10050).string("com.example").string("activity").string("com.example/.Main")
+
+	event, err := DecodeEvent(payload.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, 30014, event.TagNumber)
+	assert.Equal(t, []any{int32(0), int32(1234), int32(10050), "com.example", "activity", "com.example/.Main"}, event.Value)
+	assert.Equal(t, "30014", event.Name())
+
+	event, err = DecodeEvent(newEventPayload(3000).long(12345678901).Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, int64(12345678901), event.Value)
+
+	event, err = DecodeEvent(newEventPayload(1).list(2).float(1.5).list(1).int(7).Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []any{float32(1.5), []any{int32(7)}}, event.Value)
+
+	event, err = DecodeEvent(newEventPayload(2718).Bytes())
+	require.NoError(t, err)
+	assert.Nil(t, event.Value)
+}
+
+func TestDecodeEventErrors(t *testing.T) {
+	_, err := DecodeEvent([]byte{1, 2})
+	assert.True(t, HasErrCode(err, ParseError))
+
+	truncated := newEventPayload(42).string("hello").Bytes()
+	_, err = DecodeEvent(truncated[:len(truncated)-1])
+	assert.True(t, HasErrCode(err, ParseError))
+
+	_, err = DecodeEvent(newEventPayload(42).list(2).int(1).Bytes())
+	assert.True(t, HasErrCode(err, ParseError))
+
+	_, err = DecodeEvent(append(newEventPayload(42).Bytes(), 9))
+	assert.True(t, HasErrCode(err, ParseError))
+}
+
+func TestEventDecoder(t *testing.T) {
+	d := newFakeDevice()
+	d.addFile(EventLogTagsPath, testEventLogTags, 0644)
+	decoder := newFakeDeviceClient(d).NewEventDecoder()
+
+	event, err := decoder.Decode(&LogEntry{
+		Buffer: LogBufferEvents,
+		Payload: newEventPayload(30014).list(6).
+			int(0).int(1234).int(10050).string("com.example").string("activity").string("com.example/.Main").Bytes(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "am_proc_start", event.Name())
+	pid, ok := event.Field("PID")
+	assert.True(t, ok)
+	assert.Equal(t, int32(1234), pid)
+	_, ok = event.Field("missing")
+	assert.False(t, ok)
+
+	event, err = decoder.Decode(&LogEntry{Payload: newEventPayload(3000).long(8123).Bytes()})
+	require.NoError(t, err)
+	assert.Equal(t, "boot_progress_start", event.Name())
+	value, ok := event.Field("time")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8123), value)
+
+	event, err = decoder.Decode(&LogEntry{Payload: newEventPayload(50000).list(2).int(1).int(2).Bytes()})
+	require.NoError(t, err)
+	value, _ = event.Field("values")
+	assert.Equal(t, []any{int32(1), int32(2)}, value)
+	d.wait()
+
+	// The tags are only read once.
+	assert.Len(t, d.syncRequests, 1)
+}
+
+func TestEventDecoderMissingTags(t *testing.T) {
+	d := newFakeDevice()
+	decoder := newFakeDeviceClient(d).NewEventDecoder()
+
+	_, err := decoder.Decode(&LogEntry{Payload: newEventPayload(1).Bytes()})
+	assert.True(t, HasErrCode(err, FileNoExistError))
+}
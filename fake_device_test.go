@@ -0,0 +1,394 @@
+package adb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basiooo/goadb/wire"
+)
+
+// fakeDevice is an in-memory stand-in for an adb server with a single device attached.
+// It speaks the real protocol over net.Pipe, so tests exercise the same code paths as
+// a real server, including sync mode and connection reuse.
+type fakeDevice struct {
+	mu    sync.Mutex
+	files map[string]*fakeFile
+
+	// services handles device services other than sync:, keyed by the service prefix
+	// (e.g. "shell:"). The handler is called after OKAY has been written.
+	services map[string]func(conn net.Conn, req string)
+
+	// SEND requests for these paths are answered with FAIL and the given message.
+	sendErrors map[string]string
+
+	// If non-zero, host:tport: requests are accepted and answered with this transport id.
+	transportID uint64
+
+	// Number of times Dial was called.
+	dials int
+	// Every device service requested, in order.
+	requests []string
+	// Every sync request id received, in order.
+	syncRequests []string
+
+	wg sync.WaitGroup
+}
+
+type fakeFile struct {
+	mode  os.FileMode
+	data  []byte
+	mtime time.Time
+}
+
+var _ server = &fakeDevice{}
+
+func newFakeDevice() *fakeDevice {
+	d := &fakeDevice{
+		files:      map[string]*fakeFile{},
+		services:   map[string]func(net.Conn, string){},
+		sendErrors: map[string]string{},
+	}
+	d.files["/"] = &fakeFile{mode: os.ModeDir | 0755, mtime: someTime}
+	return d
+}
+
+func (d *fakeDevice) Start() error { return nil }
+
+func (d *fakeDevice) Dial() (*wire.Conn, error) {
+	d.mu.Lock()
+	d.dials++
+	d.mu.Unlock()
+
+	client, srv := net.Pipe()
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.serve(srv)
+	}()
+	safe := wire.MultiCloseable(client)
+	return &wire.Conn{Scanner: wire.NewScanner(safe), Sender: wire.NewSender(safe)}, nil
+}
+
+// wait blocks until every connection has been served. Tests must close their
+// connections first.
+func (d *fakeDevice) wait() {
+	d.wg.Wait()
+}
+
+// addFile creates a file and any missing parent directories.
+func (d *fakeDevice) addFile(name string, data string, mode os.FileMode) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addFileLocked(name, &fakeFile{mode: mode, data: []byte(data), mtime: someTime})
+}
+
+func (d *fakeDevice) addDir(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addFileLocked(name, &fakeFile{mode: os.ModeDir | 0755, mtime: someTime})
+}
+
+func (d *fakeDevice) addFileLocked(name string, f *fakeFile) {
+	for dir := path.Dir(name); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if _, ok := d.files[dir]; !ok {
+			d.files[dir] = &fakeFile{mode: os.ModeDir | 0755, mtime: someTime}
+		}
+	}
+	d.files[name] = f
+}
+
+func (d *fakeDevice) file(name string) (*fakeFile, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, ok := d.files[name]
+	return f, ok
+}
+
+func (d *fakeDevice) serve(conn net.Conn) {
+	defer conn.Close()
+
+	req, err := readFakeMessage(conn)
+	if err != nil {
+		return
+	}
+	if strings.HasPrefix(req, "host:") || strings.HasPrefix(req, "host-serial:") {
+		tport := strings.HasPrefix(req, "host:tport:") && d.transportID != 0
+		if !strings.HasPrefix(req, "host:transport") && !tport {
+			d.serveHost(conn, req)
+			return
+		}
+		writeFakeOkay(conn)
+		if tport {
+			binary.Write(conn, binary.LittleEndian, d.transportID)
+		}
+		if req, err = readFakeMessage(conn); err != nil {
+			return
+		}
+	}
+
+	d.mu.Lock()
+	d.requests = append(d.requests, req)
+	d.mu.Unlock()
+
+	if req == "sync:" {
+		writeFakeOkay(conn)
+		d.serveSync(conn)
+		return
+	}
+	for prefix, handler := range d.services {
+		if strings.HasPrefix(req, prefix) {
+			writeFakeOkay(conn)
+			handler(conn, req)
+			return
+		}
+	}
+	writeFakeFail(conn, "unknown service "+req)
+}
+
+func (d *fakeDevice) serveHost(conn net.Conn, req string) {
+	d.mu.Lock()
+	d.requests = append(d.requests, req)
+	d.mu.Unlock()
+	for prefix, handler := range d.services {
+		if strings.HasPrefix(req, prefix) {
+			writeFakeOkay(conn)
+			handler(conn, req)
+			return
+		}
+	}
+	writeFakeFail(conn, "unknown host service "+req)
+}
+
+func (d *fakeDevice) serveSync(conn net.Conn) {
+	for {
+		id, payload, err := readFakeSyncRequest(conn)
+		if err != nil {
+			return
+		}
+		d.mu.Lock()
+		d.syncRequests = append(d.syncRequests, id)
+		d.mu.Unlock()
+
+		switch id {
+		case "STAT":
+			d.syncStat(conn, string(payload))
+		case "LIST":
+			d.syncList(conn, string(payload))
+		case "RECV":
+			if !d.syncRecv(conn, string(payload)) {
+				return
+			}
+		case "SEND":
+			if !d.syncSend(conn, string(payload)) {
+				return
+			}
+		default:
+			// QUIT and anything unknown end the session.
+			return
+		}
+	}
+}
+
+func (d *fakeDevice) syncStat(conn net.Conn, name string) {
+	f, ok := d.file(name)
+	conn.Write([]byte("STAT"))
+	if !ok {
+		writeFakeInt32s(conn, 0, 0, 0)
+		return
+	}
+	writeFakeInt32s(conn, adbFileMode(f.mode), uint32(len(f.data)), uint32(f.mtime.Unix()))
+}
+
+func (d *fakeDevice) syncList(conn net.Conn, dir string) {
+	d.mu.Lock()
+	var names []string
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for name := range d.files {
+		if name != "/" && strings.HasPrefix(name, prefix) && !strings.Contains(name[len(prefix):], "/") {
+			names = append(names, name)
+		}
+	}
+	// Real devices don't return entries in any particular order.
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	files := make([]*fakeFile, len(names))
+	for i, name := range names {
+		files[i] = d.files[name]
+	}
+	d.mu.Unlock()
+
+	for _, dot := range []string{".", ".."} {
+		conn.Write([]byte("DENT"))
+		writeFakeInt32s(conn, adbFileMode(os.ModeDir|0755), 0, 0, uint32(len(dot)))
+		conn.Write([]byte(dot))
+	}
+	for i, f := range files {
+		base := path.Base(names[i])
+		conn.Write([]byte("DENT"))
+		writeFakeInt32s(conn, adbFileMode(f.mode), uint32(len(f.data)), uint32(f.mtime.Unix()), uint32(len(base)))
+		conn.Write([]byte(base))
+	}
+	conn.Write([]byte("DONE"))
+	writeFakeInt32s(conn, 0, 0, 0, 0)
+}
+
+func (d *fakeDevice) syncRecv(conn net.Conn, name string) bool {
+	f, ok := d.file(name)
+	if !ok || f.mode.IsDir() {
+		writeFakeSyncFail(conn, "No such file or directory")
+		return false
+	}
+	for data := f.data; len(data) > 0; {
+		chunk := data
+		if len(chunk) > 3 {
+			// Use tiny chunks so that readers cross chunk boundaries.
+			chunk = chunk[:3]
+		}
+		conn.Write([]byte("DATA"))
+		writeFakeInt32s(conn, uint32(len(chunk)))
+		conn.Write(chunk)
+		data = data[len(chunk):]
+	}
+	conn.Write([]byte("DONE"))
+	writeFakeInt32s(conn, 0)
+	return true
+}
+
+func (d *fakeDevice) syncSend(conn net.Conn, pathAndMode string) bool {
+	comma := strings.LastIndex(pathAndMode, ",")
+	if comma < 0 {
+		writeFakeSyncFail(conn, "missing mode")
+		return false
+	}
+	name := pathAndMode[:comma]
+	mode, err := strconv.ParseUint(pathAndMode[comma+1:], 10, 32)
+	if err != nil {
+		writeFakeSyncFail(conn, err.Error())
+		return false
+	}
+
+	var data []byte
+	for {
+		id := make([]byte, 4)
+		if _, err := io.ReadFull(conn, id); err != nil {
+			return false
+		}
+		var n uint32
+		if err := binary.Read(conn, binary.LittleEndian, &n); err != nil {
+			return false
+		}
+		if string(id) == "DONE" {
+			d.mu.Lock()
+			if msg, ok := d.sendErrors[name]; ok {
+				d.mu.Unlock()
+				writeFakeSyncFail(conn, msg)
+				return false
+			}
+			d.addFileLocked(name, &fakeFile{mode: os.FileMode(mode).Perm(), data: data, mtime: time.Unix(int64(n), 0).UTC()})
+			d.mu.Unlock()
+			conn.Write([]byte("OKAY"))
+			writeFakeInt32s(conn, 0)
+			return true
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(conn, chunk); err != nil {
+			return false
+		}
+		data = append(data, chunk...)
+	}
+}
+
+func adbFileMode(mode os.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode.IsDir() {
+		return bits | wire.ModeDir
+	}
+	return bits | 0100000
+}
+
+func readFakeMessage(r io.Reader) (string, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, length)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func readFakeSyncRequest(r io.Reader) (string, []byte, error) {
+	id := make([]byte, 4)
+	if _, err := io.ReadFull(r, id); err != nil {
+		return "", nil, err
+	}
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", nil, err
+	}
+	payload := make([]byte, n)
+	_, err := io.ReadFull(r, payload)
+	return string(id), payload, err
+}
+
+func writeFakeOkay(w io.Writer) {
+	w.Write([]byte(wire.StatusSuccess))
+}
+
+func writeFakeFail(w io.Writer, msg string) {
+	fmt.Fprintf(w, "%s%04x%s", wire.StatusFailure, len(msg), msg)
+}
+
+// writeFakeMessage writes a hex-length-prefixed message.
+func writeFakeMessage(w io.Writer, msg string) {
+	fmt.Fprintf(w, "%04x%s", len(msg), msg)
+}
+
+func writeFakeSyncFail(w io.Writer, msg string) {
+	w.Write([]byte(wire.StatusFailure))
+	writeFakeInt32s(w, uint32(len(msg)))
+	w.Write([]byte(msg))
+}
+
+func writeFakeInt32s(w io.Writer, values ...uint32) {
+	for _, v := range values {
+		binary.Write(w, binary.LittleEndian, v)
+	}
+}
+
+// fakeShellFunc emulates running cmdline in the device's shell.
+type fakeShellFunc func(cmdline string) (stdout, stderr string, exitCode int)
+
+// handleShell answers feature requests with features, and shell requests by calling fn.
+// Shell protocol v2 is only offered if features contains "shell_v2"; v1 shell requests
+// get stderr merged into stdout.
+func (d *fakeDevice) handleShell(features []string, fn fakeShellFunc) {
+	d.services["host-serial:serial:features"] = func(conn net.Conn, req string) {
+		writeFakeMessage(conn, strings.Join(features, ","))
+	}
+	d.services["shell,v2,raw:"] = func(conn net.Conn, req string) {
+		stdout, stderr, exitCode := fn(strings.TrimPrefix(req, "shell,v2,raw:"))
+		if stdout != "" {
+			wire.WriteShellPacket(conn, wire.ShellStdout, []byte(stdout))
+		}
+		if stderr != "" {
+			wire.WriteShellPacket(conn, wire.ShellStderr, []byte(stderr))
+		}
+		wire.WriteShellPacket(conn, wire.ShellExit, []byte{byte(exitCode)})
+	}
+	d.services["shell:"] = func(conn net.Conn, req string) {
+		stdout, stderr, _ := fn(strings.TrimPrefix(req, "shell:"))
+		io.WriteString(conn, stdout+stderr)
+	}
+}
@@ -0,0 +1,259 @@
+package adb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// Input injects touches, text and key events on the device through input, the input
+// manager's command line interface. Get one with Device.Input.
+//
+// Each input command starts a process on the device, which takes a few hundred
+// milliseconds. For fast or multi-touch gestures, see Input.Gesture.
+type Input struct {
+	device *Device
+	// display is the display events are sent to, or -1 for the default display.
+	display int
+}
+
+// Input returns an Input that sends events to the device's default display.
+func (c *Device) Input() *Input {
+	return &Input{device: c, display: -1}
+}
+
+// OnDisplay returns an Input that sends events to the display with id, as listed by
+// dumpsys display. Requires Android 10 or later.
+func (in *Input) OnDisplay(id int) *Input {
+	return &Input{device: in.device, display: id}
+}
+
+// DefaultLongPress is the duration of Input.LongPress if none is given, comfortably longer
+// than the system's long press timeout.
+const DefaultLongPress = time.Second
+
+/*
+Tap taps the screen at x, y, in pixels.
+
+Corresponds to the command:
+
+	adb shell input [-d <display>] tap <x> <y>
+*/
+func (in *Input) Tap(x, y int) error {
+	err := in.run(in.args("tap", strconv.Itoa(x), strconv.Itoa(y)))
+	return wrapClientError(err, in.device, "Tap(%d, %d)", x, y)
+}
+
+/*
+Swipe swipes from x1, y1 to x2, y2, in pixels, over duration. The system's default
+duration, around 300ms, is used if duration is 0.
+
+Corresponds to the command:
+
+	adb shell input [-d <display>] swipe <x1> <y1> <x2> <y2> [<duration ms>]
+*/
+func (in *Input) Swipe(x1, y1, x2, y2 int, duration time.Duration) error {
+	args := in.args("swipe", strconv.Itoa(x1), strconv.Itoa(y1), strconv.Itoa(x2), strconv.Itoa(y2))
+	if duration > 0 {
+		args = append(args, strconv.FormatInt(duration.Milliseconds(), 10))
+	}
+	err := in.run(args)
+	return wrapClientError(err, in.device, "Swipe(%d, %d, %d, %d)", x1, y1, x2, y2)
+}
+
+/*
+LongPress touches the screen at x, y, in pixels, for duration, or DefaultLongPress if
+duration is 0.
+
+Corresponds to the command:
+
+	adb shell input [-d <display>] swipe <x> <y> <x> <y> <duration ms>
+*/
+func (in *Input) LongPress(x, y int, duration time.Duration) error {
+	if duration <= 0 {
+		duration = DefaultLongPress
+	}
+	err := in.run(in.args("swipe", strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(x), strconv.Itoa(y),
+		strconv.FormatInt(duration.Milliseconds(), 10)))
+	return wrapClientError(err, in.device, "LongPress(%d, %d)", x, y)
+}
+
+/*
+Text types text into the focused view, as if it were typed on a hardware keyboard. Only
+printable ASCII characters, tabs and newlines can be typed; use an input method for others.
+
+Corresponds to the command:
+
+	adb shell input [-d <display>] text <text>
+*/
+func (in *Input) Text(text string) error {
+	if text == "" {
+		return nil
+	}
+	for _, r := range text {
+		if (r < ' ' || r > '~') && r != '\t' && r != '\n' {
+			return wrapClientError(errors.AssertionErrorf("input text can't type %q", r), in.device, "Text")
+		}
+	}
+
+	var cmds [][]string
+	for _, chunk := range in.textChunks(text) {
+		cmds = append(cmds, in.args("text", inputTextArg(chunk)))
+	}
+	err := in.run(cmds...)
+	return wrapClientError(err, in.device, "Text")
+}
+
+/*
+textChunks splits text into chunks typed by separate input commands that each fit in a
+shell command line. Since input text reads "%s" as a space, and there's no escape for it, a
+"%s" in text is split after its "%".
+*/
+func (in *Input) textChunks(text string) []string {
+	var chunks []string
+	var chunk []rune
+	for _, r := range text {
+		n := len(chunk)
+		split := n > 0 && chunk[n-1] == '%' && r == 's'
+		if !split && n > 0 {
+			cmdline := shellCommandLine("input", in.args("text", inputTextArg(string(append(chunk, r))))...)
+			split = len(cmdline) > maxShellCommandLine
+		}
+		if split {
+			chunks = append(chunks, string(chunk))
+			chunk = nil
+		}
+		chunk = append(chunk, r)
+	}
+	return append(chunks, string(chunk))
+}
+
+// inputTextArg encodes the spaces of text as "%s", as input text expects.
+func inputTextArg(text string) string {
+	return strings.ReplaceAll(text, " ", "%s")
+}
+
+/*
+KeyEvent presses and releases each of keys, in order.
+
+Corresponds to the command:
+
+	adb shell input [-d <display>] keyevent <key>...
+*/
+func (in *Input) KeyEvent(keys ...KeyCode) error {
+	err := in.run(in.args("keyevent", keyCodeArgs(keys)...))
+	return wrapClientError(err, in.device, "KeyEvent(%v)", keys)
+}
+
+/*
+LongPressKey presses and holds each of keys, in order, for the system's long press timeout.
+
+Corresponds to the command:
+
+	adb shell input [-d <display>] keyevent --longpress <key>...
+*/
+func (in *Input) LongPressKey(keys ...KeyCode) error {
+	err := in.run(in.args("keyevent", append([]string{"--longpress"}, keyCodeArgs(keys)...)...))
+	return wrapClientError(err, in.device, "LongPressKey(%v)", keys)
+}
+
+func keyCodeArgs(keys []KeyCode) []string {
+	args := make([]string, len(keys))
+	for i, key := range keys {
+		args[i] = strconv.Itoa(int(key))
+	}
+	return args
+}
+
+// MotionAction is the action of a MotionEvent.
+type MotionAction string
+
+const (
+	MotionDown   MotionAction = "DOWN"
+	MotionMove   MotionAction = "MOVE"
+	MotionUp     MotionAction = "UP"
+	MotionCancel MotionAction = "CANCEL"
+)
+
+// MotionEvent is a step of a touch sent by Input.Motion, at X, Y in pixels.
+type MotionEvent struct {
+	Action MotionAction
+	X, Y   int
+}
+
+/*
+Motion sends events, in order, to draw a touch along a path. A touch starts with
+MotionDown, and ends with MotionUp or MotionCancel. The events are batched into as few shell
+commands as fit, but each takes as long as an input command, so the touch moves slowly.
+Requires Android 11 or later.
+
+Corresponds to the command:
+
+	adb shell input [-d <display>] motionevent <action> <x> <y>
+*/
+func (in *Input) Motion(events ...MotionEvent) error {
+	cmds := make([][]string, len(events))
+	for i, event := range events {
+		cmds[i] = in.args("motionevent", string(event.Action), strconv.Itoa(event.X), strconv.Itoa(event.Y))
+	}
+	err := in.run(cmds...)
+	return wrapClientError(err, in.device, "Motion")
+}
+
+// args returns the arguments of input for command, with the display.
+func (in *Input) args(command string, args ...string) []string {
+	var all []string
+	if in.display >= 0 {
+		all = append(all, "-d", strconv.Itoa(in.display))
+	}
+	return append(append(all, command), args...)
+}
+
+// run runs input with each of cmds, stopping at the first that fails. As many as fit in a
+// shell command line are run by each shell command.
+func (in *Input) run(cmds ...[]string) error {
+	var batch []string
+	batchLen := 0
+	for _, args := range cmds {
+		cmdline := shellCommandLine("input", args...)
+		if len(batch) > 0 && batchLen+len(" && ")+len(cmdline) > maxShellCommandLine {
+			if err := in.runBatch(batch); err != nil {
+				return err
+			}
+			batch, batchLen = nil, 0
+		}
+		if len(batch) > 0 {
+			batchLen += len(" && ")
+		}
+		batch = append(batch, cmdline)
+		batchLen += len(cmdline)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return in.runBatch(batch)
+}
+
+func (in *Input) runBatch(cmdlines []string) error {
+	cmdline := strings.Join(cmdlines, " && ")
+	result, err := in.device.runShellCommand(cmdline)
+	if err != nil {
+		return err
+	}
+	// input prints usage errors, but may exit with status 0.
+	if msg := amErrorMessage(string(result.Stdout) + "\n" + string(result.Stderr)); msg != "" {
+		return &errors.Err{
+			Code:    errors.CommandFailed,
+			Message: fmt.Sprintf("input failed: %s", msg),
+			Details: CommandErrorDetails{
+				Command:  cmdline,
+				ExitCode: result.ExitCode,
+				Stderr:   strings.TrimSpace(string(result.Stderr)),
+			},
+		}
+	}
+	return commandError(cmdline, result)
+}
@@ -0,0 +1,106 @@
+package adb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputCommands(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice("", &cmdlines)
+	input := device.Input()
+
+	require.NoError(t, input.Tap(100, 200))
+	require.NoError(t, input.Swipe(10, 20, 30, 40, 250*time.Millisecond))
+	require.NoError(t, input.Swipe(10, 20, 30, 40, 0))
+	require.NoError(t, input.LongPress(5, 6, 0))
+	require.NoError(t, input.KeyEvent(KeyHome, KeyBack))
+	require.NoError(t, input.LongPressKey(KeyPower))
+	require.NoError(t, input.OnDisplay(2).Tap(1, 2))
+	require.NoError(t, input.Motion(
+		MotionEvent{MotionDown, 1, 2},
+		MotionEvent{MotionMove, 3, 4},
+		MotionEvent{MotionUp, 3, 4}))
+
+	assert.Equal(t, []string{
+		"input tap 100 200",
+		"input swipe 10 20 30 40 250",
+		"input swipe 10 20 30 40",
+		"input swipe 5 6 5 6 1000",
+		"input keyevent 3 4",
+		"input keyevent --longpress 26",
+		"input -d 2 tap 1 2",
+		"input motionevent DOWN 1 2 && input motionevent MOVE 3 4 && input motionevent UP 3 4",
+	}, cmdlines)
+}
+
+func TestInputText(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice("", &cmdlines)
+
+	require.NoError(t, device.Input().Text(`it's 100% "done" & a%s b`))
+	require.NoError(t, device.Input().Text(""))
+	assert.Equal(t, []string{
+		`input text 'it'\''s%s100%%s"done"%s&%sa%' && input text s%sb`,
+	}, cmdlines)
+
+	err := device.Input().Text("héllo")
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+}
+
+func TestInputTextLong(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice("", &cmdlines)
+
+	text := strings.Repeat("hello world ", 500)
+	require.NoError(t, device.Input().Text(text))
+	require.Greater(t, len(cmdlines), 1)
+	var typed string
+	for _, cmdline := range cmdlines {
+		assert.LessOrEqual(t, len(cmdline), maxShellCommandLine)
+		arg, ok := strings.CutPrefix(cmdline, "input text ")
+		require.True(t, ok, cmdline)
+		typed += strings.ReplaceAll(arg, "%s", " ")
+	}
+	assert.Equal(t, text, typed)
+}
+
+func TestInputError(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return "", "Error: Unknown command: tapp\nUsage: input [<source>] <command> [<arg>...]\n", 0
+	})
+
+	err := device.Input().Tap(1, 2)
+	assert.True(t, HasErrCode(err, CommandFailed), "%v", err)
+	assert.Contains(t, ErrorWithCauseChain(err), "Unknown command: tapp")
+}
+
+func TestKeyCode(t *testing.T) {
+	assert.Equal(t, "KEYCODE_HOME", KeyHome.String())
+	assert.Equal(t, "KEYCODE_7", Key7.String())
+	assert.Equal(t, "KEYCODE_Q", KeyQ.String())
+	assert.Equal(t, "KeyCode(300)", KeyCode(300).String())
+	assert.Equal(t, "KEYCODE_MEDIA_PLAY_PAUSE", KeyMediaPlayPause.String())
+	assert.Equal(t, "KEYCODE_MEDIA_PLAY", KeyMediaPlay.String())
+	assert.Equal(t, "KEYCODE_MEDIA_PAUSE", KeyMediaPause.String())
+	assert.Equal(t, "KEYCODE_MUTE", KeyMute.String())
+	assert.Equal(t, "KEYCODE_VOLUME_MUTE", KeyVolumeMute.String())
+
+	for _, name := range []string{"KEYCODE_VOLUME_UP", "volume_up"} {
+		key, ok := ParseKeyCode(name)
+		assert.True(t, ok)
+		assert.Equal(t, KeyVolumeUp, key)
+	}
+	key, ok := ParseKeyCode("z")
+	assert.True(t, ok)
+	assert.Equal(t, KeyZ, key)
+	key, ok = ParseKeyCode("MUTE")
+	assert.True(t, ok)
+	assert.Equal(t, KeyMute, key)
+	_, ok = ParseKeyCode("KEYCODE_NOPE")
+	assert.False(t, ok)
+}
@@ -0,0 +1,370 @@
+package adb
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// TestStatus is the outcome of a test, as reported by INSTRUMENTATION_STATUS_CODE.
+type TestStatus int
+
+const (
+	TestPassed            TestStatus = 0
+	TestError             TestStatus = -1
+	TestFailed            TestStatus = -2
+	TestIgnored           TestStatus = -3
+	TestAssumptionFailure TestStatus = -4
+
+	// testStarted is the code that starts a test.
+	testStarted = 1
+)
+
+func (s TestStatus) String() string {
+	switch s {
+	case TestPassed:
+		return "passed"
+	case TestError:
+		return "error"
+	case TestFailed:
+		return "failed"
+	case TestIgnored:
+		return "ignored"
+	case TestAssumptionFailure:
+		return "assumption failure"
+	}
+	return "TestStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// InstrumentOptions configures Device.Instrument.
+type InstrumentOptions struct {
+	// Args are passed to the runner with -e, e.g. "class" to run a single class, or
+	// "annotation" to filter tests.
+	Args map[string]string
+
+	// NumShards splits the tests into that many shards, of which only the one at ShardIndex,
+	// counting from 0, is run. Both are ignored if NumShards is 0.
+	NumShards  int
+	ShardIndex int
+
+	// Listener, if non-nil, is called as each test starts and finishes.
+	Listener func(TestEvent)
+}
+
+// TestEvent is reported to InstrumentOptions.Listener.
+type TestEvent struct {
+	// Finished is false when the test starts, and true once Test.Status is known.
+	Finished bool
+	Test     TestResult
+
+	// Current is the number of the test in the run, counting from 1, and Total is the number
+	// of tests in the run.
+	Current int
+	Total   int
+}
+
+// TestResult is a test run by Device.Instrument.
+type TestResult struct {
+	Class  string
+	Method string
+	Status TestStatus
+
+	// Stack is the stack trace of failures and errors.
+	Stack string
+
+	// Start and Duration are measured on the host, from the output of the test's start and
+	// end.
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Name returns the test's name, e.g. "com.example.FooTest#testBar".
+func (r *TestResult) Name() string {
+	return r.Class + "#" + r.Method
+}
+
+// InstrumentResult is the outcome of Device.Instrument.
+type InstrumentResult struct {
+	// Tests are the tests run, in order.
+	Tests []TestResult
+
+	// Completed is true if the runner finished the run, rather than crashing or failing to
+	// start.
+	Completed bool
+
+	// Code is the code the runner finished with, -1 if it didn't crash.
+	Code int
+	// Results is the bundle the runner finished with. Results["stream"] is the runner's
+	// summary, e.g. "Time: 1.23\n\nOK (3 tests)".
+	Results map[string]string
+
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Count returns the number of tests with status.
+func (r *InstrumentResult) Count(status TestStatus) int {
+	n := 0
+	for _, test := range r.Tests {
+		if test.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed returns true if the run completed and no test failed.
+func (r *InstrumentResult) Passed() bool {
+	return r.Completed && r.Count(TestFailed) == 0 && r.Count(TestError) == 0
+}
+
+/*
+Instrument runs the instrumentation tests of runner, e.g.
+"com.example.app.test/androidx.test.runner.AndroidJUnitRunner", and returns their results.
+The tests' progress is reported to opts.Listener as the output is received.
+
+If the run doesn't complete, e.g. because the app crashed or the runner couldn't be started,
+the test that was running is reported as a TestError, and the partial result is returned
+with a CommandFailed error. A CommandCanceled error is returned if ctx is done first.
+
+Corresponds to the command:
+
+	adb shell am instrument -r -w [-e <key> <value>]... <runner>
+*/
+func (c *Device) Instrument(ctx context.Context, runner string, opts InstrumentOptions) (*InstrumentResult, error) {
+	args, err := instrumentArgs(runner, opts)
+	if err != nil {
+		return nil, wrapClientError(err, c, "Instrument(%s)", runner)
+	}
+
+	p := newInstrumentParser(opts.Listener)
+	err = c.RunCommandLines(ctx, func(line string) error {
+		p.line(line)
+		return nil
+	}, "am", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.finish()
+	return result, wrapClientError(err, c, "Instrument(%s)", runner)
+}
+
+func instrumentArgs(runner string, opts InstrumentOptions) ([]string, error) {
+	if isBlank(runner) {
+		return nil, errors.AssertionErrorf("runner cannot be empty")
+	}
+	args := []string{"instrument", "-r", "-w"}
+
+	keys := make([]string, 0, len(opts.Args))
+	for key := range opts.Args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-e", key, opts.Args[key])
+	}
+
+	if opts.NumShards != 0 {
+		if opts.NumShards < 0 || opts.ShardIndex < 0 || opts.ShardIndex >= opts.NumShards {
+			return nil, errors.AssertionErrorf("invalid shard %d of %d", opts.ShardIndex, opts.NumShards)
+		}
+		args = append(args,
+			"-e", "numShards", strconv.Itoa(opts.NumShards),
+			"-e", "shardIndex", strconv.Itoa(opts.ShardIndex))
+	}
+	return append(args, runner), nil
+}
+
+// Prefixes of the lines of am instrument -r.
+const (
+	instrumentStatus     = "INSTRUMENTATION_STATUS: "
+	instrumentStatusCode = "INSTRUMENTATION_STATUS_CODE: "
+	instrumentResult     = "INSTRUMENTATION_RESULT: "
+	instrumentCode       = "INSTRUMENTATION_CODE: "
+	instrumentFailed     = "INSTRUMENTATION_FAILED: "
+)
+
+/*
+instrumentParser parses the output of am instrument -r. Each test reports a bundle of
+key-value pairs when it starts and when it ends, each followed by a status code:
+
+	INSTRUMENTATION_STATUS: class=com.example.FooTest
+	INSTRUMENTATION_STATUS: current=1
+	INSTRUMENTATION_STATUS: numtests=2
+	INSTRUMENTATION_STATUS: test=testBar
+	INSTRUMENTATION_STATUS_CODE: 1
+	INSTRUMENTATION_STATUS: class=com.example.FooTest
+	...
+	INSTRUMENTATION_STATUS: stack=java.lang.AssertionError: expected:<1> but was:<2>
+		at org.junit.Assert.fail(Assert.java:89)
+	INSTRUMENTATION_STATUS: test=testBar
+	INSTRUMENTATION_STATUS_CODE: -2
+
+The run ends with a bundle of results and the runner's code:
+
+	INSTRUMENTATION_RESULT: stream=
+	Time: 1.234
+
+	OK (2 tests)
+	INSTRUMENTATION_CODE: -1
+
+Values continue on the following lines until the next line with a prefix.
+*/
+type instrumentParser struct {
+	listener func(TestEvent)
+
+	status  map[string]string
+	results map[string]string
+	// value is the value being read, which continues on lines without a prefix.
+	value    *strings.Builder
+	valueKey string
+	valueMap map[string]string
+
+	running *TestEvent
+	result  InstrumentResult
+	code    *int
+	failure string
+}
+
+func newInstrumentParser(listener func(TestEvent)) *instrumentParser {
+	return &instrumentParser{
+		listener: listener,
+		status:   map[string]string{},
+		results:  map[string]string{},
+		result:   InstrumentResult{Start: time.Now()},
+	}
+}
+
+func (p *instrumentParser) line(line string) {
+	switch {
+	case strings.HasPrefix(line, instrumentStatus):
+		p.startValue(p.status, strings.TrimPrefix(line, instrumentStatus))
+	case strings.HasPrefix(line, instrumentResult):
+		p.startValue(p.results, strings.TrimPrefix(line, instrumentResult))
+	case strings.HasPrefix(line, instrumentStatusCode):
+		p.endValue()
+		if code, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, instrumentStatusCode))); err == nil {
+			p.statusCode(code)
+		}
+		p.status = map[string]string{}
+	case strings.HasPrefix(line, instrumentCode):
+		p.endValue()
+		if code, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, instrumentCode))); err == nil {
+			p.code = &code
+		}
+	case strings.HasPrefix(line, instrumentFailed):
+		p.endValue()
+		p.failure = strings.TrimPrefix(line, instrumentFailed)
+	default:
+		if p.value != nil {
+			p.value.WriteString("\n")
+			p.value.WriteString(line)
+		}
+	}
+}
+
+func (p *instrumentParser) startValue(m map[string]string, pair string) {
+	p.endValue()
+	key, value, _ := strings.Cut(pair, "=")
+	p.value, p.valueKey, p.valueMap = &strings.Builder{}, key, m
+	p.value.WriteString(value)
+}
+
+func (p *instrumentParser) endValue() {
+	if p.value != nil {
+		p.valueMap[p.valueKey] = p.value.String()
+		p.value = nil
+	}
+}
+
+func (p *instrumentParser) statusCode(code int) {
+	current, _ := strconv.Atoi(p.status["current"])
+	total, _ := strconv.Atoi(p.status["numtests"])
+	test := TestResult{Class: p.status["class"], Method: p.status["test"]}
+
+	if code == testStarted {
+		// A test that starts before the previous one finished has lost its end.
+		p.abortRunning("test didn't report a result")
+		test.Start = time.Now()
+		p.running = &TestEvent{Test: test, Current: current, Total: total}
+		p.report(*p.running)
+		return
+	}
+	if code > 0 {
+		// E.g. 2, the progress updates of some runners.
+		return
+	}
+
+	event := TestEvent{Finished: true, Test: test, Current: current, Total: total}
+	if p.running != nil && p.running.Test.Class == test.Class && p.running.Test.Method == test.Method {
+		event.Test.Start = p.running.Test.Start
+		event.Test.Duration = time.Since(event.Test.Start)
+		p.running = nil
+	}
+	event.Test.Status = TestStatus(code)
+	event.Test.Stack = p.status["stack"]
+	p.finishTest(event)
+}
+
+// abortRunning reports the running test, if any, as a TestError with message.
+func (p *instrumentParser) abortRunning(message string) {
+	if p.running == nil {
+		return
+	}
+	event := *p.running
+	p.running = nil
+	event.Finished = true
+	event.Test.Status = TestError
+	event.Test.Stack = message
+	event.Test.Duration = time.Since(event.Test.Start)
+	p.finishTest(event)
+}
+
+func (p *instrumentParser) finishTest(event TestEvent) {
+	p.result.Tests = append(p.result.Tests, event.Test)
+	p.report(event)
+}
+
+func (p *instrumentParser) report(event TestEvent) {
+	if p.listener != nil {
+		p.listener(event)
+	}
+}
+
+// finish returns the result once the output has been read, and an error if the run didn't
+// complete.
+func (p *instrumentParser) finish() (*InstrumentResult, error) {
+	p.endValue()
+	result := &p.result
+	result.Duration = time.Since(result.Start)
+	result.Results = p.results
+	if p.code != nil {
+		result.Code = *p.code
+	}
+
+	var reason string
+	switch {
+	case p.failure != "":
+		// E.g. the runner isn't installed.
+		p.abortRunning("instrumentation failed: " + p.failure)
+		return result, errors.Errorf(errors.CommandFailed, "instrumentation failed: %s", p.failure)
+	case p.code == nil:
+		reason = "the output ended without a result"
+	case p.results["shortMsg"] != "":
+		// The app crashed, or the runner failed to start.
+		reason = p.results["shortMsg"]
+	}
+	if reason == "" {
+		p.abortRunning("test didn't report a result")
+		result.Completed = true
+		return result, nil
+	}
+
+	p.abortRunning("test run didn't complete: " + reason)
+	return result, errors.Errorf(errors.CommandFailed, "test run didn't complete: %s", reason)
+}
@@ -0,0 +1,207 @@
+package adb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInstrumentOutput = `INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: current=1
+INSTRUMENTATION_STATUS: id=AndroidJUnitRunner
+INSTRUMENTATION_STATUS: numtests=3
+INSTRUMENTATION_STATUS: stream=
+com.example.FooTest:
+INSTRUMENTATION_STATUS: test=testPasses
+INSTRUMENTATION_STATUS_CODE: 1
+INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: current=1
+INSTRUMENTATION_STATUS: id=AndroidJUnitRunner
+INSTRUMENTATION_STATUS: numtests=3
+INSTRUMENTATION_STATUS: stream=.
+INSTRUMENTATION_STATUS: test=testPasses
+INSTRUMENTATION_STATUS_CODE: 0
+INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: current=2
+INSTRUMENTATION_STATUS: numtests=3
+INSTRUMENTATION_STATUS: test=testFails
+INSTRUMENTATION_STATUS_CODE: 1
+INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: current=2
+INSTRUMENTATION_STATUS: numtests=3
+INSTRUMENTATION_STATUS: stack=java.lang.AssertionError: expected:<1> but was:<2>
+	at org.junit.Assert.fail(Assert.java:89)
+	at com.example.FooTest.testFails(FooTest.java:20)
+
+INSTRUMENTATION_STATUS: stream=
+Error in testFails(com.example.FooTest):
+java.lang.AssertionError: expected:<1> but was:<2>
+INSTRUMENTATION_STATUS: test=testFails
+INSTRUMENTATION_STATUS_CODE: -2
+INSTRUMENTATION_STATUS: class=com.example.BarTest
+INSTRUMENTATION_STATUS: current=3
+INSTRUMENTATION_STATUS: numtests=3
+INSTRUMENTATION_STATUS: test=testIgnored
+INSTRUMENTATION_STATUS_CODE: 1
+INSTRUMENTATION_STATUS: class=com.example.BarTest
+INSTRUMENTATION_STATUS: current=3
+INSTRUMENTATION_STATUS: numtests=3
+INSTRUMENTATION_STATUS: test=testIgnored
+INSTRUMENTATION_STATUS_CODE: -3
+INSTRUMENTATION_RESULT: stream=
+
+Time: 1.234
+
+FAILURES!!!
+Tests run: 2,  Failures: 1
+
+INSTRUMENTATION_CODE: -1
+`
+
+const testRunner = "com.example.test/androidx.test.runner.AndroidJUnitRunner"
+
+func TestInstrument(t *testing.T) {
+	var cmdlines []string
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		cmdlines = append(cmdlines, cmdline)
+		return testInstrumentOutput, "", 0
+	})
+
+	var events []TestEvent
+	result, err := device.Instrument(context.Background(), testRunner, InstrumentOptions{
+		Args:       map[string]string{"package": "com.example", "annotation": "com.example.Smoke"},
+		NumShards:  4,
+		ShardIndex: 1,
+		Listener: func(event TestEvent) {
+			events = append(events, event)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"am instrument -r -w -e annotation com.example.Smoke -e package com.example " +
+		"-e numShards 4 -e shardIndex 1 " + testRunner}, cmdlines)
+
+	require.Len(t, result.Tests, 3)
+	assert.Equal(t, "com.example.FooTest#testPasses", result.Tests[0].Name())
+	assert.Equal(t, TestPassed, result.Tests[0].Status)
+	assert.False(t, result.Tests[0].Start.IsZero())
+	assert.Equal(t, TestFailed, result.Tests[1].Status)
+	assert.Equal(t, "java.lang.AssertionError: expected:<1> but was:<2>\n"+
+		"\tat org.junit.Assert.fail(Assert.java:89)\n"+
+		"\tat com.example.FooTest.testFails(FooTest.java:20)\n", result.Tests[1].Stack)
+	assert.Equal(t, TestResult{Class: "com.example.BarTest", Method: "testIgnored", Status: TestIgnored},
+		TestResult{Class: result.Tests[2].Class, Method: result.Tests[2].Method, Status: result.Tests[2].Status})
+
+	assert.Equal(t, -1, result.Code)
+	assert.Contains(t, result.Results["stream"], "Tests run: 2,  Failures: 1")
+	assert.True(t, result.Completed)
+	assert.False(t, result.Passed())
+	assert.Equal(t, 1, result.Count(TestFailed))
+
+	require.Len(t, events, 6)
+	assert.False(t, events[2].Finished)
+	assert.Equal(t, 2, events[2].Current)
+	assert.Equal(t, 3, events[2].Total)
+	assert.True(t, events[3].Finished)
+	assert.Equal(t, TestFailed, events[3].Test.Status)
+}
+
+func TestInstrumentCrashed(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return `INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: current=1
+INSTRUMENTATION_STATUS: numtests=2
+INSTRUMENTATION_STATUS: test=testCrashes
+INSTRUMENTATION_STATUS_CODE: 1
+INSTRUMENTATION_RESULT: shortMsg=Process crashed.
+INSTRUMENTATION_CODE: 0
+`, "", 0
+	})
+
+	result, err := device.Instrument(context.Background(), testRunner, InstrumentOptions{})
+	assert.True(t, HasErrCode(err, CommandFailed), "%v", err)
+	assert.Contains(t, ErrorWithCauseChain(err), "Process crashed.")
+	require.NotNil(t, result)
+	require.Len(t, result.Tests, 1)
+	assert.Equal(t, TestError, result.Tests[0].Status)
+	assert.Contains(t, result.Tests[0].Stack, "Process crashed.")
+	assert.Equal(t, 0, result.Code)
+	assert.False(t, result.Completed)
+	assert.False(t, result.Passed())
+}
+
+func TestInstrumentFailed(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return "INSTRUMENTATION_FAILED: " + testRunner + "\n", "", 0
+	})
+
+	result, err := device.Instrument(context.Background(), testRunner, InstrumentOptions{})
+	assert.True(t, HasErrCode(err, CommandFailed), "%v", err)
+	require.NotNil(t, result)
+	assert.Empty(t, result.Tests)
+	assert.False(t, result.Completed)
+	assert.False(t, result.Passed())
+}
+
+func TestInstrumentFailedWhileRunning(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return `INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: test=testA
+INSTRUMENTATION_STATUS_CODE: 1
+INSTRUMENTATION_FAILED: ` + testRunner + "\n", "", 0
+	})
+
+	var events []TestEvent
+	result, err := device.Instrument(context.Background(), testRunner, InstrumentOptions{
+		Listener: func(event TestEvent) {
+			events = append(events, event)
+		},
+	})
+	assert.True(t, HasErrCode(err, CommandFailed), "%v", err)
+	require.NotNil(t, result)
+	require.Len(t, result.Tests, 1)
+	assert.Equal(t, TestError, result.Tests[0].Status)
+	assert.Contains(t, result.Tests[0].Stack, "instrumentation failed")
+	require.Len(t, events, 2)
+	assert.True(t, events[1].Finished)
+	assert.False(t, result.Passed())
+}
+
+func TestInstrumentIncomplete(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return "INSTRUMENTATION_STATUS: class=com.example.FooTest\nINSTRUMENTATION_STATUS: test=testA\nINSTRUMENTATION_STATUS_CODE: 1\n", "", 0
+	})
+
+	result, err := device.Instrument(context.Background(), testRunner, InstrumentOptions{})
+	assert.True(t, HasErrCode(err, CommandFailed), "%v", err)
+	require.Len(t, result.Tests, 1)
+	assert.Equal(t, TestError, result.Tests[0].Status)
+}
+
+func TestInstrumentLongArgs(t *testing.T) {
+	var cmdlines []string
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		cmdlines = append(cmdlines, cmdline)
+		return "INSTRUMENTATION_RESULT: stream=\n\nOK (0 tests)\nINSTRUMENTATION_CODE: -1\n", "", 0
+	})
+
+	classes := strings.TrimSuffix(strings.Repeat("com.example.app.ui.SomeLongTestClassName,", 20), ",")
+	require.Greater(t, len(classes), 255)
+	result, err := device.Instrument(context.Background(), testRunner, InstrumentOptions{
+		Args: map[string]string{"class": classes},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"am instrument -r -w -e class " + classes + " " + testRunner}, cmdlines)
+	assert.True(t, result.Completed)
+	assert.True(t, result.Passed())
+}
+
+func TestInstrumentArgsInvalid(t *testing.T) {
+	_, err := instrumentArgs("", InstrumentOptions{})
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+
+	_, err = instrumentArgs(testRunner, InstrumentOptions{NumShards: 2, ShardIndex: 2})
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+}
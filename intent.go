@@ -0,0 +1,215 @@
+package adb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IntentFlag is a flag of an Intent, as defined by android.content.Intent.
+type IntentFlag uint32
+
+const (
+	FlagGrantReadURIPermission  IntentFlag = 0x00000001
+	FlagGrantWriteURIPermission IntentFlag = 0x00000002
+	FlagIncludeStoppedPackages  IntentFlag = 0x00000020
+	FlagActivityClearTask       IntentFlag = 0x00008000
+	FlagActivityReorderToFront  IntentFlag = 0x00020000
+	FlagActivityNoAnimation     IntentFlag = 0x00010000
+	FlagActivityExcludeRecents  IntentFlag = 0x00800000
+	FlagActivityClearTop        IntentFlag = 0x04000000
+	FlagActivityNewTask         IntentFlag = 0x10000000
+	FlagActivitySingleTop       IntentFlag = 0x20000000
+	FlagActivityNoHistory       IntentFlag = 0x40000000
+	// FlagReceiverForeground runs a broadcast's receivers at foreground priority. It has the
+	// same value as FlagActivityNewTask, which only applies to activities.
+	FlagReceiverForeground IntentFlag = 0x10000000
+)
+
+// Common intent actions and categories.
+const (
+	ActionMain        = "android.intent.action.MAIN"
+	ActionView        = "android.intent.action.VIEW"
+	ActionSend        = "android.intent.action.SEND"
+	CategoryLauncher  = "android.intent.category.LAUNCHER"
+	CategoryDefault   = "android.intent.category.DEFAULT"
+	CategoryBrowsable = "android.intent.category.BROWSABLE"
+)
+
+/*
+Intent describes an activity to start, a service to run or a broadcast to send, and is
+converted to the intent arguments of am. Its methods return the intent, so that it can be
+built in one expression:
+
+	intent := adb.NewIntent(adb.ActionView).
+		SetData("https://example.com").
+		SetComponent("com.example.app/.MainActivity").
+		AddFlags(adb.FlagActivityNewTask).
+		PutString("config", `{"debug": true, "tags": ["a", "b"]}`).
+		PutInt("retries", 3)
+
+Values are passed to am verbatim, whatever characters they contain. The whole am command
+line must fit in a request to adb, of up to about 4KB; longer intents fail with an
+AssertionError before anything is run.
+*/
+type Intent struct {
+	Action     string
+	Data       string
+	MimeType   string
+	Component  string
+	Package    string
+	Categories []string
+	Flags      IntentFlag
+
+	extras []intentExtra
+}
+
+// intentExtra is an extra with its am option, e.g. --ei for ints.
+type intentExtra struct {
+	option string
+	key    string
+	// value is omitted for --esn.
+	value *string
+}
+
+// NewIntent returns an intent with action, which can be empty.
+func NewIntent(action string) *Intent {
+	return &Intent{Action: action}
+}
+
+// SetData sets the data URI.
+func (i *Intent) SetData(uri string) *Intent {
+	i.Data = uri
+	return i
+}
+
+// SetType sets the MIME type of the data.
+func (i *Intent) SetType(mimeType string) *Intent {
+	i.MimeType = mimeType
+	return i
+}
+
+// SetComponent sets the component to target, e.g. "com.example.app/.MainActivity".
+func (i *Intent) SetComponent(component string) *Intent {
+	i.Component = component
+	return i
+}
+
+// SetPackage limits the intent to the components of a package.
+func (i *Intent) SetPackage(pkg string) *Intent {
+	i.Package = pkg
+	return i
+}
+
+func (i *Intent) AddCategory(category string) *Intent {
+	i.Categories = append(i.Categories, category)
+	return i
+}
+
+func (i *Intent) AddFlags(flags IntentFlag) *Intent {
+	i.Flags |= flags
+	return i
+}
+
+func (i *Intent) PutString(key, value string) *Intent {
+	return i.put("--es", key, value)
+}
+
+// PutNull puts a null string extra.
+func (i *Intent) PutNull(key string) *Intent {
+	i.extras = append(i.extras, intentExtra{option: "--esn", key: key})
+	return i
+}
+
+func (i *Intent) PutBool(key string, value bool) *Intent {
+	return i.put("--ez", key, strconv.FormatBool(value))
+}
+
+func (i *Intent) PutInt(key string, value int32) *Intent {
+	return i.put("--ei", key, strconv.FormatInt(int64(value), 10))
+}
+
+func (i *Intent) PutLong(key string, value int64) *Intent {
+	return i.put("--el", key, strconv.FormatInt(value, 10))
+}
+
+func (i *Intent) PutFloat(key string, value float32) *Intent {
+	return i.put("--ef", key, strconv.FormatFloat(float64(value), 'g', -1, 32))
+}
+
+// PutURI puts an android.net.Uri extra.
+func (i *Intent) PutURI(key, uri string) *Intent {
+	return i.put("--eu", key, uri)
+}
+
+// PutComponent puts an android.content.ComponentName extra, e.g. "com.example.app/.MyService".
+func (i *Intent) PutComponent(key, component string) *Intent {
+	return i.put("--ecn", key, component)
+}
+
+// PutStringArray puts a String[] extra.
+func (i *Intent) PutStringArray(key string, values []string) *Intent {
+	// am splits the value on commas that aren't escaped with a backslash.
+	escaped := make([]string, len(values))
+	for j, v := range values {
+		escaped[j] = strings.ReplaceAll(v, ",", `\,`)
+	}
+	return i.put("--esa", key, strings.Join(escaped, ","))
+}
+
+// PutIntArray puts an int[] extra.
+func (i *Intent) PutIntArray(key string, values []int32) *Intent {
+	formatted := make([]string, len(values))
+	for j, v := range values {
+		formatted[j] = strconv.FormatInt(int64(v), 10)
+	}
+	return i.put("--eia", key, strings.Join(formatted, ","))
+}
+
+// PutLongArray puts a long[] extra.
+func (i *Intent) PutLongArray(key string, values []int64) *Intent {
+	formatted := make([]string, len(values))
+	for j, v := range values {
+		formatted[j] = strconv.FormatInt(v, 10)
+	}
+	return i.put("--ela", key, strings.Join(formatted, ","))
+}
+
+func (i *Intent) put(option, key, value string) *Intent {
+	i.extras = append(i.extras, intentExtra{option: option, key: key, value: &value})
+	return i
+}
+
+// args returns the intent as am arguments. They must be quoted for the shell.
+func (i *Intent) args() []string {
+	var args []string
+	if i.Action != "" {
+		args = append(args, "-a", i.Action)
+	}
+	if i.Data != "" {
+		args = append(args, "-d", i.Data)
+	}
+	if i.MimeType != "" {
+		args = append(args, "-t", i.MimeType)
+	}
+	for _, category := range i.Categories {
+		args = append(args, "-c", category)
+	}
+	if i.Component != "" {
+		args = append(args, "-n", i.Component)
+	}
+	if i.Flags != 0 {
+		args = append(args, "-f", fmt.Sprintf("%#x", uint32(i.Flags)))
+	}
+	for _, extra := range i.extras {
+		args = append(args, extra.option, extra.key)
+		if extra.value != nil {
+			args = append(args, *extra.value)
+		}
+	}
+	// Older versions of am don't have -p, but all of them take a package as the last argument.
+	if i.Package != "" {
+		args = append(args, i.Package)
+	}
+	return args
+}
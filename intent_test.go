@@ -0,0 +1,51 @@
+package adb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntentArgs(t *testing.T) {
+	intent := NewIntent(ActionView).
+		SetData("https://example.com/?a=1&b=2").
+		SetType("text/html").
+		AddCategory(CategoryBrowsable).
+		SetComponent("com.example.app/.MainActivity").
+		AddFlags(FlagActivityNewTask|FlagActivityClearTop).
+		PutString("config", `{"debug": true, "tags": ["a", "b"]}`).
+		PutNull("none").
+		PutBool("enabled", true).
+		PutInt("retries", -3).
+		PutLong("id", 1<<40).
+		PutFloat("ratio", 0.5).
+		PutURI("uri", "content://x/1").
+		PutComponent("target", "com.example.app/.Service").
+		PutStringArray("names", []string{"a,b", "c"}).
+		PutIntArray("ints", []int32{1, 2}).
+		PutLongArray("longs", []int64{3})
+
+	assert.Equal(t, []string{
+		"-a", ActionView,
+		"-d", "https://example.com/?a=1&b=2",
+		"-t", "text/html",
+		"-c", CategoryBrowsable,
+		"-n", "com.example.app/.MainActivity",
+		"-f", "0x14000000",
+		"--es", "config", `{"debug": true, "tags": ["a", "b"]}`,
+		"--esn", "none",
+		"--ez", "enabled", "true",
+		"--ei", "retries", "-3",
+		"--el", "id", "1099511627776",
+		"--ef", "ratio", "0.5",
+		"--eu", "uri", "content://x/1",
+		"--ecn", "target", "com.example.app/.Service",
+		"--esa", "names", `a\,b,c`,
+		"--eia", "ints", "1,2",
+		"--ela", "longs", "3",
+	}, intent.args())
+
+	assert.Equal(t, []string{"-a", "com.example.PING", "com.example.app"},
+		NewIntent("com.example.PING").SetPackage("com.example.app").args())
+	assert.Empty(t, NewIntent("").args())
+}
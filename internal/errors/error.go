@@ -55,6 +55,16 @@ const (
 	CommandTimeout
 	// Command execution was canceled.
 	CommandCanceled
+	// Reading or writing a file on the host failed.
+	LocalFileError
+	// Tried to create a file on the device that already exists.
+	FileExistError
+	// The device refused an operation because of file permissions or a read-only filesystem.
+	PermissionDenied
+	// A command run on the device exited with a non-zero status.
+	CommandFailed
+	// Pairing with or authenticating to the device failed, e.g. because of a wrong pairing code.
+	AuthenticationFailed
 )
 
 func Errorf(code ErrCode, format string, args ...interface{}) error {
@@ -0,0 +1,134 @@
+/*
+Package protobuf decodes the protocol buffer wire format, for the few messages adbd sends
+encoded as protobufs. It only splits a message into its fields: the caller interprets them
+according to its .proto definition.
+
+	d := protobuf.NewDecoder(data)
+	for {
+		field, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		...
+		switch field.Number {
+		case 1:
+			pid = int64(field.Varint)
+		}
+	}
+*/
+package protobuf
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// WireType is the encoding of a field's value.
+type WireType uint8
+
+const (
+	WireVarint  WireType = 0
+	WireFixed64 WireType = 1
+	WireBytes   WireType = 2
+	// Groups are deprecated, and not supported.
+	WireStartGroup WireType = 3
+	WireEndGroup   WireType = 4
+	WireFixed32    WireType = 5
+)
+
+// Field is a field of a message.
+type Field struct {
+	Number int
+	Type   WireType
+
+	// Varint is the value of WireVarint, WireFixed64 and WireFixed32 fields.
+	Varint uint64
+	// Bytes is the value of WireBytes fields: a string, bytes, an embedded message or a
+	// packed repeated field. It points into the decoded message.
+	Bytes []byte
+}
+
+// Bool returns the value of a bool field.
+func (f Field) Bool() bool {
+	return f.Varint != 0
+}
+
+// Int64 returns the value of an int64 or int32 field.
+func (f Field) Int64() int64 {
+	return int64(f.Varint)
+}
+
+// Decoder reads the fields of a message.
+type Decoder struct {
+	data []byte
+}
+
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Next returns the next field of the message, or io.EOF if there are no more.
+func (d *Decoder) Next() (Field, error) {
+	if len(d.data) == 0 {
+		return Field{}, io.EOF
+	}
+
+	tag, err := d.varint("tag")
+	if err != nil {
+		return Field{}, err
+	}
+	field := Field{Number: int(tag >> 3), Type: WireType(tag & 7)}
+	if field.Number <= 0 || tag>>3 > 1<<29-1 {
+		return Field{}, errors.Errorf(errors.ParseError, "invalid protobuf field number %d", tag>>3)
+	}
+
+	switch field.Type {
+	case WireVarint:
+		field.Varint, err = d.varint("varint")
+	case WireFixed64:
+		var b []byte
+		if b, err = d.next(8, "fixed64"); err == nil {
+			field.Varint = binary.LittleEndian.Uint64(b)
+		}
+	case WireFixed32:
+		var b []byte
+		if b, err = d.next(4, "fixed32"); err == nil {
+			field.Varint = uint64(binary.LittleEndian.Uint32(b))
+		}
+	case WireBytes:
+		var length uint64
+		if length, err = d.varint("length"); err == nil {
+			if length > uint64(len(d.data)) {
+				return Field{}, errors.Errorf(errors.ParseError,
+					"protobuf field %d is %d bytes long, but only %d bytes remain", field.Number, length, len(d.data))
+			}
+			field.Bytes, err = d.next(int(length), "bytes")
+		}
+	default:
+		return Field{}, errors.Errorf(errors.ParseError, "unsupported protobuf wire type %d of field %d", field.Type, field.Number)
+	}
+	if err != nil {
+		return Field{}, err
+	}
+	return field, nil
+}
+
+func (d *Decoder) varint(part string) (uint64, error) {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, errors.Errorf(errors.ParseError, "invalid protobuf %s", part)
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+func (d *Decoder) next(n int, part string) ([]byte, error) {
+	if len(d.data) < n {
+		return nil, errors.Errorf(errors.ParseError, "truncated protobuf %s", part)
+	}
+	b := d.data[:n:n]
+	d.data = d.data[n:]
+	return b, nil
+}
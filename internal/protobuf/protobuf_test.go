@@ -0,0 +1,55 @@
+package protobuf
+
+import (
+	"io"
+	"testing"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder(t *testing.T) {
+	data := []byte{
+		0x08, 0x96, 0x01, // 1: varint 150
+		0x12, 0x03, 'a', 'r', 'm', // 2: bytes "arm"
+		0x19, 1, 0, 0, 0, 0, 0, 0, 0, // 3: fixed64 1
+		0x25, 2, 0, 0, 0, // 4: fixed32 2
+		0x28, 0x01, // 5: true
+		0x30, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // 6: -1
+	}
+	d := NewDecoder(data)
+
+	expected := []Field{
+		{Number: 1, Type: WireVarint, Varint: 150},
+		{Number: 2, Type: WireBytes, Bytes: []byte("arm")},
+		{Number: 3, Type: WireFixed64, Varint: 1},
+		{Number: 4, Type: WireFixed32, Varint: 2},
+		{Number: 5, Type: WireVarint, Varint: 1},
+		{Number: 6, Type: WireVarint, Varint: 1<<64 - 1},
+	}
+	for _, e := range expected {
+		field, err := d.Next()
+		require.NoError(t, err)
+		assert.Equal(t, e, field)
+	}
+	_, err := d.Next()
+	assert.Equal(t, io.EOF, err)
+
+	assert.True(t, Field{Varint: 1}.Bool())
+	assert.Equal(t, int64(-1), Field{Varint: 1<<64 - 1}.Int64())
+}
+
+func TestDecoderInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"truncated varint": {0x08, 0x96},
+		"truncated bytes":  {0x12, 0x05, 'a'},
+		"truncated fixed":  {0x19, 1, 0},
+		"field zero":       {0x00, 0x01},
+		"group":            {0x0b},
+		"invalid type":     {0x0e},
+	} {
+		_, err := NewDecoder(data).Next()
+		assert.True(t, errors.HasErrCode(err, errors.ParseError), "%s: %v", name, err)
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Package spake2 implements the SPAKE2 password-authenticated key exchange over edwards25519,
+compatible with the implementation in BoringSSL that adb uses for pairing.
+
+Both parties derive the same key if, and only if, they used the same password. The exchange
+reveals nothing about the password to an eavesdropper, and an active attacker only gets one
+guess per exchange.
+
+The arithmetic is done with math/big, which is not constant time. That's acceptable for
+pairing, where each password is only used for a single exchange, but this package should not
+be used for anything else.
+*/
+package spake2
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// Role identifies which side of the exchange a Context is. The two sides must use different
+// roles.
+type Role int
+
+const (
+	// Alice is the side that initiates the exchange, the client.
+	Alice Role = iota
+	// Bob is the side that responds, the server.
+	Bob
+)
+
+// MessageSize is the size of the messages exchanged.
+const MessageSize = 32
+
+// KeySize is the size of the key derived by the exchange.
+const KeySize = 64
+
+var (
+	// p is the order of the field, 2^255 - 19.
+	p = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	// d is the curve constant, -121665/121666.
+	d = mod(new(big.Int).Mul(big.NewInt(-121665), new(big.Int).ModInverse(big.NewInt(121666), p)))
+	// l is the order of the prime-order subgroup generated by the base point.
+	l, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+	// sqrtM1 is a square root of -1.
+	sqrtM1 = new(big.Int).Exp(big.NewInt(2), new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 2), p)
+
+	basePoint = newBasePoint()
+	pointM    = generatePoint("edwards25519 point generation seed (M)")
+	pointN    = generatePoint("edwards25519 point generation seed (N)")
+)
+
+// Context holds the state of one side of an exchange. It can only be used for one exchange.
+type Context struct {
+	role      Role
+	myName    []byte
+	theirName []byte
+
+	privateKey     *big.Int
+	passwordScalar *big.Int
+	passwordHash   [sha512.Size]byte
+	myMessage      []byte
+	done           bool
+}
+
+// New returns a Context for role. myName and theirName identify the two sides, and must
+// match the names the other side uses, swapped.
+func New(role Role, myName, theirName []byte) *Context {
+	return &Context{role: role, myName: myName, theirName: theirName}
+}
+
+// GenerateMessage returns the message to send to the other side, using random to generate
+// the private key.
+func (c *Context) GenerateMessage(password []byte, random io.Reader) ([]byte, error) {
+	if c.myMessage != nil {
+		return nil, errors.AssertionErrorf("message already generated")
+	}
+
+	var private [64]byte
+	if _, err := io.ReadFull(random, private[:]); err != nil {
+		return nil, errors.WrapErrorf(err, errors.AssertionError, "error generating private key")
+	}
+	// Multiply by the cofactor, so that any small-order component of the other side's
+	// point is cleared when computing the shared point.
+	c.privateKey = new(big.Int).Lsh(reduceScalar(private[:]), 3)
+
+	c.passwordHash = sha512.Sum512(password)
+	c.passwordScalar = passwordScalar(c.passwordHash[:])
+
+	mask := c.maskPoint(c.role == Alice)
+	c.myMessage = basePoint.scalarMult(c.privateKey).add(mask).encode()
+	return append([]byte(nil), c.myMessage...), nil
+}
+
+// ProcessMessage processes the other side's message and returns the derived key. If the two
+// sides used different passwords, the keys differ, which the caller detects by failing to
+// decrypt the other side's messages.
+func (c *Context) ProcessMessage(theirMessage []byte) ([]byte, error) {
+	if c.myMessage == nil {
+		return nil, errors.AssertionErrorf("message not generated")
+	}
+	if c.done {
+		return nil, errors.AssertionErrorf("message already processed")
+	}
+	if len(theirMessage) != MessageSize {
+		return nil, errors.Errorf(errors.ParseError, "invalid SPAKE2 message length %d", len(theirMessage))
+	}
+	theirPoint, ok := decodePoint(theirMessage)
+	if !ok {
+		return nil, errors.Errorf(errors.ParseError, "SPAKE2 message is not a point on the curve")
+	}
+	c.done = true
+
+	theirMask := c.maskPoint(c.role != Alice)
+	shared := theirPoint.add(theirMask.negate()).scalarMult(c.privateKey).encode()
+
+	h := sha512.New()
+	if c.role == Alice {
+		writeWithLength(h, c.myName)
+		writeWithLength(h, c.theirName)
+		writeWithLength(h, c.myMessage)
+		writeWithLength(h, theirMessage)
+	} else {
+		writeWithLength(h, c.theirName)
+		writeWithLength(h, c.myName)
+		writeWithLength(h, theirMessage)
+		writeWithLength(h, c.myMessage)
+	}
+	writeWithLength(h, shared)
+	writeWithLength(h, c.passwordHash[:])
+	return h.Sum(nil), nil
+}
+
+// maskPoint returns the password scalar times M if useM, else times N. Alice masks her
+// message with M and Bob with N.
+func (c *Context) maskPoint(useM bool) point {
+	if useM {
+		return pointM.scalarMult(c.passwordScalar)
+	}
+	return pointN.scalarMult(c.passwordScalar)
+}
+
+/*
+passwordScalar reduces the password hash to a scalar. BoringSSL adds multiples of l to make it
+a multiple of 8 as well, so that M and N's small-order components don't leak bits of the
+password. Since M and N aren't in the prime-order subgroup, this changes the mask, so it has
+to be done the same way here.
+*/
+func passwordScalar(hash []byte) *big.Int {
+	s := reduceScalar(hash)
+	multiple := new(big.Int).Set(l)
+	for bit := uint(0); bit < 3; bit++ {
+		if s.Bit(int(bit)) == 1 {
+			s.Add(s, multiple)
+		}
+		multiple.Lsh(multiple, 1)
+	}
+	return s
+}
+
+// reduceScalar interprets b as a little-endian number and reduces it mod l.
+func reduceScalar(b []byte) *big.Int {
+	return new(big.Int).Mod(fromLittleEndian(b), l)
+}
+
+func writeWithLength(w io.Writer, data []byte) {
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(data)))
+	w.Write(length[:])
+	w.Write(data)
+}
+
+// point is a point on edwards25519 in affine coordinates.
+type point struct {
+	x, y *big.Int
+}
+
+func identity() point {
+	return point{big.NewInt(0), big.NewInt(1)}
+}
+
+func newBasePoint() point {
+	// The base point has y = 4/5 and an even x.
+	y := mod(new(big.Int).Mul(big.NewInt(4), new(big.Int).ModInverse(big.NewInt(5), p)))
+	var encoded [32]byte
+	y.FillBytes(encoded[:])
+	reverse(encoded[:])
+	base, _ := decodePoint(encoded[:])
+	return base
+}
+
+/*
+generatePoint derives M or N from seed the way BoringSSL's were generated: hash the seed with
+SHA-256, and hash the hash again until it decodes to a point.
+*/
+func generatePoint(seed string) point {
+	h := sha256.Sum256([]byte(seed))
+	for {
+		if pt, ok := decodePoint(h[:]); ok {
+			return pt
+		}
+		h = sha256.Sum256(h[:])
+	}
+}
+
+// add returns a + b, using the complete twisted Edwards addition law with a = -1.
+func (a point) add(b point) point {
+	x1y2 := new(big.Int).Mul(a.x, b.y)
+	y1x2 := new(big.Int).Mul(a.y, b.x)
+	y1y2 := new(big.Int).Mul(a.y, b.y)
+	x1x2 := new(big.Int).Mul(a.x, b.x)
+	dxy := mod(new(big.Int).Mul(d, mod(new(big.Int).Mul(x1x2, y1y2))))
+
+	xNum := mod(new(big.Int).Add(x1y2, y1x2))
+	xDen := mod(new(big.Int).Add(big.NewInt(1), dxy))
+	yNum := mod(new(big.Int).Add(y1y2, x1x2))
+	yDen := mod(new(big.Int).Sub(big.NewInt(1), dxy))
+	return point{
+		x: mod(xNum.Mul(xNum, new(big.Int).ModInverse(xDen, p))),
+		y: mod(yNum.Mul(yNum, new(big.Int).ModInverse(yDen, p))),
+	}
+}
+
+func (a point) negate() point {
+	return point{mod(new(big.Int).Neg(a.x)), new(big.Int).Set(a.y)}
+}
+
+// scalarMult returns k * a. k isn't reduced, since a may have a small-order component.
+func (a point) scalarMult(k *big.Int) point {
+	result := identity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = result.add(result)
+		if k.Bit(i) == 1 {
+			result = result.add(a)
+		}
+	}
+	return result
+}
+
+// encode returns the standard 32-byte encoding of a: y in little-endian, with the top bit
+// set if x is odd.
+func (a point) encode() []byte {
+	b := a.y.FillBytes(make([]byte, 32))
+	reverse(b)
+	b[31] |= byte(a.x.Bit(0)) << 7
+	return b
+}
+
+// decodePoint decodes the encoding of a point, and returns false if it isn't on the curve.
+func decodePoint(b []byte) (point, bool) {
+	buf := append([]byte(nil), b...)
+	sign := buf[31] >> 7
+	buf[31] &= 0x7f
+	y := mod(fromLittleEndian(buf))
+
+	// x^2 = (y^2 - 1) / (d y^2 + 1)
+	y2 := mod(new(big.Int).Mul(y, y))
+	u := mod(new(big.Int).Sub(y2, big.NewInt(1)))
+	v := mod(new(big.Int).Add(new(big.Int).Mul(d, y2), big.NewInt(1)))
+	x2 := mod(new(big.Int).Mul(u, new(big.Int).ModInverse(v, p)))
+
+	x := new(big.Int).Exp(x2, new(big.Int).Rsh(new(big.Int).Add(p, big.NewInt(3)), 3), p)
+	if mod(new(big.Int).Mul(x, x)).Cmp(x2) != 0 {
+		x = mod(x.Mul(x, sqrtM1))
+		if mod(new(big.Int).Mul(x, x)).Cmp(x2) != 0 {
+			return point{}, false
+		}
+	}
+	if byte(x.Bit(0)) != sign {
+		x = mod(x.Neg(x))
+	}
+	return point{x, y}, true
+}
+
+func mod(x *big.Int) *big.Int {
+	return x.Mod(x, p)
+}
+
+func fromLittleEndian(b []byte) *big.Int {
+	be := append([]byte(nil), b...)
+	reverse(be)
+	return new(big.Int).SetBytes(be)
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
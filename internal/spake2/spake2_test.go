@@ -0,0 +1,101 @@
+package spake2
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	clientName = []byte("client\x00")
+	serverName = []byte("server\x00")
+)
+
+func exchange(t *testing.T, alicePassword, bobPassword string) ([]byte, []byte) {
+	alice := New(Alice, clientName, serverName)
+	bob := New(Bob, serverName, clientName)
+
+	aliceMsg, err := alice.GenerateMessage([]byte(alicePassword), rand.Reader)
+	require.NoError(t, err)
+	bobMsg, err := bob.GenerateMessage([]byte(bobPassword), rand.Reader)
+	require.NoError(t, err)
+	require.Len(t, aliceMsg, MessageSize)
+
+	aliceKey, err := alice.ProcessMessage(bobMsg)
+	require.NoError(t, err)
+	bobKey, err := bob.ProcessMessage(aliceMsg)
+	require.NoError(t, err)
+	require.Len(t, aliceKey, KeySize)
+	return aliceKey, bobKey
+}
+
+func TestExchange(t *testing.T) {
+	aliceKey, bobKey := exchange(t, "123456", "123456")
+	assert.Equal(t, aliceKey, bobKey)
+}
+
+func TestExchangeWrongPassword(t *testing.T) {
+	aliceKey, bobKey := exchange(t, "123456", "654321")
+	assert.NotEqual(t, aliceKey, bobKey)
+}
+
+func TestProcessMessageInvalid(t *testing.T) {
+	alice := New(Alice, clientName, serverName)
+	_, err := alice.ProcessMessage(make([]byte, MessageSize))
+	assert.True(t, errors.HasErrCode(err, errors.AssertionError))
+
+	_, err = alice.GenerateMessage([]byte("123456"), rand.Reader)
+	require.NoError(t, err)
+	_, err = alice.ProcessMessage([]byte{1, 2, 3})
+	assert.True(t, errors.HasErrCode(err, errors.ParseError))
+
+	// y = 2 isn't the y-coordinate of any point.
+	notOnCurve := make([]byte, MessageSize)
+	notOnCurve[0] = 2
+	_, err = alice.ProcessMessage(notOnCurve)
+	assert.True(t, errors.HasErrCode(err, errors.ParseError))
+}
+
+func TestBasePoint(t *testing.T) {
+	assert.Equal(t, "5866666666666666666666666666666666666666666666666666666666666666", hex.EncodeToString(basePoint.encode()))
+	assert.Equal(t, identity().encode(), basePoint.scalarMult(l).encode())
+}
+
+func TestScalarMultMatchesEd25519(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	public := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+
+	h := sha512.Sum512(seed)
+	h[0] &= 248
+	h[31] &= 127
+	h[31] |= 64
+	assert.Equal(t, []byte(public), basePoint.scalarMult(fromLittleEndian(h[:32])).encode())
+}
+
+func TestGeneratedPointsOnCurve(t *testing.T) {
+	for _, pt := range []point{pointM, pointN} {
+		decoded, ok := decodePoint(pt.encode())
+		require.True(t, ok)
+		assert.Equal(t, pt.x, decoded.x)
+		assert.Equal(t, pt.y, decoded.y)
+	}
+	assert.NotEqual(t, pointM.encode(), pointN.encode())
+}
+
+func TestPasswordScalarMultipleOfEight(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		hash := sha512.Sum512([]byte{byte(i)})
+		s := passwordScalar(hash[:])
+		assert.Zero(t, s.Bits()[0]&7)
+		assert.Equal(t, reduceScalar(hash[:]), s.Mod(s, l))
+	}
+}
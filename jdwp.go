@@ -0,0 +1,220 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/wire"
+)
+
+// jdwpHandshake is sent by the debugger once connected, and echoed by the VM.
+const jdwpHandshake = "JDWP-Handshake"
+
+/*
+TrackJdwp reports the PIDs of the device's debuggable processes, i.e. the processes of
+debuggable apps, or of every app on userdebug and eng builds. The tracker keeps reporting
+changes until ctx is done or it's closed.
+
+Eg.
+
+	tracker, err := device.TrackJdwp(ctx)
+	...
+	defer tracker.Close()
+	for {
+		pids, err := tracker.Next()
+		...
+	}
+
+Corresponds to the command:
+
+	adb track-jdwp
+*/
+func (c *Device) TrackJdwp(ctx context.Context) (*JdwpTracker, error) {
+	stream, err := c.openCommandStream(ctx, "track-jdwp", "TrackJdwp")
+	if err != nil {
+		return nil, err
+	}
+	return &JdwpTracker{stream: stream}, nil
+}
+
+// JdwpTracker reports the debuggable processes tracked by Device.TrackJdwp.
+type JdwpTracker struct {
+	stream *CommandStream
+}
+
+// Next waits until the set of debuggable processes changes, and returns the PIDs of all of
+// them, in ascending order. The first call returns the processes running when tracking
+// started. It returns io.EOF if the device disconnects.
+func (t *JdwpTracker) Next() ([]int, error) {
+	msg, err := readHexMessage(t.stream)
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errors.WrapErrf(err, "error reading track-jdwp")
+	}
+	pids, err := parseJdwpPids(string(msg))
+	return pids, wrapClientError(err, t.stream.device, "TrackJdwp")
+}
+
+func (t *JdwpTracker) Close() error {
+	return t.stream.Close()
+}
+
+// readHexMessage reads a message prefixed with its length as 4 hex digits, as sent by the
+// track-* services. It returns io.EOF if r ends before the message starts.
+func readHexMessage(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if n, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, hexMessageReadError(err, n == 0, "message length")
+	}
+	n, err := strconv.ParseUint(string(length[:]), 16, 16)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid message length %q", length[:])
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, hexMessageReadError(err, false, "message data")
+	}
+	return msg, nil
+}
+
+func hexMessageReadError(err error, atStart bool, part string) error {
+	if err == io.EOF && atStart {
+		return io.EOF
+	}
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.WrapErrorf(err, errors.ConnectionResetError, "connection closed reading %s", part)
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error reading %s", part)
+}
+
+// parseJdwpPids parses a track-jdwp message, which lists one PID per line.
+func parseJdwpPids(msg string) ([]int, error) {
+	pids := []int{}
+	for _, line := range strings.Split(msg, "\n") {
+		if line == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(line)
+		if err != nil || pid <= 0 {
+			return nil, errors.Errorf(errors.ParseError, "invalid PID %q in track-jdwp message", line)
+		}
+		pids = append(pids, pid)
+	}
+	slices.Sort(pids)
+	return pids, nil
+}
+
+/*
+DialJdwp connects to the JDWP agent of the debuggable process pid, and performs the JDWP
+handshake. The returned connection is ready for JDWP commands, e.g. from a debugger or
+profiler attaching to the process.
+
+The connection doesn't support deadlines: close it to interrupt reads and writes.
+
+Corresponds to the command:
+
+	adb forward tcp:<port> jdwp:<pid>
+*/
+func (c *Device) DialJdwp(pid int) (net.Conn, error) {
+	if pid <= 0 {
+		return nil, wrapClientError(errors.AssertionErrorf("invalid PID %d", pid), c, "DialJdwp")
+	}
+
+	conn, err := c.dialDevice()
+	if err != nil {
+		return nil, wrapClientError(err, c, "DialJdwp(%d)", pid)
+	}
+
+	if err := jdwpHandshakeOn(conn, pid); err != nil {
+		if err := conn.Close(); err != nil {
+			log.Printf("[Device] error closing connection: %s", err)
+		}
+		return nil, wrapClientError(err, c, "DialJdwp(%d)", pid)
+	}
+	return &jdwpConn{Conn: conn, addr: JdwpAddr{Device: c.descriptor.String(), Pid: pid}}, nil
+}
+
+func jdwpHandshakeOn(conn *wire.Conn, pid int) error {
+	req := fmt.Sprintf("jdwp:%d", pid)
+	if err := wire.SendMessageString(conn, req); err != nil {
+		return err
+	}
+	if _, err := conn.ReadStatus(req); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write([]byte(jdwpHandshake)); err != nil {
+		return err
+	}
+	reply := make([]byte, len(jdwpHandshake))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.WrapErrorf(err, errors.ConnectionResetError,
+				"process %d closed the connection during the JDWP handshake, it's probably not debuggable", pid)
+		}
+		return err
+	}
+	if !bytes.Equal(reply, []byte(jdwpHandshake)) {
+		return errors.Errorf(errors.ParseError, "invalid JDWP handshake reply %q", reply)
+	}
+	return nil
+}
+
+// JdwpAddr is the address of the connections returned by Device.DialJdwp.
+type JdwpAddr struct {
+	// Device describes the device, as Device.String does.
+	Device string
+	Pid    int
+}
+
+func (a JdwpAddr) Network() string {
+	return "adb"
+}
+
+func (a JdwpAddr) String() string {
+	return fmt.Sprintf("%s/jdwp:%d", a.Device, a.Pid)
+}
+
+// jdwpConn adapts a connection to the jdwp: service to net.Conn.
+type jdwpConn struct {
+	*wire.Conn
+	addr JdwpAddr
+}
+
+var _ net.Conn = &jdwpConn{}
+
+func (c *jdwpConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *jdwpConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *jdwpConn) SetDeadline(time.Time) error {
+	return os.ErrNoDeadline
+}
+
+func (c *jdwpConn) SetReadDeadline(time.Time) error {
+	return os.ErrNoDeadline
+}
+
+func (c *jdwpConn) SetWriteDeadline(time.Time) error {
+	return os.ErrNoDeadline
+}
@@ -0,0 +1,131 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackJdwp(t *testing.T) {
+	d := newFakeDevice()
+	d.services["track-jdwp"] = func(conn net.Conn, req string) {
+		writeFakeMessage(conn, "4321\n1234\n")
+		writeFakeMessage(conn, "4321\n1234\n5678\n")
+		writeFakeMessage(conn, "")
+	}
+	device := newFakeDeviceClient(d)
+
+	tracker, err := device.TrackJdwp(context.Background())
+	require.NoError(t, err)
+	defer tracker.Close()
+
+	for _, expected := range [][]int{{1234, 4321}, {1234, 4321, 5678}, {}} {
+		pids, err := tracker.Next()
+		require.NoError(t, err)
+		assert.Equal(t, expected, pids)
+	}
+	_, err = tracker.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "track-jdwp", d.requests[0])
+}
+
+func TestTrackJdwpInvalid(t *testing.T) {
+	d := newFakeDevice()
+	d.services["track-jdwp"] = func(conn net.Conn, req string) {
+		writeFakeMessage(conn, "1234\nabc\n")
+		io.WriteString(conn, "00")
+	}
+	device := newFakeDeviceClient(d)
+
+	tracker, err := device.TrackJdwp(context.Background())
+	require.NoError(t, err)
+	defer tracker.Close()
+
+	_, err = tracker.Next()
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+	_, err = tracker.Next()
+	assert.True(t, HasErrCode(err, ConnectionResetError), "%v", err)
+}
+
+func TestTrackJdwpCanceled(t *testing.T) {
+	d := newFakeDevice()
+	d.services["track-jdwp"] = func(conn net.Conn, req string) {
+		writeFakeMessage(conn, "1234\n")
+		io.Copy(io.Discard, conn)
+	}
+	device := newFakeDeviceClient(d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tracker, err := device.TrackJdwp(ctx)
+	require.NoError(t, err)
+	defer tracker.Close()
+
+	_, err = tracker.Next()
+	require.NoError(t, err)
+	cancel()
+	_, err = tracker.Next()
+	assert.True(t, HasErrCode(err, CommandCanceled), "%v", err)
+	tracker.Close()
+	d.wait()
+}
+
+func TestDialJdwp(t *testing.T) {
+	d := newFakeDevice()
+	d.services["jdwp:"] = func(conn net.Conn, req string) {
+		handshake := make([]byte, len(jdwpHandshake))
+		if _, err := io.ReadFull(conn, handshake); err != nil {
+			return
+		}
+		conn.Write(handshake)
+		// Echo JDWP packets.
+		io.Copy(conn, conn)
+	}
+	device := newFakeDeviceClient(d)
+
+	conn, err := device.DialJdwp(1234)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "jdwp:1234", d.requests[0])
+	assert.Equal(t, "DeviceSerial[serial]/jdwp:1234", conn.RemoteAddr().String())
+	assert.Equal(t, os.ErrNoDeadline, conn.SetDeadline(someTime))
+
+	packet := []byte{0, 0, 0, 11, 0, 0, 0, 1, 0, 1, 1}
+	_, err = conn.Write(packet)
+	require.NoError(t, err)
+	echoed := make([]byte, len(packet))
+	_, err = io.ReadFull(conn, echoed)
+	require.NoError(t, err)
+	assert.Equal(t, packet, echoed)
+}
+
+func TestDialJdwpNotDebuggable(t *testing.T) {
+	d := newFakeDevice()
+	d.services["jdwp:"] = func(conn net.Conn, req string) {
+		// adbd closes the connection if the process doesn't have a JDWP agent.
+		io.ReadFull(conn, make([]byte, len(jdwpHandshake)))
+	}
+	device := newFakeDeviceClient(d)
+
+	_, err := device.DialJdwp(1234)
+	assert.True(t, HasErrCode(err, ConnectionResetError), "%v", err)
+
+	_, err = device.DialJdwp(0)
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+}
+
+func TestDialJdwpInvalidHandshake(t *testing.T) {
+	d := newFakeDevice()
+	d.services["jdwp:"] = func(conn net.Conn, req string) {
+		io.ReadFull(conn, make([]byte, len(jdwpHandshake)))
+		io.WriteString(conn, "JDWP-Shakehand")
+	}
+	device := newFakeDeviceClient(d)
+
+	_, err := device.DialJdwp(1234)
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+}
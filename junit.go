@@ -0,0 +1,108 @@
+package adb
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+type junitTestSuite struct {
+	XMLName    xml.Name         `xml:"testsuite"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr"`
+	Properties *junitProperties `xml:"properties"`
+	TestCases  []junitTestCase  `xml:"testcase"`
+	SystemErr  string           `xml:"system-err,omitempty"`
+}
+
+type junitProperties struct {
+	Property []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitProblem `xml:"skipped,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr,omitempty"`
+	Stack   string `xml:",chardata"`
+}
+
+/*
+WriteJUnitXML writes the result as a JUnit XML test suite named name, the format CI systems
+read test reports in. Ignored tests and assumption failures are reported as skipped. If the
+run didn't complete, the suite has a "completed" property of "false", and says why in its
+system-err.
+*/
+func (r *InstrumentResult) WriteJUnitXML(w io.Writer, name string) error {
+	suite := junitTestSuite{
+		Name:      name,
+		Tests:     len(r.Tests),
+		Failures:  r.Count(TestFailed),
+		Errors:    r.Count(TestError),
+		Skipped:   r.Count(TestIgnored) + r.Count(TestAssumptionFailure),
+		Time:      junitSeconds(r.Duration),
+		Timestamp: r.Start.UTC().Format("2006-01-02T15:04:05"),
+	}
+	if !r.Completed {
+		suite.Properties = &junitProperties{[]junitProperty{{Name: "completed", Value: "false"}}}
+		suite.SystemErr = "test run didn't complete"
+		if msg := r.Results["shortMsg"]; msg != "" {
+			suite.SystemErr += ": " + msg
+		}
+	}
+	for _, test := range r.Tests {
+		tc := junitTestCase{ClassName: test.Class, Name: test.Method, Time: junitSeconds(test.Duration)}
+		problem := &junitProblem{Message: firstLine(test.Stack), Stack: test.Stack}
+		switch test.Status {
+		case TestFailed:
+			tc.Failure = problem
+		case TestError:
+			tc.Error = problem
+		case TestIgnored, TestAssumptionFailure:
+			tc.Skipped = problem
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error writing JUnit XML")
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error writing JUnit XML")
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error writing JUnit XML")
+	}
+	return nil
+}
+
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,65 @@
+package adb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJUnitXML(t *testing.T) {
+	result := &InstrumentResult{
+		Tests: []TestResult{
+			{Class: "com.example.FooTest", Method: "testPasses", Status: TestPassed, Duration: 120 * time.Millisecond},
+			{Class: "com.example.FooTest", Method: "testFails", Status: TestFailed, Stack: "java.lang.AssertionError: 1 < 2\n\tat Foo"},
+			{Class: "com.example.FooTest", Method: "testCrashes", Status: TestError, Stack: "test run didn't complete"},
+			{Class: "com.example.BarTest", Method: "testIgnored", Status: TestIgnored},
+		},
+		Completed: true,
+		Start:     time.Date(2024, 1, 2, 10, 11, 12, 0, time.UTC),
+		Duration:  1500 * time.Millisecond,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, result.WriteJUnitXML(&buf, "example"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="example" tests="4" failures="1" errors="1" skipped="1" time="1.500" timestamp="2024-01-02T10:11:12">
+  <testcase classname="com.example.FooTest" name="testPasses" time="0.120"></testcase>
+  <testcase classname="com.example.FooTest" name="testFails" time="0.000">
+    <failure message="java.lang.AssertionError: 1 &lt; 2">java.lang.AssertionError: 1 &lt; 2&#xA;&#x9;at Foo</failure>
+  </testcase>
+  <testcase classname="com.example.FooTest" name="testCrashes" time="0.000">
+    <error message="test run didn&#39;t complete">test run didn&#39;t complete</error>
+  </testcase>
+  <testcase classname="com.example.BarTest" name="testIgnored" time="0.000">
+    <skipped></skipped>
+  </testcase>
+</testsuite>
+`, buf.String())
+}
+
+func TestWriteJUnitXMLIncomplete(t *testing.T) {
+	result := &InstrumentResult{
+		Tests: []TestResult{
+			{Class: "com.example.FooTest", Method: "testCrashes", Status: TestError, Stack: "test run didn't complete"},
+		},
+		Results: map[string]string{"shortMsg": "Process crashed."},
+		Start:   time.Date(2024, 1, 2, 10, 11, 12, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, result.WriteJUnitXML(&buf, "example"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="example" tests="1" failures="0" errors="1" skipped="0" time="0.000" timestamp="2024-01-02T10:11:12">
+  <properties>
+    <property name="completed" value="false"></property>
+  </properties>
+  <testcase classname="com.example.FooTest" name="testCrashes" time="0.000">
+    <error message="test run didn&#39;t complete">test run didn&#39;t complete</error>
+  </testcase>
+  <system-err>test run didn&#39;t complete: Process crashed.</system-err>
+</testsuite>
+`, buf.String())
+}
@@ -0,0 +1,176 @@
+package adb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// KeyCode is an Android key code, as sent by Input.KeyEvent. See android.view.KeyEvent for
+// the full list; any code can be converted to a KeyCode.
+type KeyCode int
+
+const (
+	KeySoftLeft       KeyCode = 1
+	KeySoftRight      KeyCode = 2
+	KeyHome           KeyCode = 3
+	KeyBack           KeyCode = 4
+	KeyCall           KeyCode = 5
+	KeyEndCall        KeyCode = 6
+	Key0              KeyCode = 7
+	Key1              KeyCode = 8
+	Key2              KeyCode = 9
+	Key3              KeyCode = 10
+	Key4              KeyCode = 11
+	Key5              KeyCode = 12
+	Key6              KeyCode = 13
+	Key7              KeyCode = 14
+	Key8              KeyCode = 15
+	Key9              KeyCode = 16
+	KeyStar           KeyCode = 17
+	KeyPound          KeyCode = 18
+	KeyDpadUp         KeyCode = 19
+	KeyDpadDown       KeyCode = 20
+	KeyDpadLeft       KeyCode = 21
+	KeyDpadRight      KeyCode = 22
+	KeyDpadCenter     KeyCode = 23
+	KeyVolumeUp       KeyCode = 24
+	KeyVolumeDown     KeyCode = 25
+	KeyPower          KeyCode = 26
+	KeyCamera         KeyCode = 27
+	KeyClear          KeyCode = 28
+	KeyA              KeyCode = 29
+	KeyB              KeyCode = 30
+	KeyC              KeyCode = 31
+	KeyD              KeyCode = 32
+	KeyE              KeyCode = 33
+	KeyF              KeyCode = 34
+	KeyG              KeyCode = 35
+	KeyH              KeyCode = 36
+	KeyI              KeyCode = 37
+	KeyJ              KeyCode = 38
+	KeyK              KeyCode = 39
+	KeyL              KeyCode = 40
+	KeyM              KeyCode = 41
+	KeyN              KeyCode = 42
+	KeyO              KeyCode = 43
+	KeyP              KeyCode = 44
+	KeyQ              KeyCode = 45
+	KeyR              KeyCode = 46
+	KeyS              KeyCode = 47
+	KeyT              KeyCode = 48
+	KeyU              KeyCode = 49
+	KeyV              KeyCode = 50
+	KeyW              KeyCode = 51
+	KeyX              KeyCode = 52
+	KeyY              KeyCode = 53
+	KeyZ              KeyCode = 54
+	KeyComma          KeyCode = 55
+	KeyPeriod         KeyCode = 56
+	KeyTab            KeyCode = 61
+	KeySpace          KeyCode = 62
+	KeyEnter          KeyCode = 66
+	KeyDel            KeyCode = 67
+	KeyMenu           KeyCode = 82
+	KeySearch         KeyCode = 84
+	KeyMediaPlayPause KeyCode = 85
+	KeyMediaStop      KeyCode = 86
+	KeyMediaNext      KeyCode = 87
+	KeyMediaPrev      KeyCode = 88
+	KeyMute           KeyCode = 91 // The microphone; see KeyVolumeMute for the speaker.
+	KeyPageUp         KeyCode = 92
+	KeyPageDown       KeyCode = 93
+	KeyEscape         KeyCode = 111
+	KeyForwardDel     KeyCode = 112
+	KeyMoveHome       KeyCode = 122
+	KeyMoveEnd        KeyCode = 123
+	KeyMediaPlay      KeyCode = 126
+	KeyMediaPause     KeyCode = 127
+	KeyVolumeMute     KeyCode = 164
+	KeyAppSwitch      KeyCode = 187
+	KeyBrightDown     KeyCode = 220
+	KeyBrightUp       KeyCode = 221
+	KeySleep          KeyCode = 223
+	KeyWakeup         KeyCode = 224
+)
+
+var keyCodeNames = map[KeyCode]string{
+	KeySoftLeft:       "SOFT_LEFT",
+	KeySoftRight:      "SOFT_RIGHT",
+	KeyHome:           "HOME",
+	KeyBack:           "BACK",
+	KeyCall:           "CALL",
+	KeyEndCall:        "ENDCALL",
+	KeyStar:           "STAR",
+	KeyPound:          "POUND",
+	KeyDpadUp:         "DPAD_UP",
+	KeyDpadDown:       "DPAD_DOWN",
+	KeyDpadLeft:       "DPAD_LEFT",
+	KeyDpadRight:      "DPAD_RIGHT",
+	KeyDpadCenter:     "DPAD_CENTER",
+	KeyVolumeUp:       "VOLUME_UP",
+	KeyVolumeDown:     "VOLUME_DOWN",
+	KeyPower:          "POWER",
+	KeyCamera:         "CAMERA",
+	KeyClear:          "CLEAR",
+	KeyComma:          "COMMA",
+	KeyPeriod:         "PERIOD",
+	KeyTab:            "TAB",
+	KeySpace:          "SPACE",
+	KeyEnter:          "ENTER",
+	KeyDel:            "DEL",
+	KeyMenu:           "MENU",
+	KeySearch:         "SEARCH",
+	KeyMediaPlayPause: "MEDIA_PLAY_PAUSE",
+	KeyMediaStop:      "MEDIA_STOP",
+	KeyMediaNext:      "MEDIA_NEXT",
+	KeyMediaPrev:      "MEDIA_PREVIOUS",
+	KeyMute:           "MUTE",
+	KeyPageUp:         "PAGE_UP",
+	KeyPageDown:       "PAGE_DOWN",
+	KeyEscape:         "ESCAPE",
+	KeyForwardDel:     "FORWARD_DEL",
+	KeyMoveHome:       "MOVE_HOME",
+	KeyMoveEnd:        "MOVE_END",
+	KeyMediaPlay:      "MEDIA_PLAY",
+	KeyMediaPause:     "MEDIA_PAUSE",
+	KeyVolumeMute:     "VOLUME_MUTE",
+	KeyAppSwitch:      "APP_SWITCH",
+	KeyBrightDown:     "BRIGHTNESS_DOWN",
+	KeyBrightUp:       "BRIGHTNESS_UP",
+	KeySleep:          "SLEEP",
+	KeyWakeup:         "WAKEUP",
+}
+
+// String returns the key's name, as in android.view.KeyEvent, e.g. "KEYCODE_HOME".
+func (k KeyCode) String() string {
+	switch {
+	case k >= Key0 && k <= Key9:
+		return "KEYCODE_" + string(rune('0'+k-Key0))
+	case k >= KeyA && k <= KeyZ:
+		return "KEYCODE_" + string(rune('A'+k-KeyA))
+	}
+	if name, ok := keyCodeNames[k]; ok {
+		return "KEYCODE_" + name
+	}
+	return "KeyCode(" + strconv.Itoa(int(k)) + ")"
+}
+
+// ParseKeyCode returns the key named name, with or without the "KEYCODE_" prefix, e.g.
+// "KEYCODE_HOME" or "home". Returns false if the name isn't known.
+func ParseKeyCode(name string) (KeyCode, bool) {
+	name = strings.TrimPrefix(strings.ToUpper(name), "KEYCODE_")
+	if len(name) == 1 {
+		switch c := name[0]; {
+		case c >= '0' && c <= '9':
+			return Key0 + KeyCode(c-'0'), true
+		case c >= 'A' && c <= 'Z':
+			return KeyA + KeyCode(c-'A'), true
+		}
+	}
+	for k, n := range keyCodeNames {
+		if n == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,315 @@
+package adb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// LogBuffer identifies one of the device's log buffers.
+type LogBuffer uint32
+
+const (
+	LogBufferMain LogBuffer = iota
+	LogBufferRadio
+	LogBufferEvents
+	LogBufferSystem
+	LogBufferCrash
+	LogBufferStats
+	LogBufferSecurity
+	LogBufferKernel
+)
+
+// String returns the name logcat uses for the buffer, e.g. "main".
+func (b LogBuffer) String() string {
+	switch b {
+	case LogBufferMain:
+		return "main"
+	case LogBufferRadio:
+		return "radio"
+	case LogBufferEvents:
+		return "events"
+	case LogBufferSystem:
+		return "system"
+	case LogBufferCrash:
+		return "crash"
+	case LogBufferStats:
+		return "stats"
+	case LogBufferSecurity:
+		return "security"
+	case LogBufferKernel:
+		return "kernel"
+	default:
+		return "LogBuffer(" + strconv.Itoa(int(b)) + ")"
+	}
+}
+
+// isBinary returns true if entries in the buffer have a binary payload instead of a
+// priority, tag and message.
+func (b LogBuffer) isBinary() bool {
+	return b == LogBufferEvents || b == LogBufferStats || b == LogBufferSecurity
+}
+
+// LogPriority is the priority of a log entry, from LogVerbose to LogFatal.
+type LogPriority uint8
+
+const (
+	LogUnknown LogPriority = iota
+	LogDefault
+	LogVerbose
+	LogDebug
+	LogInfo
+	LogWarn
+	LogError
+	LogFatal
+	LogSilent
+)
+
+// String returns the letter logcat uses for the priority, e.g. "I" for LogInfo.
+func (p LogPriority) String() string {
+	switch p {
+	case LogVerbose:
+		return "V"
+	case LogDebug:
+		return "D"
+	case LogInfo:
+		return "I"
+	case LogWarn:
+		return "W"
+	case LogError:
+		return "E"
+	case LogFatal:
+		return "F"
+	case LogSilent:
+		return "S"
+	default:
+		return "?"
+	}
+}
+
+// LogEntry is a single entry read from the device's logs.
+type LogEntry struct {
+	// Buffer is the buffer the entry was logged to. Devices older than Android 5.0 don't
+	// report it, in which case it's the only buffer requested, or LogBufferMain.
+	Buffer LogBuffer
+
+	PID  int
+	TID  int
+	Time time.Time
+
+	// UID of the process that logged the entry, or -1 on devices older than Android 7.0.
+	UID int
+
+	Priority LogPriority
+	Tag      string
+	// Message may span several lines.
+	Message string
+
+	// Payload is the raw payload of entries from binary buffers, such as events.
+	// Priority, Tag and Message are unset for those entries.
+	// See EventDecoder for decoding entries from the events buffer.
+	Payload []byte
+}
+
+// LogcatOptions configures Device.Logcat. The zero value follows new entries in the
+// device's default buffers.
+type LogcatOptions struct {
+	// Buffers to read from. If empty, logcat reads its default buffers, usually main,
+	// system and crash.
+	Buffers []LogBuffer
+
+	// Filters are logcat filter specs such as "ActivityManager:I" or "*:S".
+	Filters []string
+
+	// Since, if set, starts reading from the first entry logged at or after this time
+	// instead of with the entries already in the buffers.
+	Since time.Time
+
+	// PID, if non-zero, only reads entries logged by that process. Requires Android 7.0.
+	PID int
+
+	// Dump reads the entries in the buffers and stops, instead of waiting for new ones.
+	Dump bool
+
+	// Clear clears the buffers before reading from them.
+	Clear bool
+}
+
+func (opts LogcatOptions) bufferArgs() []string {
+	var args []string
+	for _, b := range opts.Buffers {
+		args = append(args, "-b", b.String())
+	}
+	return args
+}
+
+func (opts LogcatOptions) args() []string {
+	args := []string{"logcat", "-B"}
+	if opts.Dump {
+		args = append(args, "-d")
+	}
+	args = append(args, opts.bufferArgs()...)
+	if !opts.Since.IsZero() {
+		ms := opts.Since.UnixMilli()
+		args = append(args, "-T", fmt.Sprintf("%d.%03d", ms/1000, ms%1000))
+	}
+	if opts.PID != 0 {
+		args = append(args, "--pid", strconv.Itoa(opts.PID))
+	}
+	return append(args, opts.Filters...)
+}
+
+/*
+Logcat reads entries from the device's logs.
+
+It runs logcat in its binary output mode, which unlike the text formats is the same on
+every device, keeps multi-line messages in one entry, and includes the UID and buffer of
+each entry.
+
+Unless opts.Dump is set, the reader keeps waiting for new entries until ctx is done or it's
+closed.
+
+Corresponds to the command:
+
+	adb logcat -B
+*/
+func (c *Device) Logcat(ctx context.Context, opts LogcatOptions) (*LogcatReader, error) {
+	if opts.Clear {
+		cmdline := shellCommandLine("logcat", append([]string{"-c"}, opts.bufferArgs()...)...)
+		result, err := c.runShellCommand(cmdline)
+		if err == nil {
+			err = commandError(cmdline, result)
+		}
+		if err != nil {
+			return nil, wrapClientError(err, c, "Logcat")
+		}
+	}
+
+	stream, err := c.Exec(ctx, opts.args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &LogcatReader{stream: stream, r: bufio.NewReader(stream)}
+	if len(opts.Buffers) == 1 {
+		r.defaultBuffer = opts.Buffers[0]
+	}
+	return r, nil
+}
+
+// LogcatReader reads entries from a logcat started by Device.Logcat.
+type LogcatReader struct {
+	stream        *CommandStream
+	r             *bufio.Reader
+	defaultBuffer LogBuffer
+}
+
+// Next returns the next entry. It returns io.EOF once logcat exits.
+func (r *LogcatReader) Next() (*LogEntry, error) {
+	entry, err := readLogEntry(r.r, r.defaultBuffer)
+	if err != nil && err != io.EOF {
+		return nil, errors.WrapErrf(err, "error reading logcat")
+	}
+	return entry, err
+}
+
+func (r *LogcatReader) Close() error {
+	return r.stream.Close()
+}
+
+// Sizes of the logger_entry header for each version. Version 1 doesn't record its
+// header size, and uses the field for padding instead.
+const (
+	logEntryHeaderV1 = 20
+	logEntryHeaderV3 = 24
+	logEntryHeaderV4 = 28
+)
+
+/*
+readLogEntry reads a logger_entry, as written by logcat -B.
+
+The header starts with the payload length and the header size, which identifies its version:
+
+	v1 (20 bytes): pid, tid, sec, nsec
+	v2 (24 bytes): pid, tid, sec, nsec, euid
+	v3 (24 bytes): pid, tid, sec, nsec, lid
+	v4 (28 bytes): pid, tid, sec, nsec, lid, uid
+
+Versions 2 and 3 have the same size. A 24-byte header is read as version 3 unless the
+field doesn't hold a valid buffer id, as the euid of version 2 usually doesn't.
+
+Headers larger than 28 bytes are accepted, and the fields that follow are ignored.
+*/
+func readLogEntry(r io.Reader, defaultBuffer LogBuffer) (*LogEntry, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, logEntryReadError(err, "header")
+	}
+	payloadLen := int(binary.LittleEndian.Uint16(prefix[0:]))
+	headerLen := int(binary.LittleEndian.Uint16(prefix[2:]))
+	if headerLen == 0 {
+		headerLen = logEntryHeaderV1
+	}
+	if headerLen < logEntryHeaderV1 {
+		return nil, errors.Errorf(errors.ParseError, "invalid log entry header size %d", headerLen)
+	}
+
+	header := make([]byte, headerLen-len(prefix))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, logEntryReadError(err, "header")
+	}
+	field := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(header[i*4:])
+	}
+
+	entry := &LogEntry{
+		Buffer: defaultBuffer,
+		PID:    int(int32(field(0))),
+		TID:    int(int32(field(1))),
+		Time:   time.Unix(int64(field(2)), int64(field(3))),
+		UID:    -1,
+	}
+	if headerLen >= logEntryHeaderV3 && (headerLen > logEntryHeaderV3 || field(4) <= uint32(LogBufferKernel)) {
+		entry.Buffer = LogBuffer(field(4))
+	}
+	if headerLen >= logEntryHeaderV4 {
+		entry.UID = int(field(5))
+	}
+
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, logEntryReadError(err, "payload")
+	}
+
+	if entry.Buffer.isBinary() {
+		entry.Payload = payload
+		return entry, nil
+	}
+	if len(payload) > 0 {
+		entry.Priority = LogPriority(payload[0])
+		payload = payload[1:]
+	}
+	tag, message, _ := bytes.Cut(payload, []byte{0})
+	entry.Tag = string(tag)
+	entry.Message = string(bytes.TrimRight(message, "\x00"))
+	return entry, nil
+}
+
+func logEntryReadError(err error, part string) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.WrapErrorf(err, errors.ConnectionResetError, "incomplete log entry %s", part)
+	}
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error reading log entry %s", part)
+}
@@ -0,0 +1,147 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logEntryBytes encodes a logger_entry with a header of headerLen bytes.
+// fields are the header fields following the length and header size.
+func logEntryBytes(headerLen int, payload []byte, fields ...uint32) []byte {
+	var b bytes.Buffer
+	hdrSize := uint16(headerLen)
+	if headerLen == logEntryHeaderV1 {
+		hdrSize = 0
+	}
+	binary.Write(&b, binary.LittleEndian, uint16(len(payload)))
+	binary.Write(&b, binary.LittleEndian, hdrSize)
+	binary.Write(&b, binary.LittleEndian, fields)
+	for b.Len() < headerLen {
+		b.WriteByte(0)
+	}
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func textLogPayload(priority LogPriority, tag, message string) []byte {
+	return append([]byte{byte(priority)}, tag+"\x00"+message+"\x00"...)
+}
+
+func TestReadLogEntryV4(t *testing.T) {
+	data := logEntryBytes(logEntryHeaderV4, textLogPayload(LogWarn, "ActivityManager", "line one\nline two"),
+		1234, 1240, 1700000000, 500, uint32(LogBufferSystem), 1000)
+
+	entry, err := readLogEntry(bytes.NewReader(data), LogBufferMain)
+	require.NoError(t, err)
+	assert.Equal(t, &LogEntry{
+		Buffer:   LogBufferSystem,
+		PID:      1234,
+		TID:      1240,
+		Time:     time.Unix(1700000000, 500),
+		UID:      1000,
+		Priority: LogWarn,
+		Tag:      "ActivityManager",
+		Message:  "line one\nline two",
+	}, entry)
+	assert.Equal(t, "W", entry.Priority.String())
+	assert.Equal(t, "system", entry.Buffer.String())
+}
+
+func TestReadLogEntryOlderVersions(t *testing.T) {
+	payload := textLogPayload(LogInfo, "tag", "msg")
+
+	entry, err := readLogEntry(bytes.NewReader(logEntryBytes(logEntryHeaderV1, payload, 1, 2, 3, 4)), LogBufferRadio)
+	require.NoError(t, err)
+	assert.Equal(t, LogBufferRadio, entry.Buffer)
+	assert.Equal(t, -1, entry.UID)
+	assert.Equal(t, time.Unix(3, 4), entry.Time)
+	assert.Equal(t, "msg", entry.Message)
+
+	entry, err = readLogEntry(bytes.NewReader(logEntryBytes(logEntryHeaderV3, payload, 1, 2, 3, 4, uint32(LogBufferCrash))), LogBufferMain)
+	require.NoError(t, err)
+	assert.Equal(t, LogBufferCrash, entry.Buffer)
+	assert.Equal(t, -1, entry.UID)
+
+	// Version 2 holds the euid where version 3 has the buffer id.
+	entry, err = readLogEntry(bytes.NewReader(logEntryBytes(logEntryHeaderV3, payload, 1, 2, 3, 4, 2000)), LogBufferMain)
+	require.NoError(t, err)
+	assert.Equal(t, LogBufferMain, entry.Buffer)
+
+	// Unknown fields in larger headers are skipped.
+	entry, err = readLogEntry(bytes.NewReader(logEntryBytes(32, payload, 1, 2, 3, 4, 0, 10, 99)), LogBufferMain)
+	require.NoError(t, err)
+	assert.Equal(t, 10, entry.UID)
+	assert.Equal(t, "tag", entry.Tag)
+}
+
+func TestReadLogEntryBinaryBuffer(t *testing.T) {
+	payload := []byte{0x10, 0x27, 0, 0, 0, 1, 0, 0, 0}
+	data := logEntryBytes(logEntryHeaderV4, payload, 1, 2, 3, 4, uint32(LogBufferEvents), 1000)
+
+	entry, err := readLogEntry(bytes.NewReader(data), LogBufferMain)
+	require.NoError(t, err)
+	assert.Equal(t, payload, entry.Payload)
+	assert.Empty(t, entry.Tag)
+	assert.Equal(t, LogUnknown, entry.Priority)
+}
+
+func TestReadLogEntryErrors(t *testing.T) {
+	_, err := readLogEntry(bytes.NewReader(nil), LogBufferMain)
+	assert.Equal(t, io.EOF, err)
+
+	data := logEntryBytes(logEntryHeaderV4, textLogPayload(LogInfo, "tag", "msg"), 1, 2, 3, 4, 0, 0)
+	_, err = readLogEntry(bytes.NewReader(data[:len(data)-2]), LogBufferMain)
+	assert.True(t, HasErrCode(err, ConnectionResetError))
+
+	_, err = readLogEntry(bytes.NewReader([]byte{0, 0, 8, 0}), LogBufferMain)
+	assert.True(t, HasErrCode(err, ParseError))
+}
+
+func TestLogcat(t *testing.T) {
+	d := newFakeDevice()
+	d.handleShell([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return "", "", 0
+	})
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		conn.Write(logEntryBytes(logEntryHeaderV4, textLogPayload(LogInfo, "a", "first"), 1, 1, 1, 0, 0, 0))
+		conn.Write(logEntryBytes(logEntryHeaderV4, textLogPayload(LogError, "b", "second\r\n"), 2, 2, 2, 0, 0, 0))
+	}
+	device := newFakeDeviceClient(d)
+
+	r, err := device.Logcat(context.Background(), LogcatOptions{
+		Buffers: []LogBuffer{LogBufferMain, LogBufferCrash},
+		Filters: []string{"ActivityManager:I", "*:S"},
+		Since:   time.UnixMilli(1700000000123),
+		PID:     42,
+		Dump:    true,
+		Clear:   true,
+	})
+	require.NoError(t, err)
+
+	var messages []string
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		messages = append(messages, entry.Message)
+	}
+	require.NoError(t, r.Close())
+	d.wait()
+
+	assert.Equal(t, []string{"first", "second\r\n"}, messages)
+	assert.Equal(t, []string{
+		"host-serial:serial:features",
+		"shell,v2,raw:logcat -c -b main -b crash",
+		"exec:logcat -B -d -b main -b crash -T 1700000000.123 --pid 42 ActivityManager:I '*:S'",
+	}, d.requests)
+}
@@ -0,0 +1,82 @@
+package adb
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/mdns"
+)
+
+/*
+MdnsCheck returns the status of the adb server's mDNS discovery, e.g.
+"mdns daemon version [Openscreen discovery 0.0.0]". Returns an AdbError if discovery is
+disabled.
+
+Corresponds to the command:
+
+	adb mdns check
+*/
+func (c *Adb) MdnsCheck() (string, error) {
+	resp, err := roundTripSingleResponse(c.Server, "host:mdns:check")
+	if err != nil {
+		return "", wrapClientError(err, c, "MdnsCheck")
+	}
+	return strings.TrimSpace(string(resp)), nil
+}
+
+/*
+MdnsServices returns the adb services the server has discovered over mDNS. The services
+only have an Instance, Type, Port and a single address. To discover services without the
+server, use an mdns.Browser.
+
+Corresponds to the command:
+
+	adb mdns services
+*/
+func (c *Adb) MdnsServices() ([]mdns.Service, error) {
+	resp, err := roundTripSingleResponse(c.Server, "host:mdns:services")
+	if err != nil {
+		return nil, wrapClientError(err, c, "MdnsServices")
+	}
+	services, err := parseMdnsServices(string(resp))
+	return services, wrapClientError(err, c, "MdnsServices")
+}
+
+// parseMdnsServices parses the server's list of services, which has a line for each:
+//
+//	instance<tab>type<tab>ip:port
+func parseMdnsServices(resp string) ([]mdns.Service, error) {
+	var services []mdns.Service
+	for _, line := range strings.Split(resp, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, errors.Errorf(errors.ParseError, "invalid mdns service line: %q", line)
+		}
+
+		addr := fields[2]
+		i := strings.LastIndexByte(addr, ':')
+		if i < 0 {
+			return nil, errors.Errorf(errors.ParseError, "invalid mdns service address: %q", addr)
+		}
+		ip := net.ParseIP(strings.Trim(addr[:i], "[]"))
+		port, err := strconv.Atoi(addr[i+1:])
+		if ip == nil || err != nil {
+			return nil, errors.Errorf(errors.ParseError, "invalid mdns service address: %q", addr)
+		}
+
+		serviceType := strings.TrimSuffix(strings.TrimSuffix(fields[1], "."), "."+mdns.Domain)
+		services = append(services, mdns.Service{
+			Instance: fields[0],
+			Type:     serviceType,
+			Port:     port,
+			Addrs:    []net.IP{ip},
+		})
+	}
+	return services, nil
+}
@@ -0,0 +1,455 @@
+package mdns
+
+import (
+	"context"
+	"log"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// DefaultGroup is the IPv4 mDNS multicast group.
+var DefaultGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+const (
+	defaultQueryInterval = time.Second
+	maxQueryInterval     = time.Minute
+	maxPacketSize        = 9000
+)
+
+// expiryCheckInterval is how often the Browser checks for services whose records expired.
+var expiryCheckInterval = time.Second
+
+// EventType is the kind of change an Event reports.
+type EventType int
+
+const (
+	// ServiceAdded is sent when a service is first resolved to an address.
+	ServiceAdded EventType = iota
+	// ServiceUpdated is sent when a service's host, port, addresses or TXT record change.
+	ServiceUpdated
+	// ServiceRemoved is sent when a service is withdrawn, or its records expire.
+	ServiceRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case ServiceAdded:
+		return "ServiceAdded"
+	case ServiceUpdated:
+		return "ServiceUpdated"
+	case ServiceRemoved:
+		return "ServiceRemoved"
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Event reports a change to a service. For ServiceRemoved, Service is the service as it was
+// last reported.
+type Event struct {
+	Type    EventType
+	Service Service
+}
+
+// BrowserConfig configures a Browser. The zero value browses for ServiceTLSConnect and
+// ServiceTLSPairing on the default multicast interface.
+type BrowserConfig struct {
+	// Services are the service types to browse for.
+	Services []string
+
+	// Interface to browse on. If nil, the system chooses one.
+	Interface *net.Interface
+
+	// Group is the multicast address to query and listen on. Defaults to DefaultGroup.
+	Group *net.UDPAddr
+
+	// QueryInterval is the delay before the first repeated query. It doubles after each
+	// query, up to a minute. Defaults to one second.
+	QueryInterval time.Duration
+}
+
+/*
+Browser discovers services advertised over mDNS, and publishes events as they appear, change
+and disappear. Only IPv4 is supported.
+
+Like DeviceWatcher, events are sent on an unbuffered channel, so C must be received from
+until it is closed, or Shutdown called.
+*/
+type Browser struct {
+	config BrowserConfig
+	conn   *net.UDPConn
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	err       atomic.Value
+	eventChan chan Event
+
+	mu sync.Mutex
+	// services is keyed by the lower-case name of each instance.
+	services map[string]*browsedService
+	// hosts maps lower-case host names to their addresses.
+	hosts map[string][]hostAddr
+}
+
+// hostAddr is an address from an A or AAAA record, valid until expires.
+type hostAddr struct {
+	ip      net.IP
+	expires time.Time
+}
+
+type browsedService struct {
+	// service is built up from the records received, and reported is the service as it was
+	// last published, if published is true.
+	service   Service
+	reported  Service
+	published bool
+	expires   time.Time
+}
+
+// NewBrowser starts browsing. Call Shutdown to stop.
+func NewBrowser(config BrowserConfig) (*Browser, error) {
+	if len(config.Services) == 0 {
+		config.Services = []string{ServiceTLSConnect, ServiceTLSPairing}
+	}
+	if config.Group == nil {
+		config.Group = DefaultGroup
+	}
+	if config.QueryInterval <= 0 {
+		config.QueryInterval = defaultQueryInterval
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", config.Interface, config.Group)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.NetworkError, "error joining multicast group %s", config.Group)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Browser{
+		config:    config,
+		conn:      conn,
+		ctx:       ctx,
+		cancel:    cancel,
+		eventChan: make(chan Event),
+		services:  make(map[string]*browsedService),
+		hosts:     make(map[string][]hostAddr),
+	}
+
+	messages := make(chan *message)
+	go b.readMessages(messages)
+	go b.run(messages)
+	return b, nil
+}
+
+// C returns the channel events are published on. It is closed when the Browser is shut
+// down, or an unrecoverable error occurs.
+func (b *Browser) C() <-chan Event {
+	return b.eventChan
+}
+
+// Err returns the error that caused the channel returned by C to be closed, if C is closed
+// and Shutdown wasn't called.
+func (b *Browser) Err() error {
+	if err, ok := b.err.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Services returns the services currently known, in no particular order.
+func (b *Browser) Services() []Service {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var services []Service
+	for _, s := range b.services {
+		if s.published {
+			services = append(services, s.reported)
+		}
+	}
+	return services
+}
+
+// Shutdown stops browsing and closes the channel returned by C.
+func (b *Browser) Shutdown() {
+	b.cancel()
+	if err := b.conn.Close(); err != nil {
+		log.Printf("[mdns.Browser] error closing connection: %s", err)
+	}
+}
+
+// readMessages reads responses from the connection until it's closed.
+func (b *Browser) readMessages(messages chan<- *message) {
+	defer close(messages)
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			if b.ctx.Err() == nil {
+				b.err.Store(errors.WrapErrorf(err, errors.NetworkError, "error reading mDNS response"))
+			}
+			return
+		}
+
+		m, err := parseMessage(buf[:n])
+		if err != nil {
+			log.Printf("[mdns.Browser] ignoring invalid packet: %s", err)
+			continue
+		}
+		if !m.Response {
+			continue
+		}
+		select {
+		case messages <- m:
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Browser) run(messages <-chan *message) {
+	defer close(b.eventChan)
+
+	queryTimer := time.NewTimer(0)
+	defer queryTimer.Stop()
+	expiryTicker := time.NewTicker(expiryCheckInterval)
+	defer expiryTicker.Stop()
+
+	var browse []question
+	for _, service := range b.config.Services {
+		browse = append(browse, question{Name: service + "." + Domain, Type: typePTR})
+	}
+
+	interval := b.config.QueryInterval
+	for {
+		var events []Event
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-queryTimer.C:
+			b.query(browse)
+			queryTimer.Reset(interval)
+			interval = min(2*interval, maxQueryInterval)
+		case <-expiryTicker.C:
+			events = b.expire(time.Now())
+		case m, ok := <-messages:
+			if !ok {
+				return
+			}
+			var followUps []question
+			events, followUps = b.handleResponse(m, time.Now())
+			if len(followUps) > 0 {
+				b.query(followUps)
+			}
+		}
+
+		for _, event := range events {
+			select {
+			case b.eventChan <- event:
+			case <-b.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (b *Browser) query(questions []question) {
+	packet := (&message{Questions: questions}).pack()
+	if _, err := b.conn.WriteToUDP(packet, b.config.Group); err != nil && b.ctx.Err() == nil {
+		log.Printf("[mdns.Browser] error sending query: %s", err)
+	}
+}
+
+/*
+handleResponse applies the records in m, and returns the resulting events. It also returns
+questions for the records still needed to resolve the services m mentions, for responders
+that don't include them all in one response.
+*/
+func (b *Browser) handleResponse(m *message, now time.Time) ([]Event, []question) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var events []Event
+	mentioned := make(map[string]bool)
+
+	// Handle PTRs first, so that the other records in the response apply to new instances.
+	for _, r := range m.Records {
+		if r.Type != typePTR {
+			continue
+		}
+		serviceType, ok := b.serviceType(r.Name)
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(r.Target)
+		if r.TTL == 0 {
+			events = b.remove(key, events)
+			continue
+		}
+
+		s, ok := b.services[key]
+		if !ok {
+			suffix := "." + serviceType + "." + Domain
+			if len(r.Target) <= len(suffix) {
+				continue
+			}
+			s = &browsedService{service: Service{
+				Instance: r.Target[:len(r.Target)-len(suffix)],
+				Type:     serviceType,
+			}}
+			b.services[key] = s
+		}
+		s.expires = now.Add(time.Duration(r.TTL) * time.Second)
+		mentioned[key] = true
+	}
+
+	for _, r := range m.Records {
+		key := strings.ToLower(r.Name)
+		switch r.Type {
+		case typeSRV:
+			if _, ok := b.services[key]; !ok {
+				continue
+			}
+			if r.TTL == 0 {
+				events = b.remove(key, events)
+				continue
+			}
+			b.services[key].service.Host = r.Target
+			b.services[key].service.Port = int(r.Port)
+			mentioned[key] = true
+		case typeTXT:
+			if s, ok := b.services[key]; ok {
+				s.service.TXT = parseTXT(r.Text)
+				mentioned[key] = true
+			}
+		case typeA, typeAAAA:
+			b.updateHost(key, r.IP, r.TTL, now)
+		}
+	}
+
+	return b.publish(events, mentioned)
+}
+
+/*
+publish compares each service with what was last published, and returns events for those
+that changed. It also returns questions for the records still needed to resolve the services
+in mentioned.
+*/
+func (b *Browser) publish(events []Event, mentioned map[string]bool) ([]Event, []question) {
+	var followUps []question
+	for key, s := range b.services {
+		updated := s.service
+		updated.Addrs = b.hostIPs(updated.Host)
+		complete := updated.Port != 0 && len(updated.Addrs) > 0
+
+		switch {
+		case complete && !s.published:
+			s.reported, s.published = updated, true
+			events = append(events, Event{ServiceAdded, updated})
+		case complete && !reflect.DeepEqual(updated, s.reported):
+			s.reported = updated
+			events = append(events, Event{ServiceUpdated, updated})
+		case !complete && s.published:
+			// E.g. its host's addresses expired.
+			s.published = false
+			events = append(events, Event{ServiceRemoved, s.reported})
+		}
+		if !complete && mentioned[key] {
+			if updated.Host == "" {
+				followUps = append(followUps,
+					question{Name: updated.Name(), Type: typeSRV},
+					question{Name: updated.Name(), Type: typeTXT})
+			} else {
+				followUps = append(followUps, question{Name: updated.Host, Type: typeA})
+			}
+		}
+	}
+	return events, followUps
+}
+
+// expire removes the services whose PTR records have expired, and the addresses whose A or
+// AAAA records have expired.
+func (b *Browser) expire(now time.Time) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var events []Event
+	for key, s := range b.services {
+		if now.After(s.expires) {
+			events = b.remove(key, events)
+		}
+	}
+	for host, addrs := range b.hosts {
+		var valid []hostAddr
+		for _, addr := range addrs {
+			if !now.After(addr.expires) {
+				valid = append(valid, addr)
+			}
+		}
+		if len(valid) == 0 {
+			delete(b.hosts, host)
+		} else {
+			b.hosts[host] = valid
+		}
+	}
+	events, _ = b.publish(events, nil)
+	return events
+}
+
+func (b *Browser) remove(key string, events []Event) []Event {
+	s, ok := b.services[key]
+	if !ok {
+		return events
+	}
+	delete(b.services, key)
+	if s.published {
+		events = append(events, Event{ServiceRemoved, s.reported})
+	}
+	return events
+}
+
+// updateHost adds or refreshes ip as an address of host for ttl seconds, or removes it if
+// ttl is 0.
+func (b *Browser) updateHost(host string, ip net.IP, ttl uint32, now time.Time) {
+	addrs := b.hosts[host]
+	expires := now.Add(time.Duration(ttl) * time.Second)
+	for i, addr := range addrs {
+		if addr.ip.Equal(ip) {
+			if ttl == 0 {
+				b.hosts[host] = append(addrs[:i:i], addrs[i+1:]...)
+			} else {
+				addrs[i].expires = expires
+			}
+			return
+		}
+	}
+	if ttl != 0 {
+		b.hosts[host] = append(addrs[:len(addrs):len(addrs)], hostAddr{ip, expires})
+	}
+}
+
+// hostIPs returns the addresses of host.
+func (b *Browser) hostIPs(host string) []net.IP {
+	var ips []net.IP
+	for _, addr := range b.hosts[strings.ToLower(host)] {
+		ips = append(ips, addr.ip)
+	}
+	return ips
+}
+
+// serviceType returns the service type that name, a PTR record's name, browses, if it's
+// one of the types being browsed for.
+func (b *Browser) serviceType(name string) (string, bool) {
+	for _, service := range b.config.Services {
+		if strings.EqualFold(name, service+"."+Domain) {
+			return service, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,251 @@
+package mdns
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loopbackGroup returns the loopback interface and a multicast group on a free port, or
+// skips the test if multicast doesn't work on loopback.
+func loopbackGroup(t *testing.T) (*net.Interface, *net.UDPAddr) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		interfaces, _ := net.Interfaces()
+		for i := range interfaces {
+			if interfaces[i].Flags&net.FlagLoopback != 0 {
+				lo, err = &interfaces[i], nil
+				break
+			}
+		}
+	}
+	if err != nil || lo == nil {
+		t.Skip("no loopback interface")
+	}
+
+	free, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	group := &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: free.LocalAddr().(*net.UDPAddr).Port}
+	free.Close()
+
+	conn, err := net.ListenMulticastUDP("udp4", lo, group)
+	if err != nil {
+		t.Skipf("multicast not supported on loopback: %s", err)
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP([]byte("probe"), group); err != nil {
+		t.Skipf("multicast not supported on loopback: %s", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadFromUDP(make([]byte, 16)); err != nil {
+		t.Skipf("multicast not supported on loopback: %s", err)
+	}
+	return lo, group
+}
+
+// responder stands in for a device advertising services. It answers queries with
+// respond's records.
+type responder struct {
+	t       *testing.T
+	conn    *net.UDPConn
+	group   *net.UDPAddr
+	queries chan question
+}
+
+func newResponder(t *testing.T, lo *net.Interface, group *net.UDPAddr, respond func(q question) []record) *responder {
+	conn, err := net.ListenMulticastUDP("udp4", lo, group)
+	require.NoError(t, err)
+	r := &responder{t: t, conn: conn, group: group, queries: make(chan question, 100)}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, maxPacketSize)
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			m, err := parseMessage(buf[:n])
+			if err != nil || m.Response {
+				continue
+			}
+			for _, q := range m.Questions {
+				select {
+				case r.queries <- q:
+				default:
+				}
+				if records := respond(q); len(records) > 0 {
+					r.send(records...)
+				}
+			}
+		}
+	}()
+	return r
+}
+
+func (r *responder) send(records ...record) {
+	_, err := r.conn.WriteToUDP((&message{Response: true, Records: records}).pack(), r.group)
+	assert.NoError(r.t, err)
+}
+
+func newTestBrowser(t *testing.T, lo *net.Interface, group *net.UDPAddr) *Browser {
+	browser, err := NewBrowser(BrowserConfig{Interface: lo, Group: group, QueryInterval: 50 * time.Millisecond})
+	require.NoError(t, err)
+	t.Cleanup(browser.Shutdown)
+	return browser
+}
+
+func nextEvent(t *testing.T, browser *Browser) Event {
+	select {
+	case event, ok := <-browser.C():
+		require.True(t, ok, "channel closed: %v", browser.Err())
+		return event
+	case <-time.After(5 * time.Second):
+		t.Helper()
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+const (
+	testInstance = "adb-R58M1234-AbCdEf"
+	testName     = testInstance + "." + ServiceTLSConnect + "." + Domain
+)
+
+func deviceRecords(ttl uint32, port uint16) []record {
+	return []record{
+		{Name: ServiceTLSConnect + "." + Domain, Type: typePTR, TTL: ttl, Target: testName},
+		{Name: testName, Type: typeSRV, TTL: ttl, Target: "Android.local", Port: port},
+		{Name: testName, Type: typeTXT, TTL: ttl, Text: []string{"v=ADB_SECURE_SERVICE_VERSION"}},
+		{Name: "Android.local", Type: typeA, TTL: ttl, IP: net.IPv4(192, 168, 1, 5).To4()},
+	}
+}
+
+func TestBrowser(t *testing.T) {
+	lo, group := loopbackGroup(t)
+	var answered atomic.Bool
+	r := newResponder(t, lo, group, func(q question) []record {
+		// Only answer once, so that later answers don't undo the changes sent below.
+		if strings.EqualFold(q.Name, ServiceTLSConnect+"."+Domain) && !answered.Swap(true) {
+			return deviceRecords(120, 37099)
+		}
+		return nil
+	})
+	browser := newTestBrowser(t, lo, group)
+
+	expected := Service{
+		Instance: testInstance,
+		Type:     ServiceTLSConnect,
+		Host:     "Android.local",
+		Port:     37099,
+		Addrs:    []net.IP{net.IPv4(192, 168, 1, 5).To4()},
+		TXT:      map[string]string{"v": "ADB_SECURE_SERVICE_VERSION"},
+	}
+	assert.Equal(t, Event{ServiceAdded, expected}, nextEvent(t, browser))
+	assert.Equal(t, "192.168.1.5:37099", expected.Addr())
+	assert.Equal(t, []Service{expected}, browser.Services())
+
+	// The device restarts adbd on a new port.
+	r.send(deviceRecords(120, 40001)[1])
+	expected.Port = 40001
+	assert.Equal(t, Event{ServiceUpdated, expected}, nextEvent(t, browser))
+
+	// Goodbye.
+	r.send(deviceRecords(0, 40001)[0])
+	assert.Equal(t, Event{ServiceRemoved, expected}, nextEvent(t, browser))
+	assert.Empty(t, browser.Services())
+
+	browser.Shutdown()
+	_, ok := <-browser.C()
+	assert.False(t, ok)
+	assert.NoError(t, browser.Err())
+}
+
+func TestBrowserResolvesPartialResponses(t *testing.T) {
+	lo, group := loopbackGroup(t)
+	records := deviceRecords(120, 37099)
+	newResponder(t, lo, group, func(q question) []record {
+		switch {
+		case q.Type == typePTR && strings.EqualFold(q.Name, ServiceTLSConnect+"."+Domain):
+			return records[:1]
+		case q.Type == typeSRV && strings.EqualFold(q.Name, testName):
+			return records[1:2]
+		case q.Type == typeA && strings.EqualFold(q.Name, "Android.local"):
+			return records[3:]
+		}
+		return nil
+	})
+	browser := newTestBrowser(t, lo, group)
+
+	event := nextEvent(t, browser)
+	assert.Equal(t, ServiceAdded, event.Type)
+	assert.Equal(t, "192.168.1.5:37099", event.Service.Addr())
+}
+
+func TestBrowserExpiresServices(t *testing.T) {
+	defer func(interval time.Duration) { expiryCheckInterval = interval }(expiryCheckInterval)
+	expiryCheckInterval = 50 * time.Millisecond
+
+	lo, group := loopbackGroup(t)
+	r := newResponder(t, lo, group, func(q question) []record { return nil })
+	browser := newTestBrowser(t, lo, group)
+
+	r.send(deviceRecords(1, 37099)...)
+	assert.Equal(t, ServiceAdded, nextEvent(t, browser).Type)
+	assert.Equal(t, ServiceRemoved, nextEvent(t, browser).Type)
+}
+
+func TestBrowserIgnoresOtherServices(t *testing.T) {
+	lo, group := loopbackGroup(t)
+	r := newResponder(t, lo, group, func(q question) []record { return nil })
+	browser, err := NewBrowser(BrowserConfig{Services: []string{ServiceTLSPairing}, Interface: lo, Group: group})
+	require.NoError(t, err)
+	defer browser.Shutdown()
+
+	select {
+	case q := <-r.queries:
+		assert.Equal(t, question{Name: ServiceTLSPairing + "." + Domain, Type: typePTR}, q)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for query")
+	}
+
+	r.send(deviceRecords(120, 37099)...)
+	select {
+	case event := <-browser.C():
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestBrowserExpiresAddresses(t *testing.T) {
+	b := &Browser{
+		config:   BrowserConfig{Services: []string{ServiceTLSConnect}},
+		services: make(map[string]*browsedService),
+		hosts:    make(map[string][]hostAddr),
+	}
+	now := time.Now()
+	records := append(deviceRecords(120, 37099),
+		record{Name: "Android.local", Type: typeA, TTL: 10, IP: net.IPv4(10, 0, 0, 7).To4()})
+	records[3].TTL = 60
+
+	events, _ := b.handleResponse(&message{Response: true, Records: records}, now)
+	require.Len(t, events, 1)
+	assert.Equal(t, ServiceAdded, events[0].Type)
+	assert.Len(t, events[0].Service.Addrs, 2)
+
+	events = b.expire(now.Add(30 * time.Second))
+	require.Len(t, events, 1)
+	assert.Equal(t, ServiceUpdated, events[0].Type)
+	assert.Equal(t, []net.IP{net.IPv4(192, 168, 1, 5).To4()}, events[0].Service.Addrs)
+
+	events = b.expire(now.Add(90 * time.Second))
+	require.Len(t, events, 1)
+	assert.Equal(t, ServiceRemoved, events[0].Type)
+	assert.Empty(t, b.Services())
+	assert.Empty(t, b.hosts)
+}
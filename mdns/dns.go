@@ -0,0 +1,246 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// DNS record types used by DNS-SD.
+const (
+	typeA    uint16 = 1
+	typePTR  uint16 = 12
+	typeTXT  uint16 = 16
+	typeAAAA uint16 = 28
+	typeSRV  uint16 = 33
+
+	classIN uint16 = 1
+
+	flagResponse uint16 = 1 << 15
+
+	headerSize     = 12
+	maxPointerHops = 16
+)
+
+type question struct {
+	Name string
+	Type uint16
+}
+
+/*
+record is a resource record. Only the fields for its type are set:
+
+	PTR: Target
+	SRV: Target, Port
+	TXT: Text
+	A, AAAA: IP
+*/
+type record struct {
+	Name string
+	Type uint16
+	TTL  uint32
+
+	Target string
+	Port   uint16
+	Text   []string
+	IP     net.IP
+}
+
+// message is a DNS message. The records of the answer, authority and additional sections
+// are all kept together, since mDNS responders put related records in any of them.
+type message struct {
+	Response  bool
+	Questions []question
+	Records   []record
+}
+
+// pack encodes m without name compression. Only questions and PTR, SRV, TXT and address
+// records can be encoded.
+func (m *message) pack() []byte {
+	buf := make([]byte, headerSize, 512)
+	if m.Response {
+		// Responses are authoritative.
+		binary.BigEndian.PutUint16(buf[2:], flagResponse|1<<10)
+	}
+	binary.BigEndian.PutUint16(buf[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(buf[6:], uint16(len(m.Records)))
+
+	for _, q := range m.Questions {
+		buf = appendName(buf, q.Name)
+		buf = binary.BigEndian.AppendUint16(buf, q.Type)
+		buf = binary.BigEndian.AppendUint16(buf, classIN)
+	}
+	for _, r := range m.Records {
+		buf = appendName(buf, r.Name)
+		buf = binary.BigEndian.AppendUint16(buf, r.Type)
+		buf = binary.BigEndian.AppendUint16(buf, classIN)
+		buf = binary.BigEndian.AppendUint32(buf, r.TTL)
+
+		lengthAt := len(buf)
+		buf = append(buf, 0, 0)
+		switch r.Type {
+		case typePTR:
+			buf = appendName(buf, r.Target)
+		case typeSRV:
+			buf = append(buf, 0, 0, 0, 0) // Priority and weight.
+			buf = binary.BigEndian.AppendUint16(buf, r.Port)
+			buf = appendName(buf, r.Target)
+		case typeTXT:
+			for _, s := range r.Text {
+				buf = append(buf, byte(len(s)))
+				buf = append(buf, s...)
+			}
+		case typeA:
+			buf = append(buf, r.IP.To4()...)
+		case typeAAAA:
+			buf = append(buf, r.IP.To16()...)
+		}
+		binary.BigEndian.PutUint16(buf[lengthAt:], uint16(len(buf)-lengthAt-2))
+	}
+	return buf
+}
+
+func appendName(buf []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0)
+}
+
+// parseMessage decodes a DNS message. Records of types other than PTR, SRV, TXT, A and AAAA
+// are skipped.
+func parseMessage(data []byte) (*message, error) {
+	if len(data) < headerSize {
+		return nil, errors.Errorf(errors.ParseError, "DNS message too short: %d bytes", len(data))
+	}
+	m := &message{Response: binary.BigEndian.Uint16(data[2:])&flagResponse != 0}
+	questions := int(binary.BigEndian.Uint16(data[4:]))
+	records := int(binary.BigEndian.Uint16(data[6:])) + int(binary.BigEndian.Uint16(data[8:])) +
+		int(binary.BigEndian.Uint16(data[10:]))
+
+	offset := headerSize
+	for i := 0; i < questions; i++ {
+		name, next, err := readName(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(data) {
+			return nil, errors.Errorf(errors.ParseError, "truncated DNS question")
+		}
+		m.Questions = append(m.Questions, question{Name: name, Type: binary.BigEndian.Uint16(data[next:])})
+		offset = next + 4
+	}
+
+	for i := 0; i < records; i++ {
+		name, next, err := readName(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(data) {
+			return nil, errors.Errorf(errors.ParseError, "truncated DNS record")
+		}
+		r := record{
+			Name: name,
+			Type: binary.BigEndian.Uint16(data[next:]),
+			TTL:  binary.BigEndian.Uint32(data[next+4:]),
+		}
+		length := int(binary.BigEndian.Uint16(data[next+8:]))
+		start := next + 10
+		if start+length > len(data) {
+			return nil, errors.Errorf(errors.ParseError, "truncated DNS record data")
+		}
+		offset = start + length
+
+		if err := r.parseData(data, start, length); err != nil {
+			return nil, err
+		}
+		if r.Type == typePTR || r.Type == typeSRV || r.Type == typeTXT || r.Type == typeA || r.Type == typeAAAA {
+			m.Records = append(m.Records, r)
+		}
+	}
+	return m, nil
+}
+
+// parseData decodes the record's data, which is at data[start:start+length]. Names in it can
+// point anywhere in the message.
+func (r *record) parseData(data []byte, start, length int) error {
+	rdata := data[start : start+length]
+	var err error
+	switch r.Type {
+	case typePTR:
+		r.Target, _, err = readName(data, start)
+	case typeSRV:
+		if length < 7 {
+			return errors.Errorf(errors.ParseError, "SRV record too short")
+		}
+		r.Port = binary.BigEndian.Uint16(rdata[4:])
+		r.Target, _, err = readName(data, start+6)
+	case typeTXT:
+		for i := 0; i < len(rdata); {
+			n := int(rdata[i])
+			if i+1+n > len(rdata) {
+				return errors.Errorf(errors.ParseError, "truncated TXT record")
+			}
+			if n > 0 {
+				r.Text = append(r.Text, string(rdata[i+1:i+1+n]))
+			}
+			i += 1 + n
+		}
+	case typeA:
+		if length != net.IPv4len {
+			return errors.Errorf(errors.ParseError, "invalid A record length %d", length)
+		}
+		r.IP = net.IP(append([]byte(nil), rdata...))
+	case typeAAAA:
+		if length != net.IPv6len {
+			return errors.Errorf(errors.ParseError, "invalid AAAA record length %d", length)
+		}
+		r.IP = net.IP(append([]byte(nil), rdata...))
+	}
+	return err
+}
+
+// readName reads the possibly compressed name at offset, and returns it along with the
+// offset just after it.
+func readName(data []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; ; {
+		if offset >= len(data) {
+			return "", 0, errors.Errorf(errors.ParseError, "truncated DNS name")
+		}
+		length := int(data[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(data) {
+				return "", 0, errors.Errorf(errors.ParseError, "truncated DNS name pointer")
+			}
+			if hops++; hops > maxPointerHops {
+				return "", 0, errors.Errorf(errors.ParseError, "too many DNS name pointers")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(data[offset:]) & 0x3fff)
+		case length&0xc0 != 0:
+			return "", 0, errors.Errorf(errors.ParseError, "invalid DNS label length %#x", length)
+		default:
+			if offset+1+length > len(data) {
+				return "", 0, errors.Errorf(errors.ParseError, "truncated DNS label")
+			}
+			labels = append(labels, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := &message{
+		Response:  true,
+		Questions: []question{{Name: "_adb-tls-connect._tcp.local", Type: typePTR}},
+		Records: []record{
+			{Name: "_adb-tls-connect._tcp.local", Type: typePTR, TTL: 120, Target: "adb-1._adb-tls-connect._tcp.local"},
+			{Name: "adb-1._adb-tls-connect._tcp.local", Type: typeSRV, TTL: 120, Target: "Android.local", Port: 37099},
+			{Name: "adb-1._adb-tls-connect._tcp.local", Type: typeTXT, TTL: 120, Text: []string{"v=ADB_SECURE_SERVICE_VERSION"}},
+			{Name: "Android.local", Type: typeA, TTL: 120, IP: net.IPv4(192, 168, 1, 5).To4()},
+			{Name: "Android.local", Type: typeAAAA, TTL: 120, IP: net.ParseIP("fe80::1")},
+		},
+	}
+
+	parsed, err := parseMessage(m.pack())
+	require.NoError(t, err)
+	assert.Equal(t, m, parsed)
+}
+
+func TestParseMessageCompressedNames(t *testing.T) {
+	data := []byte{
+		0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0,
+		// _adb._tcp.local at offset 12.
+		4, '_', 'a', 'd', 'b', 4, '_', 't', 'c', 'p', 5, 'l', 'o', 'c', 'a', 'l', 0,
+		0, 12, 0, 1, 0, 0, 0, 60, 0, 7,
+		// emu-1 followed by a pointer to offset 12.
+		5, 'e', 'm', 'u', '-', '1', 0xc0, 12,
+	}
+
+	m, err := parseMessage(data)
+	require.NoError(t, err)
+	assert.True(t, m.Response)
+	require.Len(t, m.Records, 1)
+	assert.Equal(t, record{Name: "_adb._tcp.local", Type: typePTR, TTL: 60, Target: "emu-1._adb._tcp.local"}, m.Records[0])
+}
+
+func TestParseMessageSkipsOtherTypes(t *testing.T) {
+	data := (&message{Response: true, Records: []record{{Name: "host.local", Type: typeA, IP: net.IPv4(10, 0, 0, 1).To4()}}}).pack()
+	// Change the record's type to NSEC.
+	data[len(data)-14] = 0
+	data[len(data)-13] = 47
+
+	m, err := parseMessage(data)
+	require.NoError(t, err)
+	assert.Empty(t, m.Records)
+}
+
+func TestParseMessageInvalid(t *testing.T) {
+	valid := (&message{Questions: []question{{Name: "_adb._tcp.local", Type: typePTR}}}).pack()
+
+	for name, data := range map[string][]byte{
+		"short header":    {0, 0, 0},
+		"truncated":       valid[:len(valid)-3],
+		"pointer loop":    {0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 12, 0, 1},
+		"invalid label":   {0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 12, 0, 1},
+		"missing records": {0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0},
+	} {
+		_, err := parseMessage(data)
+		assert.True(t, errors.HasErrCode(err, errors.ParseError), "%s: %v", name, err)
+	}
+}
+
+func TestParseTXT(t *testing.T) {
+	assert.Nil(t, parseTXT(nil))
+	assert.Equal(t, map[string]string{"v": "1", "flag": ""}, parseTXT([]string{"v=1", "flag"}))
+}
+
+func TestServiceAddr(t *testing.T) {
+	s := Service{Port: 5555}
+	assert.Equal(t, "", s.Addr())
+
+	s.Addrs = []net.IP{net.ParseIP("fe80::1"), net.IPv4(192, 168, 1, 5)}
+	assert.Equal(t, "192.168.1.5:5555", s.Addr())
+
+	s.Addrs = s.Addrs[:1]
+	assert.Equal(t, "[fe80::1]:5555", s.Addr())
+}
@@ -0,0 +1,95 @@
+/*
+Package mdns discovers Android devices that advertise adb over multicast DNS, without going
+through an adb server.
+
+Devices with wireless debugging enabled advertise ServiceTLSConnect, and, while the
+"Pair device with pairing code" dialog is open, ServiceTLSPairing. A Browser sends DNS-SD
+queries for them and reports devices as they appear and disappear:
+
+	browser, err := mdns.NewBrowser(mdns.BrowserConfig{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer browser.Shutdown()
+	for event := range browser.C() {
+		fmt.Println(event.Type, event.Service.Instance, event.Service.Addr())
+	}
+
+To list the services the adb server has discovered instead, use adb.Adb.MdnsServices.
+*/
+package mdns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Service types advertised by adbd.
+const (
+	// ServiceTLSConnect is advertised by devices with wireless debugging enabled. Connect to
+	// it once paired.
+	ServiceTLSConnect = "_adb-tls-connect._tcp"
+	// ServiceTLSPairing is advertised while the device shows a pairing code. Pair with it
+	// using the pairing package or adb.Adb.Pair.
+	ServiceTLSPairing = "_adb-tls-pairing._tcp"
+	// ServiceAdb is advertised by devices accepting unencrypted connections, e.g. emulators
+	// and devices restarted with adb tcpip.
+	ServiceAdb = "_adb._tcp"
+
+	// Domain is the domain all mDNS names are in.
+	Domain = "local"
+)
+
+// Service is an instance of an adb service advertised by a device.
+type Service struct {
+	// Instance is the name of the instance, e.g. "adb-R58M1234-AbCdEf". For ServiceTLSConnect,
+	// it is the device's GUID, which is also the serial the adb server gives it.
+	Instance string
+
+	// Type is the service type, e.g. ServiceTLSConnect.
+	Type string
+
+	// Host is the device's mDNS host name, e.g. "Android.local". It can be empty for services
+	// listed by the adb server.
+	Host string
+
+	Port  int
+	Addrs []net.IP
+
+	// TXT holds the key/value pairs of the service's TXT record.
+	TXT map[string]string
+}
+
+// Name returns the service's fully qualified DNS name.
+func (s Service) Name() string {
+	return s.Instance + "." + s.Type + "." + Domain
+}
+
+// Addr returns the address to connect to, as host:port, preferring an IPv4 address.
+// Returns "" if no address is known.
+func (s Service) Addr() string {
+	if len(s.Addrs) == 0 {
+		return ""
+	}
+	ip := s.Addrs[0]
+	for _, addr := range s.Addrs {
+		if addr.To4() != nil {
+			ip = addr
+			break
+		}
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(s.Port))
+}
+
+func parseTXT(text []string) map[string]string {
+	if len(text) == 0 {
+		return nil
+	}
+	txt := make(map[string]string, len(text))
+	for _, s := range text {
+		key, value, _ := strings.Cut(s, "=")
+		txt[key] = value
+	}
+	return txt
+}
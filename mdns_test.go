@@ -0,0 +1,66 @@
+package adb
+
+import (
+	"net"
+	"testing"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/mdns"
+	"github.com/basiooo/goadb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMdnsCheck(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"mdns daemon version [Openscreen discovery 0.0.0]\n"},
+	}
+	client := &Adb{s}
+
+	status, err := client.MdnsCheck()
+	require.NoError(t, err)
+	assert.Equal(t, "mdns daemon version [Openscreen discovery 0.0.0]", status)
+	assert.Equal(t, []string{"host:mdns:check"}, s.Requests)
+}
+
+func TestMdnsCheckDisabled(t *testing.T) {
+	s := &MockServer{Errs: []error{nil, errors.Errorf(errors.AdbError, "ERROR: mdns discovery disabled")}}
+	client := &Adb{s}
+
+	_, err := client.MdnsCheck()
+	assert.True(t, HasErrCode(err, AdbError))
+}
+
+func TestMdnsServices(t *testing.T) {
+	s := &MockServer{
+		Status: wire.StatusSuccess,
+		Messages: []string{
+			"adb-R58M1234-AbCdEf\t_adb-tls-connect._tcp\t192.168.1.5:37099\n" +
+				"adb-R58M1234-AbCdEf\t_adb-tls-pairing._tcp.\t192.168.1.5:40135\n" +
+				"emulator\t_adb._tcp.local.\t[fe80::1]:5555\n",
+		},
+	}
+	client := &Adb{s}
+
+	services, err := client.MdnsServices()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host:mdns:services"}, s.Requests)
+	assert.Equal(t, []mdns.Service{
+		{Instance: "adb-R58M1234-AbCdEf", Type: mdns.ServiceTLSConnect, Port: 37099, Addrs: []net.IP{net.ParseIP("192.168.1.5")}},
+		{Instance: "adb-R58M1234-AbCdEf", Type: mdns.ServiceTLSPairing, Port: 40135, Addrs: []net.IP{net.ParseIP("192.168.1.5")}},
+		{Instance: "emulator", Type: mdns.ServiceAdb, Port: 5555, Addrs: []net.IP{net.ParseIP("fe80::1")}},
+	}, services)
+}
+
+func TestParseMdnsServicesInvalid(t *testing.T) {
+	for _, resp := range []string{
+		"adb-1\t_adb._tcp\n",
+		"adb-1\t_adb._tcp\t192.168.1.5\n",
+		"adb-1\t_adb._tcp\tnot-an-ip:5555\n",
+		"adb-1\t_adb._tcp\t192.168.1.5:port\n",
+	} {
+		_, err := parseMdnsServices(resp)
+		assert.True(t, errors.HasErrCode(err, errors.ParseError), "%q: %v", resp, err)
+	}
+}
@@ -0,0 +1,88 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// PairResult is the adb server's reply to a successful pairing.
+type PairResult struct {
+	// Message is the server's reply, e.g. "Successfully paired to 192.168.1.5:37099 [guid=adb-XXXX]".
+	Message string
+
+	// GUID identifies the device's adbd, and is used as the name of its mDNS service.
+	GUID string
+}
+
+/*
+Pair pairs the adb server with a device that has wireless debugging enabled. addr is the
+host:port shown in the device's "Pair device with pairing code" dialog, and code is the
+six-digit code shown with it. Once paired, connect to the device's wireless debugging port
+with Connect.
+
+A wrong code returns an AuthenticationFailed error. To pair without an adb server, see the
+pairing package.
+
+Corresponds to the command:
+
+	adb pair <addr> <code>
+*/
+func (c *Adb) Pair(ctx context.Context, addr, code string) (*PairResult, error) {
+	message, err := c.roundTripWithContext(ctx, fmt.Sprintf("host:pair:%s:%s", code, addr))
+	if err != nil {
+		return nil, wrapClientError(err, c, "Pair(%s)", addr)
+	}
+	result, err := parsePairResult(message)
+	return result, wrapClientError(err, c, "Pair(%s)", addr)
+}
+
+// roundTripWithContext sends req to the server and returns its response, closing the
+// connection if ctx is done first.
+func (c *Adb) roundTripWithContext(ctx context.Context, req string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", errors.WrapErrorf(err, errors.CommandCanceled, "command canceled")
+	}
+
+	conn, err := c.Server.Dial()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("[Adb] error closing connection: %s", err)
+		}
+	}()
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	resp, err := conn.RoundTripSingleResponse([]byte(req))
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return "", errors.WrapErrorf(ctxErr, errors.CommandCanceled, "command canceled")
+	}
+	return string(resp), err
+}
+
+// parsePairResult parses the server's reply to host:pair. The server accepts the request
+// even when pairing fails, and reports the failure in the reply.
+func parsePairResult(message string) (*PairResult, error) {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "Successfully paired") {
+		code := errors.AdbError
+		if strings.Contains(message, "Wrong password") {
+			code = errors.AuthenticationFailed
+		}
+		return nil, errors.Errorf(code, "pairing failed: %s", strings.TrimPrefix(message, "Failed: "))
+	}
+
+	result := &PairResult{Message: message}
+	if _, guid, ok := strings.Cut(message, "[guid="); ok {
+		result.GUID = strings.TrimSuffix(guid, "]")
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package adb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/basiooo/goadb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPair(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"Successfully paired to 192.168.1.5:37099 [guid=adb-R58M1234-AbCdEf]"},
+	}
+	client := &Adb{s}
+
+	result, err := client.Pair(context.Background(), "192.168.1.5:37099", "123456")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host:pair:123456:192.168.1.5:37099"}, s.Requests)
+	assert.Equal(t, &PairResult{
+		Message: "Successfully paired to 192.168.1.5:37099 [guid=adb-R58M1234-AbCdEf]",
+		GUID:    "adb-R58M1234-AbCdEf",
+	}, result)
+}
+
+func TestPairFailed(t *testing.T) {
+	for message, code := range map[string]ErrCode{
+		"Failed: Wrong password or connection was dropped.": AuthenticationFailed,
+		"Failed: Unable to start pairing client.":           AdbError,
+	} {
+		s := &MockServer{Status: wire.StatusSuccess, Messages: []string{message}}
+		client := &Adb{s}
+
+		_, err := client.Pair(context.Background(), "192.168.1.5:37099", "123456")
+		assert.True(t, HasErrCode(err, code), "%s: %v", message, err)
+	}
+}
+
+func TestPairCanceled(t *testing.T) {
+	s := &MockServer{Status: wire.StatusSuccess}
+	client := &Adb{s}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.Pair(ctx, "192.168.1.5:37099", "123456")
+	assert.True(t, HasErrCode(err, CommandCanceled))
+	assert.Empty(t, s.Requests)
+}
@@ -0,0 +1,318 @@
+/*
+Package pairing implements the wireless debugging pairing protocol of Android 11 and later,
+without going through an adb server.
+
+A device in pairing mode listens on a pairing port and shows a six-digit code. The host
+connects with TLS, and both sides prove they know the code with a SPAKE2 exchange keyed on
+the code and the TLS session. They then swap identities encrypted with the derived key: the
+host sends its adb public key, which the device adds to its authorized keys, and the device
+sends its GUID. After pairing, the host can connect to the device's wireless debugging port
+with the same key.
+
+To pair through the adb server instead, use adb.Adb.Pair.
+*/
+package pairing
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/basiooo/goadb/adbkey"
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/internal/spake2"
+)
+
+// MaxPeerInfoSize is the size of the PeerInfo struct exchanged after SPAKE2, including the
+// type byte.
+const MaxPeerInfoSize = 8192
+
+const (
+	clientName       = "adb pair client\x00"
+	serverName       = "adb pair server\x00"
+	exportedKeyLabel = "adb-label\x00"
+	exportedKeySize  = 64
+	cipherKeyInfo    = "adb pairing_auth aes-128-gcm key"
+	cipherKeySize    = 16
+
+	packetVersion  = 1
+	maxPayloadSize = 2 * MaxPeerInfoSize
+)
+
+type packetType byte
+
+const (
+	packetSpake2Message packetType = 0
+	packetPeerInfo      packetType = 1
+)
+
+// PeerInfoType identifies the contents of a PeerInfo.
+type PeerInfoType byte
+
+const (
+	// PeerRSAPublicKey is sent by the host: its adb public key, as in adbkey.pub.
+	PeerRSAPublicKey PeerInfoType = 0
+	// PeerDeviceGUID is sent by the device: the GUID of its adbd.
+	PeerDeviceGUID PeerInfoType = 1
+)
+
+// PeerInfo is the identity one side of the pairing sends the other.
+type PeerInfo struct {
+	Type PeerInfoType
+	Data string
+}
+
+/*
+Pair connects to the pairing port at addr and pairs with the device using code. key is the
+host's adb key, which the device will accept connections from once paired. Use
+adbkey.Load(adbkey.DefaultPath()) to pair the same key the adb server uses.
+
+Returns the device's PeerInfo, which holds its GUID. A wrong code returns an
+AuthenticationFailed error.
+*/
+func Pair(ctx context.Context, addr, code string, key *rsa.PrivateKey) (*PeerInfo, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, errors.WrapErrorf(ctxErr, errors.CommandCanceled, "pairing canceled")
+		}
+		return nil, errors.WrapErrorf(err, errors.ServerNotAvailable, "error connecting to %s", addr)
+	}
+	defer conn.Close()
+	return PairConn(ctx, conn, code, key)
+}
+
+// PairConn is like Pair, but pairs over an existing connection to the pairing port.
+func PairConn(ctx context.Context, conn net.Conn, code string, key *rsa.PrivateKey) (*PeerInfo, error) {
+	cert, err := adbkey.Certificate(key)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := adbkey.EncodePublicKey(&key.PublicKey, adbkey.DefaultName())
+	if err != nil {
+		return nil, err
+	}
+
+	// The server's certificate isn't verified: it's self-signed, and the SPAKE2 exchange,
+	// which is bound to the TLS session, authenticates it instead.
+	tlsConn := tls.Client(conn, &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+	})
+	return exchange(ctx, tlsConn, code, spake2.Alice, PeerInfo{Type: PeerRSAPublicKey, Data: publicKey})
+}
+
+/*
+Serve runs the device side of pairing over conn, using code as the pairing code, cert as the
+server certificate and guid as the device's GUID. It returns the host's PeerInfo, which holds
+its adb public key.
+
+Serve is meant for tests and tools that stand in for a device.
+*/
+func Serve(ctx context.Context, conn net.Conn, code string, cert tls.Certificate, guid string) (*PeerInfo, error) {
+	tlsConn := tls.Server(conn, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	})
+	return exchange(ctx, tlsConn, code, spake2.Bob, PeerInfo{Type: PeerDeviceGUID, Data: guid})
+}
+
+func exchange(ctx context.Context, conn *tls.Conn, code string, role spake2.Role, info PeerInfo) (*PeerInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapErrorf(err, errors.CommandCanceled, "pairing canceled")
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	peer, err := runExchange(ctx, conn, code, role, info)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, errors.WrapErrorf(ctxErr, errors.CommandCanceled, "pairing canceled")
+	}
+	return peer, err
+}
+
+func runExchange(ctx context.Context, conn *tls.Conn, code string, role spake2.Role, info PeerInfo) (*PeerInfo, error) {
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil, errors.WrapErrorf(err, errors.AuthenticationFailed, "TLS handshake failed")
+	}
+	state := conn.ConnectionState()
+	exported, err := state.ExportKeyingMaterial(exportedKeyLabel, nil, exportedKeySize)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.AssertionError, "error exporting TLS keying material")
+	}
+
+	// Binding the password to the TLS session means a man in the middle, who has a
+	// different session with each side, can't complete the exchange even with the code.
+	password := append([]byte(code), exported...)
+	myName, theirName := clientName, serverName
+	if role == spake2.Bob {
+		myName, theirName = serverName, clientName
+	}
+	auth := spake2.New(role, []byte(myName), []byte(theirName))
+	msg, err := auth.GenerateMessage(password, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writePacket(conn, packetSpake2Message, msg); err != nil {
+		return nil, err
+	}
+	theirMsg, err := readPacket(conn, packetSpake2Message)
+	if err != nil {
+		return nil, err
+	}
+	key, err := auth.ProcessMessage(theirMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newPairingCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePacket(conn, packetPeerInfo, c.encrypt(info.marshal())); err != nil {
+		return nil, err
+	}
+	payload, err := readPacket(conn, packetPeerInfo)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := c.decrypt(payload)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalPeerInfo(plaintext)
+}
+
+// marshal encodes info as the fixed-size PeerInfo struct, with Data NUL-terminated.
+func (info PeerInfo) marshal() []byte {
+	buf := make([]byte, MaxPeerInfoSize)
+	buf[0] = byte(info.Type)
+	copy(buf[1:MaxPeerInfoSize-1], info.Data)
+	return buf
+}
+
+func unmarshalPeerInfo(buf []byte) (*PeerInfo, error) {
+	if len(buf) != MaxPeerInfoSize {
+		return nil, errors.Errorf(errors.ParseError, "invalid peer info size %d", len(buf))
+	}
+	info := &PeerInfo{Type: PeerInfoType(buf[0])}
+	data := buf[1:]
+	for i, b := range data {
+		if b == 0 {
+			data = data[:i]
+			break
+		}
+	}
+	info.Data = string(data)
+	return info, nil
+}
+
+/*
+writePacket writes a packet, which has a 6-byte header:
+
+	uint8  version
+	uint8  type
+	uint32 payload size, big-endian
+*/
+func writePacket(w io.Writer, typ packetType, payload []byte) error {
+	buf := make([]byte, 6, 6+len(payload))
+	buf[0] = packetVersion
+	buf[1] = byte(typ)
+	binary.BigEndian.PutUint32(buf[2:], uint32(len(payload)))
+	if _, err := w.Write(append(buf, payload...)); err != nil {
+		return errors.WrapErrorf(err, errors.NetworkError, "error writing pairing packet")
+	}
+	return nil
+}
+
+func readPacket(r io.Reader, typ packetType) ([]byte, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, packetReadError(err)
+	}
+	if header[0] != packetVersion {
+		return nil, errors.Errorf(errors.ParseError, "unsupported pairing packet version %d", header[0])
+	}
+	if packetType(header[1]) != typ {
+		return nil, errors.Errorf(errors.ParseError, "expected pairing packet type %d, got %d", typ, header[1])
+	}
+	size := binary.BigEndian.Uint32(header[2:])
+	if size == 0 || size > maxPayloadSize {
+		return nil, errors.Errorf(errors.ParseError, "invalid pairing packet size %d", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, packetReadError(err)
+	}
+	return payload, nil
+}
+
+// packetReadError wraps an error reading a packet. The device closes the connection when it
+// can't decrypt the host's PeerInfo, so a closed connection usually means a wrong code.
+func packetReadError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.WrapErrorf(err, errors.AuthenticationFailed,
+			"connection closed during pairing, the pairing code is probably wrong")
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error reading pairing packet")
+}
+
+// pairingCipher encrypts PeerInfos with AES-128-GCM. Each direction uses a counter, encoded
+// as a little-endian uint64 at the start of the nonce.
+type pairingCipher struct {
+	aead   cipher.AEAD
+	encSeq uint64
+	decSeq uint64
+}
+
+func newPairingCipher(spake2Key []byte) (*pairingCipher, error) {
+	key, err := hkdf.Key(sha256.New, spake2Key, nil, cipherKeyInfo, cipherKeySize)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.AssertionError, "error deriving pairing key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.AssertionError, "error creating pairing cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.AssertionError, "error creating pairing cipher")
+	}
+	return &pairingCipher{aead: aead}, nil
+}
+
+func (c *pairingCipher) encrypt(plaintext []byte) []byte {
+	ciphertext := c.aead.Seal(nil, c.nonce(c.encSeq), plaintext, nil)
+	c.encSeq++
+	return ciphertext
+}
+
+func (c *pairingCipher) decrypt(ciphertext []byte) ([]byte, error) {
+	plaintext, err := c.aead.Open(nil, c.nonce(c.decSeq), ciphertext, nil)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.AuthenticationFailed, "wrong pairing code")
+	}
+	c.decSeq++
+	return plaintext, nil
+}
+
+func (c *pairingCipher) nonce(seq uint64) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.LittleEndian.PutUint64(nonce, seq)
+	return nonce
+}
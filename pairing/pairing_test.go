@@ -0,0 +1,175 @@
+package pairing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/basiooo/goadb/adbkey"
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testKeysOnce          sync.Once
+	hostKey, deviceKey    *rsa.PrivateKey
+	errGeneratingTestKeys error
+)
+
+func testKeys(t *testing.T) (*rsa.PrivateKey, *rsa.PrivateKey) {
+	testKeysOnce.Do(func() {
+		if hostKey, errGeneratingTestKeys = adbkey.Generate(); errGeneratingTestKeys == nil {
+			deviceKey, errGeneratingTestKeys = adbkey.Generate()
+		}
+	})
+	require.NoError(t, errGeneratingTestKeys)
+	return hostKey, deviceKey
+}
+
+type serveResult struct {
+	peer *PeerInfo
+	err  error
+}
+
+// startDevice listens on a local port and runs the device side of pairing on the first
+// connection.
+func startDevice(t *testing.T, code, guid string) (string, <-chan serveResult) {
+	_, deviceKey := testKeys(t)
+	cert, err := adbkey.Certificate(deviceKey)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	results := make(chan serveResult, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			results <- serveResult{err: err}
+			return
+		}
+		defer conn.Close()
+		peer, err := Serve(context.Background(), conn, code, cert, guid)
+		results <- serveResult{peer, err}
+	}()
+	return listener.Addr().String(), results
+}
+
+func TestPair(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	addr, results := startDevice(t, "123456", "adb-ABCDEF-xyz")
+
+	device, err := Pair(context.Background(), addr, "123456", hostKey)
+	require.NoError(t, err)
+	assert.Equal(t, &PeerInfo{Type: PeerDeviceGUID, Data: "adb-ABCDEF-xyz"}, device)
+
+	result := <-results
+	require.NoError(t, result.err)
+	publicKey, err := adbkey.EncodePublicKey(&hostKey.PublicKey, adbkey.DefaultName())
+	require.NoError(t, err)
+	assert.Equal(t, &PeerInfo{Type: PeerRSAPublicKey, Data: publicKey}, result.peer)
+}
+
+func TestPairWrongCode(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	addr, results := startDevice(t, "123456", "adb-ABCDEF-xyz")
+
+	_, err := Pair(context.Background(), addr, "654321", hostKey)
+	assert.True(t, errors.HasErrCode(err, errors.AuthenticationFailed), "%v", err)
+
+	result := <-results
+	assert.True(t, errors.HasErrCode(result.err, errors.AuthenticationFailed), "%v", result.err)
+}
+
+func TestPairCanceled(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		// Accept the connection, but never respond.
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = Pair(ctx, listener.Addr().String(), "123456", hostKey)
+	assert.True(t, errors.HasErrCode(err, errors.CommandCanceled), "%v", err)
+}
+
+func TestPairConnectionRefused(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = Pair(context.Background(), addr, "123456", hostKey)
+	assert.True(t, errors.HasErrCode(err, errors.ServerNotAvailable), "%v", err)
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writePacket(&buf, packetSpake2Message, []byte("hello")))
+	assert.Equal(t, []byte{1, 0, 0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o'}, buf.Bytes())
+
+	payload, err := readPacket(&buf, packetSpake2Message)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), payload)
+}
+
+func TestReadPacketInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"version": {2, 0, 0, 0, 0, 1, 0},
+		"type":    {1, 1, 0, 0, 0, 1, 0},
+		"empty":   {1, 0, 0, 0, 0, 0},
+		"too big": {1, 0, 0, 0, 0x40, 1},
+	} {
+		_, err := readPacket(bytes.NewReader(data), packetSpake2Message)
+		assert.True(t, errors.HasErrCode(err, errors.ParseError), "%s: %v", name, err)
+	}
+
+	_, err := readPacket(bytes.NewReader([]byte{1, 0, 0, 0, 0, 5, 'h'}), packetSpake2Message)
+	assert.True(t, errors.HasErrCode(err, errors.AuthenticationFailed), "%v", err)
+}
+
+func TestPeerInfoMarshal(t *testing.T) {
+	buf := PeerInfo{Type: PeerDeviceGUID, Data: "guid"}.marshal()
+	require.Len(t, buf, MaxPeerInfoSize)
+	assert.Equal(t, []byte{1, 'g', 'u', 'i', 'd', 0}, buf[:6])
+
+	info, err := unmarshalPeerInfo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, &PeerInfo{Type: PeerDeviceGUID, Data: "guid"}, info)
+
+	// Data that doesn't fit is truncated, leaving room for the terminator.
+	buf = PeerInfo{Data: string(bytes.Repeat([]byte("a"), MaxPeerInfoSize))}.marshal()
+	assert.Equal(t, byte(0), buf[MaxPeerInfoSize-1])
+}
+
+func TestPairingCipher(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 64)
+	sender, err := newPairingCipher(key)
+	require.NoError(t, err)
+	receiver, err := newPairingCipher(key)
+	require.NoError(t, err)
+
+	for _, msg := range []string{"first", "second"} {
+		plaintext, err := receiver.decrypt(sender.encrypt([]byte(msg)))
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(plaintext))
+	}
+
+	// A truncated message fails authentication.
+	_, err = receiver.decrypt(sender.encrypt([]byte("third"))[:5])
+	assert.True(t, errors.HasErrCode(err, errors.AuthenticationFailed))
+}
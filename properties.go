@@ -0,0 +1,207 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// Names of commonly used system properties.
+const (
+	PropSDKLevel     = "ro.build.version.sdk"
+	PropRelease      = "ro.build.version.release"
+	PropABIList      = "ro.product.cpu.abilist"
+	PropFingerprint  = "ro.build.fingerprint"
+	PropManufacturer = "ro.product.manufacturer"
+	PropModel        = "ro.product.model"
+	PropSerialNo     = "ro.serialno"
+	PropBootComplete = "sys.boot_completed"
+)
+
+// devicePollInterval is how often pollUntil checks the device.
+var devicePollInterval = 500 * time.Millisecond
+
+// Properties maps the names of system properties to their values.
+type Properties map[string]string
+
+// SDKLevel returns the API level of the device's Android version, e.g. 34.
+func (p Properties) SDKLevel() (int, error) {
+	level, err := strconv.Atoi(p[PropSDKLevel])
+	if err != nil {
+		return 0, errors.WrapErrorf(err, errors.ParseError, "invalid %s: %q", PropSDKLevel, p[PropSDKLevel])
+	}
+	return level, nil
+}
+
+// Release returns the user-visible Android version, e.g. "14".
+func (p Properties) Release() string {
+	return p[PropRelease]
+}
+
+// ABIs returns the ABIs supported by the device, most preferred first.
+func (p Properties) ABIs() []string {
+	if list := p[PropABIList]; list != "" {
+		return strings.Split(list, ",")
+	}
+	// Devices older than Android 5.0 list at most two.
+	var abis []string
+	for _, key := range []string{"ro.product.cpu.abi", "ro.product.cpu.abi2"} {
+		if abi := p[key]; abi != "" {
+			abis = append(abis, abi)
+		}
+	}
+	return abis
+}
+
+func (p Properties) Fingerprint() string {
+	return p[PropFingerprint]
+}
+
+func (p Properties) Manufacturer() string {
+	return p[PropManufacturer]
+}
+
+func (p Properties) Model() string {
+	return p[PropModel]
+}
+
+// SerialNo returns the device's hardware serial number, which can differ from the serial
+// adb knows it by, e.g. for devices connected over the network.
+func (p Properties) SerialNo() string {
+	return p[PropSerialNo]
+}
+
+/*
+Properties returns all of the device's system properties.
+
+Corresponds to the command:
+
+	adb shell getprop
+*/
+func (c *Device) Properties() (Properties, error) {
+	output, err := c.runPropertyCommand("getprop")
+	if err != nil {
+		return nil, wrapClientError(err, c, "Properties")
+	}
+	props, err := parseProperties(output)
+	return props, wrapClientError(err, c, "Properties")
+}
+
+// Property returns the value of the system property key, or "" if it isn't set.
+func (c *Device) Property(key string) (string, error) {
+	output, err := c.runPropertyCommand("getprop", key)
+	if err != nil {
+		return "", wrapClientError(err, c, "Property(%s)", key)
+	}
+	return strings.TrimSuffix(output, "\n"), nil
+}
+
+/*
+SetProperty sets the system property key to value. Most properties can only be set by root,
+and ro. properties can only be set once.
+
+Corresponds to the command:
+
+	adb shell setprop key value
+*/
+func (c *Device) SetProperty(key, value string) error {
+	_, err := c.runPropertyCommand("setprop", key, value)
+	return wrapClientError(err, c, "SetProperty(%s)", key)
+}
+
+/*
+WatchProperty calls fn with the value of the system property key, and again each time it
+changes, until fn returns true. The property is checked twice a second, so short-lived
+values can be missed.
+
+Returns nil once fn returns true, or a CommandCanceled error if ctx is done first.
+
+Eg.
+
+	err := device.WatchProperty(ctx, adb.PropBootComplete, func(value string) bool {
+		return value == "1"
+	})
+*/
+func (c *Device) WatchProperty(ctx context.Context, key string, fn func(value string) bool) error {
+	first := true
+	var last string
+	return c.pollUntil(ctx, fmt.Sprintf("WatchProperty(%s)", key), func() (bool, error) {
+		value, err := c.Property(key)
+		if err != nil || (!first && value == last) {
+			return false, err
+		}
+		first, last = false, value
+		return fn(value), nil
+	})
+}
+
+func (c *Device) runPropertyCommand(cmd string, args ...string) (string, error) {
+	cmdline := shellCommandLine(cmd, args...)
+	result, err := c.runShellCommand(cmdline)
+	if err == nil {
+		err = commandError(cmdline, result)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(result.Stdout), nil
+}
+
+/*
+parseProperties parses the output of getprop, which lists each property as
+
+	[key]: [value]
+
+A value containing newlines continues on the following lines, until one that ends with "]".
+*/
+func parseProperties(output string) (Properties, error) {
+	props := Properties{}
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "]: [")
+		if !ok || !strings.HasPrefix(key, "[") {
+			return nil, errors.Errorf(errors.ParseError, "invalid getprop output line: %q", line)
+		}
+		for !strings.HasSuffix(value, "]") {
+			i++
+			if i >= len(lines) {
+				return nil, errors.Errorf(errors.ParseError, "unterminated value for property %s", key[1:])
+			}
+			value += "\n" + lines[i]
+		}
+		props[key[1:]] = strings.TrimSuffix(value, "]")
+	}
+	return props, nil
+}
+
+// pollUntil calls check every devicePollInterval until it returns true or an error.
+func (c *Device) pollUntil(ctx context.Context, operation string, check func() (bool, error)) error {
+	ticker := time.NewTicker(devicePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return wrapClientError(errors.WrapErrorf(err, errors.CommandCanceled, "wait canceled"), c, "%s", operation)
+		}
+		done, err := check()
+		if err != nil {
+			return wrapClientError(err, c, "%s", operation)
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package adb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGetpropOutput = `[ro.build.fingerprint]: [google/oriole/oriole:14/UQ1A.240105.004/11206848:user/release-keys]
+[ro.build.version.release]: [14]
+[ro.build.version.sdk]: [34]
+[ro.product.cpu.abilist]: [arm64-v8a,armeabi-v7a,armeabi]
+[ro.product.manufacturer]: [Google]
+[ro.product.model]: [Pixel 6]
+[ro.serialno]: [1A2B3C]
+[persist.sys.motd]: [line one
+line two]
+[empty.prop]: []
+`
+
+func TestParseProperties(t *testing.T) {
+	props, err := parseProperties(testGetpropOutput)
+	require.NoError(t, err)
+
+	assert.Len(t, props, 9)
+	assert.Equal(t, "line one\nline two", props["persist.sys.motd"])
+	assert.Equal(t, "", props["empty.prop"])
+
+	level, err := props.SDKLevel()
+	require.NoError(t, err)
+	assert.Equal(t, 34, level)
+	assert.Equal(t, "14", props.Release())
+	assert.Equal(t, []string{"arm64-v8a", "armeabi-v7a", "armeabi"}, props.ABIs())
+	assert.Equal(t, "google/oriole/oriole:14/UQ1A.240105.004/11206848:user/release-keys", props.Fingerprint())
+	assert.Equal(t, "Google", props.Manufacturer())
+	assert.Equal(t, "Pixel 6", props.Model())
+	assert.Equal(t, "1A2B3C", props.SerialNo())
+}
+
+func TestParsePropertiesOldDevice(t *testing.T) {
+	props, err := parseProperties("[ro.product.cpu.abi]: [armeabi-v7a]\r\n[ro.product.cpu.abi2]: [armeabi]\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"armeabi-v7a", "armeabi"}, props.ABIs())
+
+	_, err = props.SDKLevel()
+	assert.True(t, HasErrCode(err, ParseError))
+}
+
+func TestParsePropertiesErrors(t *testing.T) {
+	_, err := parseProperties("not a property\n")
+	assert.True(t, HasErrCode(err, ParseError))
+
+	_, err = parseProperties("[a]: [unterminated\n")
+	assert.True(t, HasErrCode(err, ParseError))
+}
+
+func TestProperties(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		switch cmdline {
+		case "getprop":
+			return testGetpropOutput, "", 0
+		case "getprop ro.product.model":
+			return "Pixel 6\n", "", 0
+		case "setprop persist.my.prop 'a b'":
+			return "", "", 0
+		default:
+			return "", "Failed to set property 'ro.x' to 'y'.\n", 1
+		}
+	})
+
+	props, err := device.Properties()
+	require.NoError(t, err)
+	assert.Equal(t, "Google", props.Manufacturer())
+
+	model, err := device.Property(PropModel)
+	require.NoError(t, err)
+	assert.Equal(t, "Pixel 6", model)
+
+	assert.NoError(t, device.SetProperty("persist.my.prop", "a b"))
+	err = device.SetProperty("ro.x", "y")
+	assert.True(t, HasErrCode(err, CommandFailed))
+	assert.Contains(t, ErrorWithCauseChain(err), "Failed to set property")
+}
+
+func TestWatchProperty(t *testing.T) {
+	defer func(interval time.Duration) { devicePollInterval = interval }(devicePollInterval)
+	devicePollInterval = time.Millisecond
+
+	var mu sync.Mutex
+	values := []string{"", "", "0", "0", "1"}
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		value := values[0]
+		if len(values) > 1 {
+			values = values[1:]
+		}
+		return value + "\n", "", 0
+	})
+
+	var seen []string
+	err := device.WatchProperty(context.Background(), PropBootComplete, func(value string) bool {
+		seen = append(seen, value)
+		return value == "1"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "0", "1"}, seen)
+}
+
+func TestWatchPropertyCanceled(t *testing.T) {
+	defer func(interval time.Duration) { devicePollInterval = interval }(devicePollInterval)
+	devicePollInterval = time.Millisecond
+
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return "0\n", "", 0
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := device.WatchProperty(ctx, PropBootComplete, func(value string) bool {
+		calls++
+		cancel()
+		return false
+	})
+	assert.True(t, HasErrCode(err, CommandCanceled))
+	assert.Equal(t, 1, calls)
+	assert.Contains(t, ErrorWithCauseChain(err), PropBootComplete)
+}
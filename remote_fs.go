@@ -0,0 +1,137 @@
+package adb
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+The sync protocol can only read, write, stat and list files. The methods in this file
+cover the rest of the usual filesystem operations by running the matching toybox
+commands through the shell, with every argument quoted so that paths reach the command
+verbatim.
+
+Failures are reported using the exit status of the command. Common error messages are
+mapped to FileNoExistError, FileExistError and PermissionDenied; anything else is a
+CommandFailed error. The error's Details is a CommandErrorDetails.
+*/
+
+// Mkdir creates the directory name with the permission bits perm.
+// Unlike os.Mkdir, perm is not affected by the device's umask.
+func (c *Device) Mkdir(name string, perm os.FileMode) error {
+	return c.runFileCommand("Mkdir(%s)", name,
+		[]string{"mkdir", "-m", fileModeString(perm), "--", name})
+}
+
+// MkdirAll creates the directory name along with any missing parents, and does nothing if
+// it already exists. Like mkdir -p -m, only name itself gets the permission bits perm; any
+// parents it creates get the device's default permissions.
+func (c *Device) MkdirAll(name string, perm os.FileMode) error {
+	return c.runFileCommand("MkdirAll(%s)", name,
+		[]string{"mkdir", "-p", "-m", fileModeString(perm), "--", name})
+}
+
+// Remove removes the file or empty directory name.
+func (c *Device) Remove(name string) error {
+	quoted := shellQuote(name)
+	script := fmt.Sprintf("if [ -d %[1]s ] && [ ! -L %[1]s ]; then rmdir -- %[1]s; else rm -- %[1]s; fi", quoted)
+	return c.runFileScript("Remove(%s)", name, script)
+}
+
+// RemoveAll removes name and everything it contains. Like os.RemoveAll, it returns nil if
+// name doesn't exist.
+func (c *Device) RemoveAll(name string) error {
+	return c.runFileCommand("RemoveAll(%s)", name, []string{"rm", "-rf", "--", name})
+}
+
+// Rename moves oldpath to newpath. If newpath is an existing directory, oldpath is moved
+// inside it, as mv does.
+func (c *Device) Rename(oldpath, newpath string) error {
+	return c.runFileCommand("Rename(%s)", oldpath+", "+newpath, []string{"mv", "--", oldpath, newpath})
+}
+
+// Chmod changes the mode of name to mode, including the setuid, setgid and sticky bits.
+func (c *Device) Chmod(name string, mode os.FileMode) error {
+	return c.runFileCommand("Chmod(%s)", name, []string{"chmod", fileModeString(mode), "--", name})
+}
+
+// Chown changes the numeric uid and gid of name. A uid or gid of -1 leaves that value unchanged.
+func (c *Device) Chown(name string, uid, gid int) error {
+	var owner string
+	switch {
+	case uid == -1 && gid == -1:
+		return nil
+	case gid == -1:
+		owner = strconv.Itoa(uid)
+	case uid == -1:
+		owner = ":" + strconv.Itoa(gid)
+	default:
+		owner = strconv.Itoa(uid) + ":" + strconv.Itoa(gid)
+	}
+	return c.runFileCommand("Chown(%s)", name, []string{"chown", owner, "--", name})
+}
+
+// Symlink creates newname as a symbolic link to oldname.
+func (c *Device) Symlink(oldname, newname string) error {
+	return c.runFileCommand("Symlink(%s)", oldname+", "+newname, []string{"ln", "-s", "--", oldname, newname})
+}
+
+// Readlink returns the destination of the symbolic link name.
+func (c *Device) Readlink(name string) (string, error) {
+	cmdline := shellCommandLine("readlink", "--", name)
+	result, err := c.runShellCommand(cmdline)
+	if err == nil {
+		err = commandError(cmdline, result)
+	}
+	if err != nil {
+		return "", wrapClientError(err, c, "Readlink(%s)", name)
+	}
+	return strings.TrimSuffix(string(result.Stdout), "\n"), nil
+}
+
+// Truncate changes the size of name to size, padding it with zeros if it grows.
+// Unlike os.Truncate, the file is created if it doesn't exist.
+func (c *Device) Truncate(name string, size int64) error {
+	return c.runFileCommand("Truncate(%s)", name,
+		[]string{"truncate", "-s", strconv.FormatInt(size, 10), "--", name})
+}
+
+func (c *Device) runFileCommand(operation, name string, argv []string) error {
+	return c.runFileScript(operation, name, shellCommandLine(argv[0], argv[1:]...))
+}
+
+func (c *Device) runFileScript(operation, name, script string) error {
+	result, err := c.runShellCommand(script)
+	if err == nil {
+		err = commandError(script, result)
+	}
+	return wrapClientError(err, c, operation, name)
+}
+
+// shellCommandLine quotes and joins cmd and args into a command line for the device shell.
+func shellCommandLine(cmd string, args ...string) string {
+	quoted := make([]string, 0, len(args)+1)
+	quoted = append(quoted, shellQuote(cmd))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// fileModeString formats the permission, setuid, setgid and sticky bits of mode as an
+// octal mode for chmod and mkdir.
+func fileModeString(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
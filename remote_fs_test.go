@@ -0,0 +1,160 @@
+package adb
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeShellDevice(features []string, fn fakeShellFunc) (*fakeDevice, *Device) {
+	d := newFakeDevice()
+	d.handleShell(features, fn)
+	return d, newFakeDeviceClient(d)
+}
+
+func TestRemoteFSCommandLines(t *testing.T) {
+	var cmdlines []string
+	_, device := newFakeShellDevice([]string{"cmd", "shell_v2"}, func(cmdline string) (string, string, int) {
+		cmdlines = append(cmdlines, cmdline)
+		return "", "", 0
+	})
+
+	require.NoError(t, device.Mkdir("/sdcard/new dir", 0750))
+	require.NoError(t, device.MkdirAll("/sdcard/a/b", 0755))
+	require.NoError(t, device.RemoveAll("/sdcard/it's"))
+	require.NoError(t, device.Rename("/a", "/b;reboot"))
+	require.NoError(t, device.Chmod("/x", os.ModeSetuid|0755))
+	require.NoError(t, device.Chown("/x", 1000, -1))
+	require.NoError(t, device.Chown("/x", -1, 2000))
+	require.NoError(t, device.Chown("/x", -1, -1))
+	require.NoError(t, device.Symlink("/target", "/link"))
+	require.NoError(t, device.Truncate("/x", 1024))
+
+	assert.Equal(t, []string{
+		"mkdir -m 0750 -- '/sdcard/new dir'",
+		"mkdir -p -m 0755 -- /sdcard/a/b",
+		`rm -rf -- '/sdcard/it'\''s'`,
+		"mv -- /a '/b;reboot'",
+		"chmod 4755 -- /x",
+		"chown 1000 -- /x",
+		"chown :2000 -- /x",
+		"ln -s -- /target /link",
+		"truncate -s 1024 -- /x",
+	}, cmdlines)
+}
+
+func TestRemoteFSRemove(t *testing.T) {
+	var cmdline string
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(c string) (string, string, int) {
+		cmdline = c
+		return "", "", 0
+	})
+
+	require.NoError(t, device.Remove("/sdcard/$HOME"))
+	assert.Equal(t, `if [ -d '/sdcard/$HOME' ] && [ ! -L '/sdcard/$HOME' ]; then rmdir -- '/sdcard/$HOME'; else rm -- '/sdcard/$HOME'; fi`, cmdline)
+}
+
+func TestRemoteFSErrorCodes(t *testing.T) {
+	for _, test := range []struct {
+		stderr string
+		code   ErrCode
+	}{
+		{"rm: /nope: No such file or directory\n", FileNoExistError},
+		{"mkdir: '/sdcard/x': File exists\n", FileExistError},
+		{"mkdir: '/system/x': Read-only file system\n", PermissionDenied},
+		{"chmod: /data: Operation not permitted\n", PermissionDenied},
+		{"mv: something else\n", CommandFailed},
+	} {
+		_, device := newFakeShellDevice([]string{"shell_v2"}, func(string) (string, string, int) {
+			return "", test.stderr, 1
+		})
+
+		err := device.Mkdir("/x", 0755)
+		assert.True(t, HasErrCode(err, test.code), "%s: %v", test.stderr, err)
+	}
+}
+
+func TestRemoteFSReadlink(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		assert.Equal(t, "readlink -- /sdcard", cmdline)
+		return "/storage/self/primary\n", "", 0
+	})
+
+	target, err := device.Readlink("/sdcard")
+	require.NoError(t, err)
+	assert.Equal(t, "/storage/self/primary", target)
+}
+
+func TestRemoteFSShellV1Fallback(t *testing.T) {
+	var cmdline string
+	_, device := newFakeShellDevice(nil, func(c string) (string, string, int) {
+		cmdline = c
+		return "rm: /nope: No such file or directory\r\n\r\n1\r\n", "", 0
+	})
+
+	err := device.RemoveAll("/nope")
+	assert.True(t, HasErrCode(err, FileNoExistError))
+	assert.Equal(t, "(rm -rf -- /nope) 2>&1; echo; echo $?", cmdline)
+
+	_, device = newFakeShellDevice(nil, func(string) (string, string, int) {
+		return "/target\n\n0\n", "", 0
+	})
+	target, err := device.Readlink("/link")
+	require.NoError(t, err)
+	assert.Equal(t, "/target", target)
+}
+
+func TestCommandErrorDetails(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(string) (string, string, int) {
+		return "", "boom\n", 42
+	})
+
+	err := device.Chmod("/x", 0644)
+	require.True(t, HasErrCode(err, CommandFailed))
+	cause := err.(*errors.Err).Cause.(*errors.Err)
+	assert.Equal(t, "command exited with status 42: boom", cause.Message)
+	assert.Equal(t, CommandErrorDetails{
+		Command:  "chmod 0644 -- /x",
+		ExitCode: 42,
+		Stderr:   "boom",
+	}, cause.Details)
+}
+
+func TestShellV2MissingExitStatus(t *testing.T) {
+	d := newFakeDevice()
+	d.services["host-serial:serial:features"] = func(conn net.Conn, req string) {
+		writeFakeMessage(conn, "shell_v2")
+	}
+	d.services["shell,v2,raw:"] = func(conn net.Conn, req string) {
+		// The stream ends before the exit packet, as when adbd is killed.
+		wire.WriteShellPacket(conn, wire.ShellStdout, []byte("partial output"))
+	}
+	device := newFakeDeviceClient(d)
+
+	err := device.Chmod("/x", 0644)
+	assert.True(t, HasErrCode(err, ConnectionResetError), "%v", err)
+	assert.Contains(t, ErrorWithCauseChain(err), "shell exited without a status")
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "''", shellQuote(""))
+	assert.Equal(t, "plain/path-1.txt", shellQuote("plain/path-1.txt"))
+	assert.Equal(t, "'a b'", shellQuote("a b"))
+	assert.Equal(t, `'{"a":1}'`, shellQuote(`{"a":1}`))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	assert.Equal(t, "'$(reboot)'", shellQuote("$(reboot)"))
+	assert.Equal(t, "'*'", shellQuote("*"))
+}
+
+func TestDeviceFeatures(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2", "cmd", "stat_v2"}, nil)
+
+	features, err := device.Features()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shell_v2", "cmd", "stat_v2"}, features)
+}
@@ -0,0 +1,130 @@
+package adb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// MaxScreenRecordSegment is the longest screenrecord will record for in one run.
+const MaxScreenRecordSegment = 3 * time.Minute
+
+// ScreenRecordOptions configures Device.ScreenRecord. Zero values use screenrecord's defaults.
+type ScreenRecordOptions struct {
+	// BitRate in bits per second.
+	BitRate int
+
+	// Width and Height of the video. Both must be set to change the size.
+	Width  int
+	Height int
+
+	// TimeLimit stops the recording after this long, measured on the host's clock from when
+	// ScreenRecord is called, so it includes the time taken to start each run of screenrecord.
+	// If zero, the recording continues until ctx is done.
+	TimeLimit time.Duration
+
+	// DisplayID records the display with this id instead of the default display.
+	// Requires Android 10.
+	DisplayID string
+}
+
+func (opts ScreenRecordOptions) args(segment time.Duration) []string {
+	args := []string{"screenrecord", "--output-format=h264"}
+	if opts.BitRate > 0 {
+		args = append(args, "--bit-rate", strconv.Itoa(opts.BitRate))
+	}
+	if opts.Width > 0 && opts.Height > 0 {
+		args = append(args, "--size", fmt.Sprintf("%dx%d", opts.Width, opts.Height))
+	}
+	seconds := int(math.Ceil(segment.Seconds()))
+	args = append(args, "--time-limit", strconv.Itoa(seconds))
+	if opts.DisplayID != "" {
+		args = append(args, "--display-id", opts.DisplayID)
+	}
+	return append(args, "-")
+}
+
+/*
+ScreenRecord records the device's screen and writes it to w as a raw H.264 elementary stream,
+which players such as ffplay and VLC can play and ffmpeg can put in a container.
+
+screenrecord can't record for longer than MaxScreenRecordSegment, so longer recordings are
+made from several runs of it, one after another. Each run starts with its own stream headers,
+so the output is still a single valid stream, but a few frames may be missed between runs.
+
+ScreenRecord returns once opts.TimeLimit has passed, a run of screenrecord records nothing,
+or ctx is done. Stopping a recording with ctx isn't an error: the output written up to then
+is kept, and nil is returned. If screenrecord fails, a CommandFailed error is returned with
+its error message.
+
+Corresponds to the command:
+
+	adb exec-out screenrecord --output-format=h264 -
+*/
+func (c *Device) ScreenRecord(ctx context.Context, w io.Writer, opts ScreenRecordOptions) error {
+	var elapsed time.Duration
+	for opts.TimeLimit == 0 || elapsed < opts.TimeLimit {
+		segment := MaxScreenRecordSegment
+		if opts.TimeLimit > 0 && opts.TimeLimit-elapsed < segment {
+			segment = opts.TimeLimit - elapsed
+		}
+
+		start := time.Now()
+		written, err := c.recordSegment(ctx, w, opts.args(segment))
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if written == 0 {
+			// Nothing was recorded, so there's no point starting another run.
+			return nil
+		}
+		// screenrecord may stop before its time limit, e.g. when the display is rotated on
+		// old versions, so count the time the run actually took, including starting it.
+		elapsed += time.Since(start)
+	}
+	return nil
+}
+
+// recordSegment runs screenrecord once, copies its output to w, and returns the number of
+// bytes written.
+func (c *Device) recordSegment(ctx context.Context, w io.Writer, args []string) (int64, error) {
+	stream, err := c.Exec(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	// Errors are written to the same stream as the video, which always starts with a NAL
+	// unit start code.
+	r := bufio.NewReader(stream)
+	start, err := r.Peek(4)
+	if len(start) > 0 && !bytes.HasPrefix(start, []byte{0, 0, 1}) && !bytes.HasPrefix(start, []byte{0, 0, 0, 1}) {
+		output, _ := io.ReadAll(io.LimitReader(r, 4096))
+		msg := string(bytes.TrimSpace(output))
+		return 0, wrapClientError(&errors.Err{
+			Code:    errors.CommandFailed,
+			Message: "screenrecord failed: " + msg,
+			Details: CommandErrorDetails{Command: shellCommandLine(args[0], args[1:]...), ExitCode: -1, Stderr: msg},
+		}, c, "ScreenRecord")
+	} else if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	written, err := r.WriteTo(w)
+	if err != nil {
+		if _, ok := err.(*errors.Err); !ok {
+			err = wrapClientError(errors.WrapErrorf(err, errors.LocalFileError, "error writing recording"), c, "ScreenRecord")
+		}
+	}
+	return written, err
+}
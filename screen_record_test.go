@@ -0,0 +1,98 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testH264Segment = "\x00\x00\x00\x01\x67\x42\x00\x1f\x00\x00\x00\x01\x65\x88"
+
+func TestScreenRecordChainsSegments(t *testing.T) {
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, testH264Segment)
+		time.Sleep(200 * time.Millisecond)
+	}
+	device := newFakeDeviceClient(d)
+
+	// Each run of screenrecord stops well before its time limit, so it takes three runs to
+	// record for the time limit.
+	var out bytes.Buffer
+	err := device.ScreenRecord(context.Background(), &out, ScreenRecordOptions{
+		BitRate:   4000000,
+		Width:     1280,
+		Height:    720,
+		TimeLimit: 500 * time.Millisecond,
+		DisplayID: "1",
+	})
+	require.NoError(t, err)
+	d.wait()
+
+	assert.Equal(t, strings.Repeat(testH264Segment, 3), out.String())
+	args := "--output-format=h264 --bit-rate 4000000 --size 1280x720 --time-limit 1 --display-id 1 -"
+	assert.Equal(t, []string{
+		"exec:screenrecord " + args,
+		"exec:screenrecord " + args,
+		"exec:screenrecord " + args,
+	}, d.requests)
+}
+
+func TestScreenRecordArgs(t *testing.T) {
+	assert.Equal(t, []string{"screenrecord", "--output-format=h264", "--time-limit", "180", "-"},
+		ScreenRecordOptions{}.args(MaxScreenRecordSegment))
+	assert.Equal(t, []string{"screenrecord", "--output-format=h264", "--time-limit", "61", "-"},
+		ScreenRecordOptions{}.args(time.Minute+500*time.Millisecond))
+}
+
+func TestScreenRecordStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var once sync.Once
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, testH264Segment)
+		once.Do(cancel)
+		io.Copy(io.Discard, conn)
+	}
+	device := newFakeDeviceClient(d)
+
+	var out bytes.Buffer
+	err := device.ScreenRecord(ctx, &out, ScreenRecordOptions{})
+	assert.NoError(t, err)
+	d.wait()
+	assert.Equal(t, []string{"exec:screenrecord --output-format=h264 --time-limit 180 -"}, d.requests)
+}
+
+func TestScreenRecordStopsWhenNothingRecorded(t *testing.T) {
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {}
+	device := newFakeDeviceClient(d)
+
+	err := device.ScreenRecord(context.Background(), io.Discard, ScreenRecordOptions{})
+	assert.NoError(t, err)
+	d.wait()
+	assert.Len(t, d.requests, 1)
+}
+
+func TestScreenRecordFailure(t *testing.T) {
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "ERROR: unable to get display 5\n")
+	}
+	device := newFakeDeviceClient(d)
+
+	var out bytes.Buffer
+	err := device.ScreenRecord(context.Background(), &out, ScreenRecordOptions{DisplayID: "5"})
+	assert.True(t, HasErrCode(err, CommandFailed))
+	assert.Contains(t, ErrorWithCauseChain(err), "unable to get display 5")
+	assert.Zero(t, out.Len())
+	d.wait()
+}
@@ -0,0 +1,216 @@
+package adb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+/*
+Screenshot captures the device's screen.
+
+It reads the raw pixels from the framebuffer: service. If the device doesn't provide the
+service, closes it without sending a header, or uses a format this package doesn't
+understand, it falls back to running screencap -p through Exec and decoding its PNG output.
+Nothing is written to the device's storage either way.
+
+Corresponds to the commands:
+
+	adb exec-out screencap -p
+*/
+func (c *Device) Screenshot(ctx context.Context) (image.Image, error) {
+	img, err := c.readFramebuffer(ctx)
+	if err == nil {
+		return img, nil
+	}
+	if !HasErrCode(err, AdbError) && !HasErrCode(err, ParseError) && !HasErrCode(err, ConnectionResetError) {
+		return nil, err
+	}
+
+	stream, err := c.Exec(ctx, "screencap", "-p")
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	img, err = png.Decode(bufio.NewReader(stream))
+	if err != nil {
+		if _, ok := err.(*errors.Err); !ok {
+			err = errors.WrapErrorf(err, errors.ParseError, "error decoding screencap output")
+		}
+		return nil, wrapClientError(err, c, "Screenshot")
+	}
+	return img, nil
+}
+
+func (c *Device) readFramebuffer(ctx context.Context) (image.Image, error) {
+	stream, err := c.openCommandStream(ctx, "framebuffer:", "Screenshot")
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	img, err := decodeFramebuffer(bufio.NewReader(stream), stream)
+	return img, wrapClientError(err, c, "Screenshot")
+}
+
+// framebufferHeader describes the pixel format sent by the framebuffer: service.
+type framebufferHeader struct {
+	Bpp    uint32
+	Size   uint32
+	Width  uint32
+	Height uint32
+
+	RedOffset, RedLength     uint32
+	BlueOffset, BlueLength   uint32
+	GreenOffset, GreenLength uint32
+	AlphaOffset, AlphaLength uint32
+}
+
+/*
+decodeFramebuffer reads the output of the framebuffer: service. Its header is a series of
+little-endian uint32s, starting with a version:
+
+	16 (legacy): size, width, height, followed by RGB565 pixels
+	1: bpp, size, width, height, then the offset and length of red, blue, green and alpha
+	2: like 1, with a color space after bpp
+
+Older versions of adbd wait for the client to write a byte before sending the pixels, so
+one is written to w after the header.
+*/
+func decodeFramebuffer(r io.Reader, w io.Writer) (image.Image, error) {
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, framebufferReadError(err, "header")
+	}
+
+	var header framebufferHeader
+	switch version {
+	case 16:
+		var legacy struct{ Size, Width, Height uint32 }
+		if err := binary.Read(r, binary.LittleEndian, &legacy); err != nil {
+			return nil, framebufferReadError(err, "header")
+		}
+		header = framebufferHeader{
+			Bpp: 16, Size: legacy.Size, Width: legacy.Width, Height: legacy.Height,
+			RedOffset: 11, RedLength: 5, GreenOffset: 5, GreenLength: 6, BlueOffset: 0, BlueLength: 5,
+		}
+	case 1, 2:
+		var bpp uint32
+		if err := binary.Read(r, binary.LittleEndian, &bpp); err != nil {
+			return nil, framebufferReadError(err, "header")
+		}
+		if version == 2 {
+			var colorSpace uint32
+			if err := binary.Read(r, binary.LittleEndian, &colorSpace); err != nil {
+				return nil, framebufferReadError(err, "header")
+			}
+		}
+		var rest struct {
+			Size, Width, Height uint32
+			Masks               [8]uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &rest); err != nil {
+			return nil, framebufferReadError(err, "header")
+		}
+		header = framebufferHeader{
+			Bpp: bpp, Size: rest.Size, Width: rest.Width, Height: rest.Height,
+			RedOffset: rest.Masks[0], RedLength: rest.Masks[1],
+			BlueOffset: rest.Masks[2], BlueLength: rest.Masks[3],
+			GreenOffset: rest.Masks[4], GreenLength: rest.Masks[5],
+			AlphaOffset: rest.Masks[6], AlphaLength: rest.Masks[7],
+		}
+	default:
+		return nil, errors.Errorf(errors.ParseError, "unsupported framebuffer version %d", version)
+	}
+
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return nil, err
+	}
+
+	pixels := make([]byte, header.Size)
+	if _, err := io.ReadFull(r, pixels); err != nil {
+		return nil, framebufferReadError(err, "pixels")
+	}
+	return header.toImage(pixels), nil
+}
+
+func (h *framebufferHeader) validate() error {
+	if h.Bpp != 16 && h.Bpp != 24 && h.Bpp != 32 {
+		return errors.Errorf(errors.ParseError, "unsupported framebuffer depth of %d bits", h.Bpp)
+	}
+	if h.Width == 0 || h.Height == 0 {
+		return errors.Errorf(errors.ParseError, "framebuffer is empty")
+	}
+	if uint64(h.Width)*uint64(h.Height)*uint64(h.Bpp/8) != uint64(h.Size) {
+		return errors.Errorf(errors.ParseError, "framebuffer size %d doesn't match %dx%d at %d bits",
+			h.Size, h.Width, h.Height, h.Bpp)
+	}
+	for _, length := range []uint32{h.RedLength, h.GreenLength, h.BlueLength, h.AlphaLength} {
+		if length > 8 {
+			return errors.Errorf(errors.ParseError, "unsupported framebuffer channel length %d", length)
+		}
+	}
+	return nil
+}
+
+func (h *framebufferHeader) toImage(pixels []byte) image.Image {
+	width, height := int(h.Width), int(h.Height)
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	// Most devices use RGBA_8888 or RGBX_8888, which are already in the image's layout.
+	if h.Bpp == 32 && h.RedOffset == 0 && h.GreenOffset == 8 && h.BlueOffset == 16 &&
+		h.RedLength == 8 && h.GreenLength == 8 && h.BlueLength == 8 {
+		copy(img.Pix, pixels)
+		if h.AlphaLength == 0 {
+			for i := 3; i < len(img.Pix); i += 4 {
+				img.Pix[i] = 0xff
+			}
+		}
+		return img
+	}
+
+	bytesPerPixel := int(h.Bpp / 8)
+	for i, j := 0, 0; i < len(pixels); i, j = i+bytesPerPixel, j+4 {
+		var value uint32
+		for k := bytesPerPixel - 1; k >= 0; k-- {
+			value = value<<8 | uint32(pixels[i+k])
+		}
+		img.Pix[j] = framebufferChannel(value, h.RedOffset, h.RedLength)
+		img.Pix[j+1] = framebufferChannel(value, h.GreenOffset, h.GreenLength)
+		img.Pix[j+2] = framebufferChannel(value, h.BlueOffset, h.BlueLength)
+		if h.AlphaLength == 0 {
+			img.Pix[j+3] = 0xff
+		} else {
+			img.Pix[j+3] = framebufferChannel(value, h.AlphaOffset, h.AlphaLength)
+		}
+	}
+	return img
+}
+
+// framebufferChannel extracts the channel of length bits at offset from value, scaled to 8 bits.
+func framebufferChannel(value, offset, length uint32) uint8 {
+	if length == 0 {
+		return 0
+	}
+	maxValue := uint32(1)<<length - 1
+	return uint8((value >> offset & maxValue) * 0xff / maxValue)
+}
+
+func framebufferReadError(err error, part string) error {
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.WrapErrorf(err, errors.ConnectionResetError, "incomplete framebuffer %s", part)
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error reading framebuffer %s", part)
+}
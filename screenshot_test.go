@@ -0,0 +1,122 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func framebufferBytes(header []uint32, pixels []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, header)
+	b.Write(pixels)
+	return b.Bytes()
+}
+
+func TestDecodeFramebufferRGBA(t *testing.T) {
+	pixels := []byte{
+		0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x80,
+		0x00, 0x00, 0xff, 0x00, 0x10, 0x20, 0x30, 0x40,
+	}
+	data := framebufferBytes([]uint32{1, 32, 16, 2, 2, 0, 8, 16, 8, 8, 8, 24, 8}, pixels)
+
+	var nudge bytes.Buffer
+	img, err := decodeFramebuffer(bytes.NewReader(data), &nudge)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0}, nudge.Bytes())
+	assert.Equal(t, image.Rect(0, 0, 2, 2), img.Bounds())
+	assert.Equal(t, color.NRGBA{0x00, 0xff, 0x00, 0x80}, img.At(1, 0))
+	assert.Equal(t, color.NRGBA{0x10, 0x20, 0x30, 0x40}, img.At(1, 1))
+}
+
+func TestDecodeFramebufferBGRXVersion2(t *testing.T) {
+	pixels := []byte{0x30, 0x20, 0x10, 0x00}
+	data := framebufferBytes([]uint32{2, 32, 0, 4, 1, 1, 16, 8, 0, 8, 8, 8, 24, 0}, pixels)
+
+	img, err := decodeFramebuffer(bytes.NewReader(data), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, color.NRGBA{0x10, 0x20, 0x30, 0xff}, img.At(0, 0))
+}
+
+func TestDecodeFramebufferLegacyRGB565(t *testing.T) {
+	// Pure red, then pure blue.
+	pixels := []byte{0x00, 0xf8, 0x1f, 0x00}
+	data := framebufferBytes([]uint32{16, 4, 2, 1}, pixels)
+
+	img, err := decodeFramebuffer(bytes.NewReader(data), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, color.NRGBA{0xff, 0, 0, 0xff}, img.At(0, 0))
+	assert.Equal(t, color.NRGBA{0, 0, 0xff, 0xff}, img.At(1, 0))
+}
+
+func TestDecodeFramebufferErrors(t *testing.T) {
+	_, err := decodeFramebuffer(bytes.NewReader(framebufferBytes([]uint32{3}, nil)), io.Discard)
+	assert.True(t, HasErrCode(err, ParseError))
+
+	_, err = decodeFramebuffer(bytes.NewReader(framebufferBytes([]uint32{1, 32, 15, 2, 2, 0, 8, 16, 8, 8, 8, 24, 8}, nil)), io.Discard)
+	assert.True(t, HasErrCode(err, ParseError))
+
+	_, err = decodeFramebuffer(bytes.NewReader(framebufferBytes([]uint32{1, 32, 4, 1, 1, 0, 8, 16, 8, 8, 8, 24, 8}, []byte{1})), io.Discard)
+	assert.True(t, HasErrCode(err, ConnectionResetError))
+}
+
+func TestScreenshotFramebuffer(t *testing.T) {
+	d := newFakeDevice()
+	d.services["framebuffer:"] = func(conn net.Conn, req string) {
+		conn.Write(framebufferBytes([]uint32{1, 32, 4, 1, 1, 0, 8, 16, 8, 8, 8, 24, 8}, nil))
+		nudge := make([]byte, 1)
+		if _, err := io.ReadFull(conn, nudge); err == nil {
+			conn.Write([]byte{1, 2, 3, 4})
+		}
+	}
+	device := newFakeDeviceClient(d)
+
+	img, err := device.Screenshot(context.Background())
+	require.NoError(t, err)
+	d.wait()
+	assert.Equal(t, color.NRGBA{1, 2, 3, 4}, img.At(0, 0))
+	assert.Equal(t, []string{"framebuffer:"}, d.requests)
+}
+
+func TestScreenshotFallsBackToScreencap(t *testing.T) {
+	expected := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	expected.Set(2, 1, color.NRGBA{0xaa, 0xbb, 0xcc, 0xff})
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, expected))
+
+	d := newFakeDevice()
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		conn.Write(encoded.Bytes())
+	}
+	device := newFakeDeviceClient(d)
+
+	img, err := device.Screenshot(context.Background())
+	require.NoError(t, err)
+	d.wait()
+	assert.Equal(t, image.Rect(0, 0, 3, 2), img.Bounds())
+	r, g, b, _ := img.At(2, 1).RGBA()
+	assert.Equal(t, []uint32{0xaa, 0xbb, 0xcc}, []uint32{r >> 8, g >> 8, b >> 8})
+	assert.Equal(t, []string{"framebuffer:", "exec:screencap -p"}, d.requests)
+}
+
+func TestScreenshotInvalidPNG(t *testing.T) {
+	d := newFakeDevice()
+	d.services["framebuffer:"] = func(conn net.Conn, req string) {}
+	d.services["exec:"] = func(conn net.Conn, req string) {
+		io.WriteString(conn, "screencap: not found\n")
+	}
+	device := newFakeDeviceClient(d)
+
+	_, err := device.Screenshot(context.Background())
+	assert.True(t, HasErrCode(err, ParseError))
+	d.wait()
+}
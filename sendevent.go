@@ -0,0 +1,308 @@
+package adb
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// Linux input event types and codes, from linux/input-event-codes.h.
+const (
+	evSyn = 0
+	evKey = 1
+	evAbs = 3
+
+	synReport       = 0
+	btnTouch        = 0x14a
+	absMtSlot       = 0x2f
+	absMtPositionX  = 0x35
+	absMtPositionY  = 0x36
+	absMtTrackingID = 0x39
+	absMtPressure   = 0x3a
+)
+
+// TouchScreen is a multi-touch input device, as found by Input.TouchScreen. Its coordinates
+// are in its own units, which may differ from the display's pixels; see FromDisplay.
+type TouchScreen struct {
+	// Path is the device's node, e.g. "/dev/input/event2".
+	Path string
+	Name string
+
+	MinX, MaxX int
+	MinY, MaxY int
+	// Slots is the number of touches the device tracks at once.
+	Slots int
+	// MaxPressure is the largest pressure the device reports, or 0 if it doesn't.
+	MaxPressure int
+}
+
+// FromDisplay converts x, y, in pixels of a display width by height pixels, to the touch
+// screen's coordinates.
+func (ts *TouchScreen) FromDisplay(x, y, width, height int) (int, int) {
+	scale := func(v, size, lo, hi int) int {
+		if size <= 1 {
+			return lo
+		}
+		return lo + v*(hi-lo)/(size-1)
+	}
+	return scale(x, width, ts.MinX, ts.MaxX), scale(y, height, ts.MinY, ts.MaxY)
+}
+
+/*
+TouchScreen returns the first input device that supports multi-touch, with the slots of
+the kernel's protocol B. Returns a FileNoExistError if there's none, e.g. on an emulator
+without touch input.
+
+Corresponds to the command:
+
+	adb shell getevent -pl
+*/
+func (in *Input) TouchScreen() (*TouchScreen, error) {
+	cmdline := "getevent -pl"
+	result, err := in.device.runShellCommand(cmdline)
+	if err != nil {
+		return nil, wrapClientError(err, in.device, "TouchScreen")
+	}
+	if err := commandError(cmdline, result); err != nil {
+		return nil, wrapClientError(err, in.device, "TouchScreen")
+	}
+	ts := findTouchScreen(string(result.Stdout))
+	if ts == nil {
+		return nil, wrapClientError(errors.Errorf(errors.FileNoExistError, "no multi-touch input device"), in.device, "TouchScreen")
+	}
+	return ts, nil
+}
+
+var (
+	getEventDeviceRegex = regexp.MustCompile(`^add device \d+: (\S+)`)
+	getEventNameRegex   = regexp.MustCompile(`^\s*name:\s*"(.*)"`)
+	getEventAbsRegex    = regexp.MustCompile(`(ABS_MT_\w+)\s*: value -?\d+, min (-?\d+), max (-?\d+)`)
+)
+
+/*
+findTouchScreen returns the first device in the output of getevent -pl with the axes of
+multi-touch protocol B, or nil if there's none:
+
+	add device 2: /dev/input/event2
+	  name:     "sec_touchscreen"
+	  events:
+	    KEY (0001): BTN_TOUCH
+	    ABS (0003): ABS_MT_SLOT           : value 0, min 0, max 9, fuzz 0, flat 0, resolution 0
+	                ABS_MT_POSITION_X     : value 0, min 0, max 1079, fuzz 0, flat 0, resolution 0
+	                ABS_MT_POSITION_Y     : value 0, min 0, max 2399, fuzz 0, flat 0, resolution 0
+	                ABS_MT_TRACKING_ID    : value 0, min 0, max 65535, fuzz 0, flat 0, resolution 0
+*/
+func findTouchScreen(output string) *TouchScreen {
+	var ts *TouchScreen
+	var axes map[string][2]int
+	found := func() *TouchScreen {
+		if ts == nil {
+			return nil
+		}
+		slot, hasSlot := axes["ABS_MT_SLOT"]
+		x, hasX := axes["ABS_MT_POSITION_X"]
+		y, hasY := axes["ABS_MT_POSITION_Y"]
+		_, hasTrackingID := axes["ABS_MT_TRACKING_ID"]
+		if !hasSlot || !hasX || !hasY || !hasTrackingID {
+			return nil
+		}
+		ts.MinX, ts.MaxX = x[0], x[1]
+		ts.MinY, ts.MaxY = y[0], y[1]
+		ts.Slots = slot[1] + 1
+		ts.MaxPressure = axes["ABS_MT_PRESSURE"][1]
+		return ts
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := getEventDeviceRegex.FindStringSubmatch(line); m != nil {
+			if found() != nil {
+				return ts
+			}
+			ts, axes = &TouchScreen{Path: m[1]}, map[string][2]int{}
+			continue
+		}
+		if ts == nil {
+			continue
+		}
+		if m := getEventNameRegex.FindStringSubmatch(line); m != nil {
+			ts.Name = m[1]
+		} else if m := getEventAbsRegex.FindStringSubmatch(line); m != nil {
+			lo, _ := strconv.Atoi(m[2])
+			hi, _ := strconv.Atoi(m[3])
+			axes[m[1]] = [2]int{lo, hi}
+		}
+	}
+	return found()
+}
+
+// Touch is a finger on a TouchScreen in a TouchFrame, at X, Y in the touch screen's
+// coordinates. ID identifies the finger from frame to frame, from 0 to TouchScreen.Slots-1.
+type Touch struct {
+	ID   int
+	X, Y int
+}
+
+// TouchFrame is the fingers on a TouchScreen at a moment of a gesture. Fingers in the
+// previous frame that aren't in it are lifted.
+type TouchFrame []Touch
+
+/*
+Gesture plays frames on ts, interval apart, by writing the kernel's input events to the
+device with sendevent. Unlike Input.Motion, the events are pushed to the device as a
+script and run by a single shell, so there's no round trip to the host between frames, and
+several fingers can touch the screen, e.g. to pinch. Any fingers still down after the last
+frame are lifted. Each event still starts a sendevent process, so frames take at least a
+few milliseconds per event, and short intervals are stretched by that.
+
+Writing to the device requires the shell user's access to input devices, which some
+devices restrict, and bypasses the display's rotation; see TouchScreen.FromDisplay.
+
+Corresponds to the commands:
+
+	adb push <script> /data/local/tmp/
+	adb shell sh <script>
+
+where the script runs:
+
+	sendevent <device> <type> <code> <value>
+	sleep <interval>
+	...
+*/
+func (in *Input) Gesture(ts *TouchScreen, frames []TouchFrame, interval time.Duration) error {
+	if ts == nil {
+		return wrapClientError(errors.AssertionErrorf("touch screen cannot be nil"), in.device, "Gesture")
+	}
+	script, err := gestureScript(ts, frames, interval)
+	if err != nil {
+		return wrapClientError(err, in.device, "Gesture(%s)", ts.Path)
+	}
+
+	path := fmt.Sprintf(gestureScriptPath, time.Now().UnixNano())
+	if err := in.pushScript(path, script); err != nil {
+		return wrapClientError(err, in.device, "Gesture(%s)", ts.Path)
+	}
+
+	cmdline := fmt.Sprintf("sh %[1]s; status=$?; rm -f %[1]s; exit $status", shellQuote(path))
+	result, err := in.device.runShellCommand(cmdline)
+	if err == nil {
+		err = commandError(cmdline, result)
+	}
+	return wrapClientError(err, in.device, "Gesture(%s)", ts.Path)
+}
+
+// pushScript writes script to path, and returns once adbd has confirmed it's written.
+func (in *Input) pushScript(path, script string) error {
+	session, err := in.device.NewSyncSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := session.Close(); err != nil {
+			log.Printf("[Device] error closing sync session: %s", err)
+		}
+	}()
+
+	w, err := session.OpenWrite(path, 0644, MtimeOfClose)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, script); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// gestureScriptPath is where Gesture pushes its script, with a unique number. The shell
+// user can write and run files in /data/local/tmp.
+const gestureScriptPath = "/data/local/tmp/goadb-gesture-%d.sh"
+
+// gestureScript returns the shell script that plays frames. It stops at the first
+// sendevent that fails, e.g. because the shell user can't write to the device.
+func gestureScript(ts *TouchScreen, frames []TouchFrame, interval time.Duration) (string, error) {
+	if len(frames) > 0 && len(frames[len(frames)-1]) > 0 {
+		frames = append(frames, TouchFrame{})
+	}
+
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	down := map[int]bool{}
+	for i, frame := range frames {
+		events, err := touchFrameEvents(ts, frame, down)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 && interval > 0 {
+			fmt.Fprintf(&script, "sleep %s\n", strconv.FormatFloat(interval.Seconds(), 'f', -1, 64))
+		}
+		for _, event := range events {
+			fmt.Fprintf(&script, "sendevent %s %d %d %d\n", shellQuote(ts.Path), event[0], event[1], event[2])
+		}
+	}
+	return script.String(), nil
+}
+
+// touchFrameEvents returns the type, code and value of the input events that move the
+// fingers down to frame, and updates down.
+func touchFrameEvents(ts *TouchScreen, frame TouchFrame, down map[int]bool) ([][3]int, error) {
+	var events [][3]int
+	inFrame := map[int]bool{}
+	for _, touch := range frame {
+		if touch.ID < 0 || touch.ID >= ts.Slots {
+			return nil, errors.AssertionErrorf("touch ID %d out of range of %d slots", touch.ID, ts.Slots)
+		}
+		if inFrame[touch.ID] {
+			return nil, errors.AssertionErrorf("touch ID %d repeated in frame", touch.ID)
+		}
+		inFrame[touch.ID] = true
+
+		events = append(events, [3]int{evAbs, absMtSlot, touch.ID})
+		if !down[touch.ID] {
+			// The finger's ID doubles as its tracking ID, which only has to differ from the
+			// other fingers down.
+			events = append(events, [3]int{evAbs, absMtTrackingID, touch.ID})
+		}
+		events = append(events,
+			[3]int{evAbs, absMtPositionX, touch.X},
+			[3]int{evAbs, absMtPositionY, touch.Y})
+		if ts.MaxPressure > 0 && !down[touch.ID] {
+			events = append(events, [3]int{evAbs, absMtPressure, (ts.MaxPressure + 1) / 2})
+		}
+	}
+
+	var lifted []int
+	for id := range down {
+		if !inFrame[id] {
+			lifted = append(lifted, id)
+		}
+	}
+	sort.Ints(lifted)
+	for _, id := range lifted {
+		events = append(events,
+			[3]int{evAbs, absMtSlot, id},
+			[3]int{evAbs, absMtTrackingID, -1})
+	}
+
+	if len(down) == 0 && len(frame) > 0 {
+		events = append(events, [3]int{evKey, btnTouch, 1})
+	} else if len(down) > 0 && len(frame) == 0 {
+		events = append(events, [3]int{evKey, btnTouch, 0})
+	}
+	events = append(events, [3]int{evSyn, synReport, 0})
+
+	for id := range down {
+		delete(down, id)
+	}
+	for id := range inFrame {
+		down[id] = true
+	}
+	return events, nil
+}
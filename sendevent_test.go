@@ -0,0 +1,141 @@
+package adb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGetEventOutput = `add device 1: /dev/input/event0
+  name:     "gpio-keys"
+  events:
+    KEY (0001): KEY_VOLUMEDOWN        KEY_VOLUMEUP          KEY_POWER
+  input props:
+    <none>
+add device 2: /dev/input/event3
+  name:     "sec_touchscreen"
+  events:
+    KEY (0001): BTN_TOUCH
+    ABS (0003): ABS_MT_SLOT           : value 0, min 0, max 9, fuzz 0, flat 0, resolution 0
+                ABS_MT_TOUCH_MAJOR    : value 0, min 0, max 255, fuzz 0, flat 0, resolution 0
+                ABS_MT_POSITION_X     : value 0, min 0, max 4095, fuzz 0, flat 0, resolution 0
+                ABS_MT_POSITION_Y     : value 0, min 0, max 8191, fuzz 0, flat 0, resolution 0
+                ABS_MT_TRACKING_ID    : value 0, min 0, max 65535, fuzz 0, flat 0, resolution 0
+                ABS_MT_PRESSURE       : value 0, min 0, max 63, fuzz 0, flat 0, resolution 0
+  input props:
+    INPUT_PROP_DIRECT
+`
+
+func TestTouchScreen(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice(testGetEventOutput, &cmdlines)
+
+	ts, err := device.Input().TouchScreen()
+	require.NoError(t, err)
+	assert.Equal(t, &TouchScreen{
+		Path:        "/dev/input/event3",
+		Name:        "sec_touchscreen",
+		MaxX:        4095,
+		MaxY:        8191,
+		Slots:       10,
+		MaxPressure: 63,
+	}, ts)
+	assert.Equal(t, []string{"getevent -pl"}, cmdlines)
+
+	x, y := ts.FromDisplay(1079, 1200, 1080, 2400)
+	assert.Equal(t, 4095, x)
+	assert.Equal(t, 4097, y)
+}
+
+func TestTouchScreenNone(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice(strings.SplitN(testGetEventOutput, "add device 2", 2)[0], &cmdlines)
+
+	_, err := device.Input().TouchScreen()
+	assert.True(t, HasErrCode(err, FileNoExistError), "%v", err)
+}
+
+func TestGesture(t *testing.T) {
+	var cmdlines []string
+	d, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		cmdlines = append(cmdlines, cmdline)
+		return "", "", 0
+	})
+	ts := &TouchScreen{Path: "/dev/input/event3", MaxX: 1079, MaxY: 2399, Slots: 2}
+
+	err := device.Input().Gesture(ts, []TouchFrame{
+		{{ID: 0, X: 100, Y: 200}},
+		{{ID: 0, X: 110, Y: 210}, {ID: 1, X: 300, Y: 400}},
+		{{ID: 1, X: 310, Y: 410}},
+	}, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	var path string
+	d.mu.Lock()
+	for name := range d.files {
+		if strings.HasPrefix(name, "/data/local/tmp/") {
+			path = name
+		}
+	}
+	d.mu.Unlock()
+	assert.Regexp(t, `^/data/local/tmp/goadb-gesture-\d+\.sh$`, path)
+	script, ok := d.file(path)
+	require.True(t, ok)
+	assert.Equal(t, []string{fmt.Sprintf("sh %[1]s; status=$?; rm -f %[1]s; exit $status", path)}, cmdlines)
+
+	ev := "sendevent /dev/input/event3 "
+	assert.Equal(t, strings.Join([]string{
+		"set -e",
+		ev + "3 47 0", ev + "3 57 0", ev + "3 53 100", ev + "3 54 200", ev + "1 330 1", ev + "0 0 0",
+		"sleep 0.01",
+		ev + "3 47 0", ev + "3 53 110", ev + "3 54 210",
+		ev + "3 47 1", ev + "3 57 1", ev + "3 53 300", ev + "3 54 400", ev + "0 0 0",
+		"sleep 0.01",
+		ev + "3 47 1", ev + "3 53 310", ev + "3 54 410",
+		ev + "3 47 0", ev + "3 57 -1", ev + "0 0 0",
+		"sleep 0.01",
+		ev + "3 47 1", ev + "3 57 -1", ev + "1 330 0", ev + "0 0 0",
+	}, "\n")+"\n", string(script.data))
+}
+
+func TestGesturePressure(t *testing.T) {
+	ts := &TouchScreen{Path: "/dev/input/event3", Slots: 1, MaxPressure: 63}
+
+	script, err := gestureScript(ts, []TouchFrame{{{X: 1, Y: 2}}, {{X: 3, Y: 4}}}, 0)
+	require.NoError(t, err)
+	ev := "sendevent /dev/input/event3 "
+	assert.Equal(t, strings.Join([]string{
+		"set -e",
+		ev + "3 47 0", ev + "3 57 0", ev + "3 53 1", ev + "3 54 2", ev + "3 58 32", ev + "1 330 1", ev + "0 0 0",
+		ev + "3 47 0", ev + "3 53 3", ev + "3 54 4", ev + "0 0 0",
+		ev + "3 47 0", ev + "3 57 -1", ev + "1 330 0", ev + "0 0 0",
+	}, "\n")+"\n", script)
+}
+
+func TestGestureInvalid(t *testing.T) {
+	var cmdlines []string
+	device := newFakeAmDevice("", &cmdlines)
+	ts := &TouchScreen{Path: "/dev/input/event3", Slots: 2}
+
+	err := device.Input().Gesture(ts, []TouchFrame{{{ID: 2}}}, 0)
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+	err = device.Input().Gesture(ts, []TouchFrame{{{ID: 1}, {ID: 1}}}, 0)
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+	err = device.Input().Gesture(nil, []TouchFrame{{{ID: 0}}}, 0)
+	assert.True(t, HasErrCode(err, AssertionError), "%v", err)
+	assert.Empty(t, cmdlines)
+}
+
+func TestGesturePermissionDenied(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return "", "sendevent: /dev/input/event3: Permission denied\n", 1
+	})
+	ts := &TouchScreen{Path: "/dev/input/event3", Slots: 1}
+
+	err := device.Input().Gesture(ts, []TouchFrame{{{X: 1, Y: 1}}}, 0)
+	assert.True(t, HasErrCode(err, PermissionDenied), "%v", err)
+}
@@ -0,0 +1,197 @@
+package adb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/wire"
+)
+
+// CommandErrorDetails describes a command that failed with CommandFailed, or one of the more
+// specific codes mapped from its error output. It's the Details of the error that reports
+// the failure. Device methods wrap that error, so it's the Cause of the error they return,
+// whose Details is the Device.
+type CommandErrorDetails struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+// shellResult is the outcome of a command run by runShellCommand.
+type shellResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+/*
+Features returns the features supported by both the device and the adb server,
+e.g. "shell_v2" or "cmd".
+
+Corresponds to the command:
+
+	adb features
+*/
+func (c *Device) Features() ([]string, error) {
+	attr, err := c.getAttribute("features")
+	if err != nil {
+		return nil, wrapClientError(err, c, "Features")
+	}
+	if attr == "" {
+		return nil, nil
+	}
+	return strings.Split(attr, ","), nil
+}
+
+func (c *Device) hasFeature(feature string) (bool, error) {
+	features, err := c.Features()
+	if err != nil {
+		return false, err
+	}
+	for _, f := range features {
+		if f == feature {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/*
+runShellCommand runs cmdline in the device's shell and returns its output and exit status.
+
+Devices with the shell_v2 feature report stdout, stderr and the exit status separately.
+On older devices, the command is wrapped so that it echoes its exit status after its
+output; stderr is merged into stdout, and the merged output is returned as both.
+*/
+func (c *Device) runShellCommand(cmdline string) (*shellResult, error) {
+	v2, err := c.hasFeature("shell_v2")
+	if err != nil {
+		return nil, err
+	}
+	if v2 {
+		return c.runShellV2(cmdline)
+	}
+	return c.runShellV1WithStatus(cmdline)
+}
+
+func (c *Device) runShellV2(cmdline string) (*shellResult, error) {
+	resp, err := c.readShellService("shell,v2,raw:" + cmdline)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &shellResult{}
+	r := bytes.NewReader(resp)
+	for {
+		id, data, err := wire.ReadShellPacket(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF || errors.HasErrCode(err, errors.ConnectionResetError) {
+			// E.g. the device was disconnected, or adbd was killed.
+			return nil, errors.WrapErrorf(err, errors.ConnectionResetError, "shell exited without a status")
+		} else if err != nil {
+			return nil, errors.WrapErrorf(err, errors.NetworkError, "error reading output of shell command")
+		}
+		switch id {
+		case wire.ShellStdout:
+			result.Stdout = append(result.Stdout, data...)
+		case wire.ShellStderr:
+			result.Stderr = append(result.Stderr, data...)
+		case wire.ShellExit:
+			if len(data) != 1 {
+				return nil, errors.Errorf(errors.ParseError, "invalid shell exit packet of %d bytes", len(data))
+			}
+			result.ExitCode = int(data[0])
+			return result, nil
+		}
+	}
+}
+
+// maxShellCommandLine is the longest cmdline runShellCommand can run, which has to fit in
+// a request with the wrapper added by runShellV1WithStatus.
+const maxShellCommandLine = wire.MaxMessageLength - len("shell:() 2>&1; echo; echo $?")
+
+func (c *Device) runShellV1WithStatus(cmdline string) (*shellResult, error) {
+	// The extra echo puts the status on its own line even if the output doesn't end with one.
+	resp, err := c.readShellService(fmt.Sprintf("shell:(%s) 2>&1; echo; echo $?", cmdline))
+	if err != nil {
+		return nil, err
+	}
+
+	// Old devices run shell commands in a PTY, which translates newlines.
+	output := strings.TrimSuffix(strings.ReplaceAll(string(resp), "\r\n", "\n"), "\n")
+	i := strings.LastIndex(output, "\n")
+	if i < 0 {
+		return nil, errors.Errorf(errors.ParseError, "shell output doesn't end with an exit status: %q", output)
+	}
+	exitCode, err := strconv.Atoi(output[i+1:])
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid exit status: %q", output[i+1:])
+	}
+
+	merged := []byte(output[:i])
+	return &shellResult{Stdout: merged, Stderr: merged, ExitCode: exitCode}, nil
+}
+
+// readShellService opens the shell service req and reads everything it writes.
+func (c *Device) readShellService(req string) ([]byte, error) {
+	conn, err := c.dialDevice()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("[Device] error closing connection: %s", err)
+		}
+	}()
+
+	if err = conn.SendMessage([]byte(req)); err != nil {
+		return nil, err
+	}
+	if _, err = conn.ReadStatus(req); err != nil {
+		return nil, err
+	}
+	return conn.ReadUntilEof()
+}
+
+// shellErrorCodes maps error messages printed by toybox and toolbox commands to error codes.
+var shellErrorCodes = []struct {
+	message string
+	code    errors.ErrCode
+}{
+	{"No such file or directory", errors.FileNoExistError},
+	{"File exists", errors.FileExistError},
+	{"Permission denied", errors.PermissionDenied},
+	{"Operation not permitted", errors.PermissionDenied},
+	{"Read-only file system", errors.PermissionDenied},
+}
+
+// commandError returns an error describing the failure of cmdline, with a code derived
+// from its error output. Returns nil if the command succeeded.
+func commandError(cmdline string, result *shellResult) error {
+	if result.ExitCode == 0 {
+		return nil
+	}
+
+	stderr := strings.TrimSpace(string(result.Stderr))
+	code := errors.CommandFailed
+	for _, e := range shellErrorCodes {
+		if strings.Contains(stderr, e.message) {
+			code = e.code
+			break
+		}
+	}
+
+	return &errors.Err{
+		Code:    code,
+		Message: fmt.Sprintf("command exited with status %d: %s", result.ExitCode, stderr),
+		Details: CommandErrorDetails{
+			Command:  cmdline,
+			ExitCode: result.ExitCode,
+			Stderr:   stderr,
+		},
+	}
+}
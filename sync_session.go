@@ -0,0 +1,504 @@
+package adb
+
+import (
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/basiooo/goadb/wire"
+)
+
+/*
+SyncSession keeps a single sync connection to a device open for many file operations.
+
+Every Stat, ListDirEntries, OpenRead and OpenWrite call on Device dials the server,
+switches the transport and sends "sync:" before doing any work. A session pays that
+setup cost once, and ends the connection with a QUIT request when closed.
+
+A session performs one operation at a time. Readers returned by OpenRead and writers
+returned by OpenWrite hold the connection until they are closed, and any other call made
+in the meantime returns an AssertionError. If the device reports an error in the middle of
+an operation, adbd drops the connection, so the session is unusable afterwards and every
+later call returns that error.
+*/
+type SyncSession struct {
+	device *Device
+	conn   *wire.SyncConn
+
+	// Passed to the sync helpers instead of conn, so that readers, writers and
+	// DirEntries can't close the session's connection.
+	shared *wire.SyncConn
+
+	mu     sync.Mutex
+	busy   bool
+	closed bool
+	err    error
+}
+
+// NewSyncSession opens a sync connection to the device that can be reused for many
+// operations. The caller must Close it when done.
+func (c *Device) NewSyncSession() (*SyncSession, error) {
+	conn, err := c.getSyncConn()
+	if err != nil {
+		return nil, wrapClientError(err, c, "NewSyncSession")
+	}
+	session := newSyncSession(conn)
+	session.device = c
+	return session, nil
+}
+
+func newSyncSession(conn *wire.SyncConn) *SyncSession {
+	return &SyncSession{
+		conn: conn,
+		shared: &wire.SyncConn{
+			SyncScanner: nopCloseSyncScanner{conn.SyncScanner},
+			SyncSender:  nopCloseSyncSender{conn.SyncSender},
+		},
+	}
+}
+
+func (s *SyncSession) String() string {
+	if s.device == nil {
+		return "SyncSession"
+	}
+	return "SyncSession[" + s.device.String() + "]"
+}
+
+// Stat returns information about the file at path. Returns a FileNoExistError if
+// the file doesn't exist.
+func (s *SyncSession) Stat(path string) (*DirEntry, error) {
+	if err := s.acquire(); err != nil {
+		return nil, wrapClientError(err, s, "Stat(%s)", path)
+	}
+	defer s.release()
+
+	entry, err := stat(s.shared, path)
+	if err != nil && !HasErrCode(err, FileNoExistError) {
+		// A missing file is reported as an all-zero stat, which leaves the
+		// connection usable. Anything else means the stream is broken.
+		s.fail(err)
+	}
+	return entry, wrapClientError(err, s, "Stat(%s)", path)
+}
+
+// ListDirEntries returns all the entries in the directory at path, including "." and "..".
+// Unlike Device.ListDirEntries, the listing is read completely before returning so the
+// connection is free for the next operation.
+func (s *SyncSession) ListDirEntries(path string) ([]*DirEntry, error) {
+	if err := s.acquire(); err != nil {
+		return nil, wrapClientError(err, s, "ListDirEntries(%s)", path)
+	}
+	defer s.release()
+
+	entries, err := listDirEntries(s.shared, path)
+	if err != nil {
+		s.fail(err)
+		return nil, wrapClientError(err, s, "ListDirEntries(%s)", path)
+	}
+
+	result, err := entries.ReadAll()
+	if err == nil {
+		err = skipDoneDirEntry(s.shared)
+	}
+	if err != nil {
+		if _, ok := err.(*errors.Err); !ok {
+			err = errors.WrapErrorf(err, errors.NetworkError, "error reading dir entries")
+		}
+		s.fail(err)
+		return nil, wrapClientError(err, s, "ListDirEntries(%s)", path)
+	}
+	return result, nil
+}
+
+// OpenRead opens the file at path for reading. The session can't be used for anything
+// else until the returned reader is closed.
+func (s *SyncSession) OpenRead(path string) (io.ReadCloser, error) {
+	if err := s.acquire(); err != nil {
+		return nil, wrapClientError(err, s, "OpenRead(%s)", path)
+	}
+
+	reader, err := receiveFile(s.shared, path)
+	if err != nil {
+		s.fail(err)
+		s.release()
+		return nil, wrapClientError(err, s, "OpenRead(%s)", path)
+	}
+	return &syncSessionReader{session: s, reader: reader}, nil
+}
+
+// OpenWrite opens the file at path on the device for writing, creating it with the
+// permissions specified by perms if necessary. See Device.OpenWrite for the meaning of mtime.
+// The session can't be used for anything else until the returned writer is closed.
+func (s *SyncSession) OpenWrite(path string, perms os.FileMode, mtime time.Time) (io.WriteCloser, error) {
+	if err := s.acquire(); err != nil {
+		return nil, wrapClientError(err, s, "OpenWrite(%s)", path)
+	}
+
+	writer, err := sendFile(s.shared, path, perms, mtime)
+	if err != nil {
+		s.fail(err)
+		s.release()
+		return nil, wrapClientError(err, s, "OpenWrite(%s)", path)
+	}
+	return &syncSessionWriter{session: s, writer: writer}, nil
+}
+
+/*
+Walk walks the file tree rooted at root, calling fn for each file or directory
+in the tree, including root. Entries in a directory are visited in lexical order,
+and symlinks are not followed.
+
+fn may return fs.SkipDir to skip a directory, or fs.SkipAll to stop walking. If
+reading a directory fails, fn is called a second time for that directory with the error.
+*/
+func (s *SyncSession) Walk(root string, fn WalkFunc) error {
+	entry, err := s.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		entry.Name = path.Base(root)
+		err = s.walk(root, entry, fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (s *SyncSession) walk(dir string, entry *DirEntry, fn WalkFunc) error {
+	if err := fn(dir, entry, nil); err != nil || !entry.Mode.IsDir() {
+		return err
+	}
+
+	entries, err := s.ListDirEntries(dir)
+	if err != nil {
+		return fn(dir, entry, err)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	for _, child := range entries {
+		if child.Name == "." || child.Name == ".." {
+			continue
+		}
+		if err := s.walk(path.Join(dir, child.Name), child, fn); err != nil {
+			if err == fs.SkipDir && child.Mode.IsDir() {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Close ends the session by sending QUIT and closing the connection.
+// Calling Close while a reader or writer is still open is an error.
+func (s *SyncSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	if s.busy {
+		return wrapClientError(errors.AssertionErrorf("sync session closed with an operation in progress"), s, "Close")
+	}
+	s.closed = true
+
+	var quitErr error
+	if s.err == nil {
+		quitErr = s.conn.SendOctetString("QUIT")
+		if quitErr == nil {
+			quitErr = s.conn.SendInt32(0)
+		}
+	}
+	closeErr := s.conn.Close()
+	return wrapClientError(errors.CombineErrs("error closing sync session", errors.NetworkError, quitErr, closeErr), s, "Close")
+}
+
+// Err returns the error that broke the session, or nil if it's still usable.
+func (s *SyncSession) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *SyncSession) acquire() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.closed:
+		return errors.AssertionErrorf("sync session is closed")
+	case s.err != nil:
+		return s.err
+	case s.busy:
+		return errors.AssertionErrorf("sync session is busy: close the previous reader or writer first")
+	}
+	s.busy = true
+	return nil
+}
+
+func (s *SyncSession) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy = false
+}
+
+// fail records err as the reason the session can't be used anymore.
+func (s *SyncSession) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// WalkFunc is called by SyncSession.Walk and Device.Walk for each visited path.
+// entry is nil if err is non-nil and the path could not be stat'd.
+type WalkFunc func(path string, entry *DirEntry, err error) error
+
+// Walk walks the file tree rooted at root over a single sync session.
+// See SyncSession.Walk.
+func (c *Device) Walk(root string, fn WalkFunc) error {
+	session, err := c.NewSyncSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := session.Close(); err != nil {
+			log.Printf("[Device] error closing sync session: %s", err)
+		}
+	}()
+	return session.Walk(root, fn)
+}
+
+// syncSessionReader keeps the session busy until the file has been read or discarded.
+type syncSessionReader struct {
+	session *SyncSession
+	reader  io.ReadCloser
+	eof     bool
+	closed  bool
+}
+
+func (r *syncSessionReader) Read(buf []byte) (int, error) {
+	if r.closed {
+		return 0, errors.AssertionErrorf("read from closed file")
+	}
+	if r.eof {
+		return 0, io.EOF
+	}
+
+	n, err := r.reader.Read(buf)
+	if err == io.EOF {
+		r.eof = true
+		// The DONE chunk carries an unused length that must be consumed before
+		// the next request.
+		if _, lenErr := r.session.shared.ReadInt32(); lenErr != nil {
+			r.session.fail(lenErr)
+			return n, lenErr
+		}
+	} else if err != nil {
+		r.session.fail(err)
+	}
+	return n, err
+}
+
+// Close discards any unread data so the connection can be reused, then releases the session.
+func (r *syncSessionReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	var err error
+	if !r.eof {
+		_, err = io.Copy(io.Discard, r)
+	}
+	r.closed = true
+	r.session.release()
+	return err
+}
+
+// abort gives up on the file without reading the rest of it. The session is
+// left broken, since the stream can't be resynchronized.
+func (r *syncSessionReader) abort(err error) {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.session.fail(err)
+	r.session.release()
+}
+
+// syncSessionWriter waits for adbd to acknowledge the file when it's closed,
+// so the connection can be reused.
+type syncSessionWriter struct {
+	session *SyncSession
+	writer  io.WriteCloser
+	closed  bool
+}
+
+func (w *syncSessionWriter) Write(buf []byte) (int, error) {
+	if w.closed {
+		return 0, errors.AssertionErrorf("write to closed file")
+	}
+	n, err := w.writer.Write(buf)
+	if err != nil {
+		w.session.fail(err)
+	}
+	return n, err
+}
+
+func (w *syncSessionWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.session.release()
+
+	if err := w.writer.Close(); err != nil {
+		w.session.fail(err)
+		return err
+	}
+	if err := readSyncOkay(w.session.shared, "send"); err != nil {
+		w.session.fail(err)
+		return err
+	}
+	return nil
+}
+
+// abort gives up on the file without sending DONE, so adbd never commits a
+// partial file. The session is left broken.
+func (w *syncSessionWriter) abort(err error) {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.session.fail(err)
+	w.session.release()
+}
+
+// readSyncOkay reads the OKAY response adbd sends after a file has been received,
+// along with its unused length.
+func readSyncOkay(s wire.SyncScanner, req string) error {
+	status, err := s.ReadStatus(req)
+	if err != nil {
+		return err
+	}
+	if status != wire.StatusSuccess {
+		return errors.Errorf(errors.AssertionError, "expected status '%s' for %s, but got '%s'", wire.StatusSuccess, req, status)
+	}
+	_, err = s.ReadInt32()
+	return err
+}
+
+// skipDoneDirEntry consumes the mode, size, time and name length that follow the
+// DONE id at the end of a directory listing.
+func skipDoneDirEntry(s wire.SyncScanner) error {
+	for i := 0; i < 4; i++ {
+		if _, err := s.ReadInt32(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type nopCloseSyncScanner struct {
+	wire.SyncScanner
+}
+
+func (nopCloseSyncScanner) Close() error { return nil }
+
+type nopCloseSyncSender struct {
+	wire.SyncSender
+}
+
+func (nopCloseSyncSender) Close() error { return nil }
+
+/*
+SyncPool keeps a small number of idle SyncSessions to a device, so that parallel
+transfers can each use their own connection without dialing one per file.
+
+Get returns an idle session or opens a new one. Put returns a session to the pool;
+broken sessions and sessions over the pool's size are closed instead.
+*/
+type SyncPool struct {
+	device *Device
+	idle   chan *SyncSession
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSyncPool returns a pool that keeps at most size idle sessions open.
+// A size less than 1 is treated as 1.
+func (c *Device) NewSyncPool(size int) *SyncPool {
+	if size < 1 {
+		size = 1
+	}
+	return &SyncPool{
+		device: c,
+		idle:   make(chan *SyncSession, size),
+	}
+}
+
+// Get returns an idle session from the pool, or opens a new one.
+func (p *SyncPool) Get() (*SyncSession, error) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, wrapClientError(errors.AssertionErrorf("sync pool is closed"), p, "Get")
+	}
+
+	select {
+	case session, ok := <-p.idle:
+		if ok {
+			return session, nil
+		}
+		return nil, wrapClientError(errors.AssertionErrorf("sync pool is closed"), p, "Get")
+	default:
+		return p.device.NewSyncSession()
+	}
+}
+
+// Put returns session to the pool.
+func (p *SyncPool) Put(session *SyncSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed && session.Err() == nil {
+		select {
+		case p.idle <- session:
+			return
+		default:
+		}
+	}
+	if err := session.Close(); err != nil {
+		log.Printf("[SyncPool] error closing sync session: %s", err)
+	}
+}
+
+// Close closes all idle sessions. Sessions that are checked out are closed when they're Put back.
+func (p *SyncPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.idle)
+
+	var errs []error
+	for session := range p.idle {
+		errs = append(errs, session.Close())
+	}
+	return errors.CombineErrs("error closing sync pool", errors.NetworkError, errs...)
+}
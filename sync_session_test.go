@@ -0,0 +1,243 @@
+package adb
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/basiooo/goadb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeDeviceClient(d *fakeDevice) *Device {
+	return (&Adb{d}).Device(DeviceWithSerial("serial"))
+}
+
+func TestSyncSessionReusesConnection(t *testing.T) {
+	d := newFakeDevice()
+	d.addFile("/sdcard/a.txt", "hello world", 0644)
+	device := newFakeDeviceClient(d)
+
+	session, err := device.NewSyncSession()
+	require.NoError(t, err)
+
+	entry, err := session.Stat("/sdcard/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int32(11), entry.Size)
+
+	entries, err := session.ListDirEntries("/sdcard")
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	r, err := session.OpenRead("/sdcard/a.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	require.NoError(t, r.Close())
+
+	w, err := session.OpenWrite("/sdcard/b.txt", 0600, someTime)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("written"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = session.Stat("/sdcard/missing")
+	assert.True(t, HasErrCode(err, FileNoExistError))
+	assert.NoError(t, session.Err())
+
+	require.NoError(t, session.Close())
+	d.wait()
+
+	assert.Equal(t, 1, d.dials)
+	assert.Equal(t, []string{"STAT", "LIST", "RECV", "SEND", "STAT", "QUIT"}, d.syncRequests)
+	f, ok := d.file("/sdcard/b.txt")
+	require.True(t, ok)
+	assert.Equal(t, "written", string(f.data))
+	assert.Equal(t, os.FileMode(0600), f.mode)
+	assert.Equal(t, someTime, f.mtime)
+}
+
+func TestSyncSessionCloseReaderEarlyDiscardsRest(t *testing.T) {
+	d := newFakeDevice()
+	d.addFile("/a", "0123456789", 0644)
+	d.addFile("/b", "b", 0644)
+	session, err := newFakeDeviceClient(d).NewSyncSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	r, err := session.OpenRead("/a")
+	require.NoError(t, err)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	r, err = session.OpenRead("/b")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+	require.NoError(t, r.Close())
+}
+
+func TestSyncSessionBusy(t *testing.T) {
+	d := newFakeDevice()
+	d.addFile("/a", "abc", 0644)
+	session, err := newFakeDeviceClient(d).NewSyncSession()
+	require.NoError(t, err)
+
+	r, err := session.OpenRead("/a")
+	require.NoError(t, err)
+
+	_, err = session.Stat("/a")
+	assert.True(t, HasErrCode(err, AssertionError))
+	assert.True(t, HasErrCode(session.Close(), AssertionError))
+
+	require.NoError(t, r.Close())
+	_, err = session.Stat("/a")
+	assert.NoError(t, err)
+	assert.NoError(t, session.Close())
+}
+
+func TestSyncSessionBrokenAfterFailure(t *testing.T) {
+	d := newFakeDevice()
+	session, err := newFakeDeviceClient(d).NewSyncSession()
+	require.NoError(t, err)
+
+	_, err = session.OpenRead("/missing")
+	assert.True(t, HasErrCode(err, FileNoExistError))
+	assert.Error(t, session.Err())
+
+	_, err = session.Stat("/")
+	assert.True(t, HasErrCode(err, FileNoExistError))
+	assert.NoError(t, session.Close())
+	d.wait()
+	assert.Equal(t, []string{"RECV"}, d.syncRequests)
+}
+
+func TestSyncSessionEmptyFile(t *testing.T) {
+	var resp, req bytes.Buffer
+	sender := wire.NewSyncSender(&resp)
+	sender.SendOctetString(wire.StatusSyncDone)
+	sender.SendInt32(0)
+	sender.SendOctetString("STAT")
+	sender.SendFileMode(0644)
+	sender.SendInt32(0)
+	sender.SendTime(someTime)
+
+	session := newSyncSession(&wire.SyncConn{
+		SyncScanner: wire.NewSyncScanner(&resp),
+		SyncSender:  wire.NewSyncSender(&req),
+	})
+
+	r, err := session.OpenRead("/empty")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	require.NoError(t, r.Close())
+
+	entry, err := session.Stat("/empty")
+	require.NoError(t, err)
+	assert.Equal(t, someTime, entry.ModifiedAt)
+}
+
+func TestSyncSessionWalk(t *testing.T) {
+	d := newFakeDevice()
+	d.addFile("/data/b/2", "2", 0644)
+	d.addFile("/data/a", "a", 0644)
+	d.addFile("/data/c/skipped", "x", 0644)
+	d.addFile("/data/d", "d", 0644)
+	device := newFakeDeviceClient(d)
+
+	var visited []string
+	err := device.Walk("/data", func(path string, entry *DirEntry, err error) error {
+		require.NoError(t, err)
+		visited = append(visited, path)
+		if path == "/data/c" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data", "/data/a", "/data/b", "/data/b/2", "/data/c", "/data/d"}, visited)
+	assert.Equal(t, 1, d.dials)
+}
+
+func TestSyncSessionWalkMissingRoot(t *testing.T) {
+	d := newFakeDevice()
+	device := newFakeDeviceClient(d)
+
+	var gotErr error
+	err := device.Walk("/nope", func(path string, entry *DirEntry, err error) error {
+		assert.Nil(t, entry)
+		gotErr = err
+		return err
+	})
+	assert.True(t, HasErrCode(gotErr, FileNoExistError))
+	assert.True(t, HasErrCode(err, FileNoExistError))
+}
+
+func TestSyncPoolReusesSessions(t *testing.T) {
+	d := newFakeDevice()
+	d.addFile("/a", "a", 0644)
+	pool := newFakeDeviceClient(d).NewSyncPool(2)
+
+	s1, err := pool.Get()
+	require.NoError(t, err)
+	s2, err := pool.Get()
+	require.NoError(t, err)
+	pool.Put(s1)
+	pool.Put(s2)
+
+	s3, err := pool.Get()
+	require.NoError(t, err)
+	_, err = s3.Stat("/a")
+	assert.NoError(t, err)
+	pool.Put(s3)
+
+	assert.Equal(t, 2, d.dials)
+	require.NoError(t, pool.Close())
+
+	_, err = pool.Get()
+	assert.True(t, HasErrCode(err, AssertionError))
+}
+
+func TestSyncPoolDiscardsBrokenSessions(t *testing.T) {
+	d := newFakeDevice()
+	pool := newFakeDeviceClient(d).NewSyncPool(1)
+
+	s, err := pool.Get()
+	require.NoError(t, err)
+	_, err = s.OpenRead("/missing")
+	require.Error(t, err)
+	pool.Put(s)
+
+	s, err = pool.Get()
+	require.NoError(t, err)
+	assert.NoError(t, s.Err())
+	pool.Put(s)
+	assert.Equal(t, 2, d.dials)
+	assert.NoError(t, pool.Close())
+}
+
+func TestSyncSessionOpenWriteZeroMtime(t *testing.T) {
+	d := newFakeDevice()
+	session, err := newFakeDeviceClient(d).NewSyncSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	before := time.Now().Add(-time.Second)
+	w, err := session.OpenWrite("/new", 0644, MtimeOfClose)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	f, ok := d.file("/new")
+	require.True(t, ok)
+	assert.True(t, f.mtime.After(before))
+}
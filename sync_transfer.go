@@ -0,0 +1,389 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// TransferOptions configures PushDir, PullDir and SyncDir.
+type TransferOptions struct {
+	// Concurrency is the number of files transferred at the same time, each over its own
+	// sync connection. Values less than 1 mean 1.
+	Concurrency int
+
+	// Progress, if non-nil, is called each time a file finishes transferring, whether it
+	// succeeded or not. Calls are never concurrent.
+	Progress func(TransferProgress)
+}
+
+// TransferredFile is the outcome of transferring a single file as part of a directory transfer.
+type TransferredFile struct {
+	LocalPath  string
+	RemotePath string
+	Size       int64
+
+	// Skipped is set by SyncDir for files that were already up to date on the device.
+	Skipped bool
+
+	// Err is non-nil if the file could not be transferred.
+	Err error
+}
+
+// TransferProgress is reported after each file of a directory transfer completes.
+type TransferProgress struct {
+	File TransferredFile
+
+	// FilesDone counts every file processed so far, including those skipped or failed.
+	FilesDone  int
+	FilesTotal int
+	// BytesDone only counts the files actually transferred, so it stays below BytesTotal if
+	// any were skipped or failed.
+	BytesDone  int64
+	BytesTotal int64
+}
+
+// TransferResult is the outcome of a directory transfer.
+// Files are sorted by RemotePath, so the result doesn't depend on the order in which
+// concurrent transfers happened to complete.
+type TransferResult struct {
+	Files []TransferredFile
+
+	// Bytes is the total size of the files that were transferred, excluding skipped
+	// and failed files.
+	Bytes int64
+}
+
+// Failed returns the files that could not be transferred.
+func (r *TransferResult) Failed() []TransferredFile {
+	var failed []TransferredFile
+	for _, f := range r.Files {
+		if f.Err != nil {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+/*
+PushDir copies every regular file under localDir to the same relative path under remoteDir,
+preserving permissions and modification times. Up to opts.Concurrency files are sent at
+once, each over its own sync connection.
+
+Every file is attempted even if some fail. The returned error combines the errors of all
+failed files, in path order; the per-file errors are also available in the result.
+Empty directories are not created on the device.
+*/
+func (c *Device) PushDir(ctx context.Context, localDir, remoteDir string, opts TransferOptions) (*TransferResult, error) {
+	jobs, err := listLocalTransfers(localDir, remoteDir)
+	if err != nil {
+		return nil, wrapClientError(err, c, "PushDir(%s, %s)", localDir, remoteDir)
+	}
+
+	result, err := c.runTransfers(ctx, jobs, opts, func(session *SyncSession, job *transferJob) (bool, error) {
+		return false, pushFile(ctx, session, job)
+	})
+	return result, wrapClientError(err, c, "PushDir(%s, %s)", localDir, remoteDir)
+}
+
+/*
+SyncDir is like PushDir, but skips files whose size and modification time on the device
+already match the local file. Since pushed files keep their local modification time,
+running SyncDir again right after a successful sync transfers nothing.
+*/
+func (c *Device) SyncDir(ctx context.Context, localDir, remoteDir string, opts TransferOptions) (*TransferResult, error) {
+	jobs, err := listLocalTransfers(localDir, remoteDir)
+	if err != nil {
+		return nil, wrapClientError(err, c, "SyncDir(%s, %s)", localDir, remoteDir)
+	}
+
+	result, err := c.runTransfers(ctx, jobs, opts, func(session *SyncSession, job *transferJob) (bool, error) {
+		entry, err := session.Stat(job.file.RemotePath)
+		if err == nil && entry.Mode.IsRegular() &&
+			int64(entry.Size) == job.file.Size && entry.ModifiedAt.Equal(job.mtime) {
+			return true, nil
+		}
+		if err != nil && !HasErrCode(err, FileNoExistError) {
+			return false, err
+		}
+		return false, pushFile(ctx, session, job)
+	})
+	return result, wrapClientError(err, c, "SyncDir(%s, %s)", localDir, remoteDir)
+}
+
+/*
+PullDir copies every regular file under remoteDir on the device to the same relative path
+under localDir, creating directories as needed and preserving permissions and modification
+times. Up to opts.Concurrency files are received at once, each over its own sync connection.
+
+Errors are reported the same way as PushDir.
+*/
+func (c *Device) PullDir(ctx context.Context, remoteDir, localDir string, opts TransferOptions) (*TransferResult, error) {
+	pool := c.NewSyncPool(opts.Concurrency)
+	session, err := pool.Get()
+	if err != nil {
+		return nil, wrapClientError(err, c, "PullDir(%s, %s)", remoteDir, localDir)
+	}
+
+	var jobs []*transferJob
+	err = session.Walk(remoteDir, func(remotePath string, entry *DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.WrapErrorf(err, errors.CommandCanceled, "transfer canceled")
+		}
+
+		rel := strings.TrimPrefix(path.Clean(remotePath), path.Clean(remoteDir))
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		switch {
+		case entry.Mode.IsDir():
+			return errors.WrapErrorf(os.MkdirAll(localPath, 0755), errors.LocalFileError, "error creating directory %s", localPath)
+		case entry.Mode.IsRegular():
+			jobs = append(jobs, &transferJob{
+				file: TransferredFile{
+					LocalPath:  localPath,
+					RemotePath: remotePath,
+					Size:       int64(entry.Size),
+				},
+				mode:  entry.Mode.Perm(),
+				mtime: entry.ModifiedAt,
+			})
+		}
+		return nil
+	})
+	pool.Put(session)
+	if err != nil {
+		if err := pool.Close(); err != nil {
+			log.Printf("[Device] error closing sync pool: %s", err)
+		}
+		return nil, wrapClientError(err, c, "PullDir(%s, %s)", remoteDir, localDir)
+	}
+
+	result, err := c.runTransfersWithPool(ctx, pool, jobs, opts, func(session *SyncSession, job *transferJob) (bool, error) {
+		return false, pullFile(ctx, session, job)
+	})
+	return result, wrapClientError(err, c, "PullDir(%s, %s)", remoteDir, localDir)
+}
+
+type transferJob struct {
+	file  TransferredFile
+	mode  os.FileMode
+	mtime time.Time
+}
+
+// transferFunc transfers a single file over session, and reports whether it was skipped.
+type transferFunc func(session *SyncSession, job *transferJob) (skipped bool, err error)
+
+func (c *Device) runTransfers(ctx context.Context, jobs []*transferJob, opts TransferOptions, fn transferFunc) (*TransferResult, error) {
+	return c.runTransfersWithPool(ctx, c.NewSyncPool(opts.Concurrency), jobs, opts, fn)
+}
+
+// runTransfersWithPool runs fn for every job with at most opts.Concurrency running at once,
+// then closes pool.
+func (c *Device) runTransfersWithPool(ctx context.Context, pool *SyncPool, jobs []*transferJob, opts TransferOptions, fn transferFunc) (*TransferResult, error) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].file.RemotePath < jobs[j].file.RemotePath
+	})
+
+	progress := TransferProgress{FilesTotal: len(jobs)}
+	for _, job := range jobs {
+		progress.BytesTotal += job.file.Size
+	}
+	var progressMu sync.Mutex
+	report := func(job *transferJob) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		progress.File = job.file
+		progress.FilesDone++
+		if job.file.Err == nil && !job.file.Skipped {
+			progress.BytesDone += job.file.Size
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	queue := make(chan *transferJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				job.file.Skipped, job.file.Err = runTransfer(ctx, pool, job, fn)
+				report(job)
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	result := &TransferResult{Files: make([]TransferredFile, len(jobs))}
+	var errs []error
+	for i, job := range jobs {
+		result.Files[i] = job.file
+		if job.file.Err != nil {
+			errs = append(errs, job.file.Err)
+		} else if !job.file.Skipped {
+			result.Bytes += job.file.Size
+		}
+	}
+
+	closeErr := pool.Close()
+	if err := ctx.Err(); err != nil {
+		return result, errors.WrapErrorf(err, errors.CommandCanceled, "transfer canceled")
+	}
+	if len(errs) > 0 {
+		msg := fmt.Sprintf("%d of %d files failed to transfer", len(errs), len(jobs))
+		code := errors.NetworkError
+		if e, ok := errs[0].(*errors.Err); ok {
+			code = e.Code
+		}
+		return result, errors.CombineErrs(msg, code, errs...)
+	}
+	return result, closeErr
+}
+
+func runTransfer(ctx context.Context, pool *SyncPool, job *transferJob, fn transferFunc) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, errors.WrapErrorf(err, errors.CommandCanceled, "transfer canceled")
+	}
+	session, err := pool.Get()
+	if err != nil {
+		return false, err
+	}
+	defer pool.Put(session)
+	skipped, err := fn(session, job)
+	if _, ok := err.(*errors.Err); err != nil && !ok {
+		err = errors.WrapErrorf(err, errors.NetworkError, "error transferring %s", job.file.RemotePath)
+	}
+	return skipped, err
+}
+
+func pushFile(ctx context.Context, session *SyncSession, job *transferJob) error {
+	f, err := os.Open(job.file.LocalPath)
+	if err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error opening %s", job.file.LocalPath)
+	}
+	defer f.Close()
+
+	w, err := session.OpenWrite(job.file.RemotePath, job.mode, job.mtime)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, &contextReader{ctx: ctx, r: f}); err != nil {
+		err = localFileError(err, "error reading %s", job.file.LocalPath)
+		w.(*syncSessionWriter).abort(err)
+		return err
+	}
+	return w.Close()
+}
+
+func pullFile(ctx context.Context, session *SyncSession, job *transferJob) error {
+	r, err := session.OpenRead(job.file.RemotePath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(job.file.LocalPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, job.mode)
+	if err != nil {
+		r.(*syncSessionReader).abort(err)
+		return errors.WrapErrorf(err, errors.LocalFileError, "error creating %s", job.file.LocalPath)
+	}
+	if _, err := io.Copy(f, &contextReader{ctx: ctx, r: r}); err != nil {
+		err = localFileError(err, "error writing %s", job.file.LocalPath)
+		r.(*syncSessionReader).abort(err)
+		f.Close()
+		return err
+	}
+	if err := r.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return errors.WrapErrorf(err, errors.LocalFileError, "error writing %s", job.file.LocalPath)
+	}
+	return errors.WrapErrorf(os.Chtimes(job.file.LocalPath, job.mtime, job.mtime),
+		errors.LocalFileError, "error setting modification time of %s", job.file.LocalPath)
+}
+
+// listLocalTransfers returns a job for every regular file under localDir.
+func listLocalTransfers(localDir, remoteDir string) ([]*transferJob, error) {
+	var jobs []*transferJob
+	err := filepath.WalkDir(localDir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, &transferJob{
+			file: TransferredFile{
+				LocalPath:  localPath,
+				RemotePath: path.Join(remoteDir, filepath.ToSlash(rel)),
+				Size:       info.Size(),
+			},
+			mode: info.Mode().Perm(),
+			// The sync protocol only has second precision.
+			mtime: time.Unix(info.ModTime().Unix(), 0).UTC(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.LocalFileError, "error listing %s", localDir)
+	}
+	return jobs, nil
+}
+
+// localFileError wraps err as a LocalFileError unless it's already an *errors.Err,
+// i.e. it came from the device side of a copy.
+func localFileError(err error, format string, args ...any) error {
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	return errors.WrapErrorf(err, errors.LocalFileError, format, args...)
+}
+
+// contextReader stops reading with a CommandCanceled error once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(buf []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, errors.WrapErrorf(err, errors.CommandCanceled, "transfer canceled")
+	}
+	return r.r.Read(buf)
+}
@@ -0,0 +1,196 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLocalTree(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(data), 0640))
+		require.NoError(t, os.Chtimes(p, someTime, someTime))
+	}
+	return dir
+}
+
+func TestPushDir(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("dir%d/file%d.txt", i%3, i)] = fmt.Sprintf("contents of %d", i)
+	}
+	local := writeLocalTree(t, files)
+	d := newFakeDevice()
+	device := newFakeDeviceClient(d)
+
+	var progress []TransferProgress
+	result, err := device.PushDir(context.Background(), local, "/sdcard/out", TransferOptions{
+		Concurrency: 3,
+		Progress: func(p TransferProgress) {
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+	d.wait()
+
+	require.Len(t, result.Files, 10)
+	for i := 1; i < len(result.Files); i++ {
+		assert.Less(t, result.Files[i-1].RemotePath, result.Files[i].RemotePath)
+	}
+	for name, data := range files {
+		f, ok := d.file("/sdcard/out/" + name)
+		require.True(t, ok, name)
+		assert.Equal(t, data, string(f.data))
+		assert.Equal(t, os.FileMode(0640), f.mode)
+		assert.Equal(t, someTime, f.mtime)
+	}
+
+	require.Len(t, progress, 10)
+	last := progress[len(progress)-1]
+	assert.Equal(t, 10, last.FilesDone)
+	assert.Equal(t, last.BytesTotal, last.BytesDone)
+	assert.Equal(t, result.Bytes, last.BytesTotal)
+	assert.LessOrEqual(t, d.dials, 3)
+}
+
+func TestPushDirAggregatesErrors(t *testing.T) {
+	local := writeLocalTree(t, map[string]string{"a": "a", "b": "b", "c": "c"})
+	d := newFakeDevice()
+	d.sendErrors["/out/c"] = "Read-only file system"
+	d.sendErrors["/out/a"] = "Permission denied"
+	device := newFakeDeviceClient(d)
+
+	result, err := device.PushDir(context.Background(), local, "/out", TransferOptions{Concurrency: 2})
+	assert.True(t, HasErrCode(err, AdbError))
+	assert.Contains(t, ErrorWithCauseChain(err), "2 of 3 files failed")
+	d.wait()
+
+	failed := result.Failed()
+	require.Len(t, failed, 2)
+	assert.Equal(t, "/out/a", failed[0].RemotePath)
+	assert.Equal(t, "/out/c", failed[1].RemotePath)
+	assert.NoError(t, result.Files[1].Err)
+	assert.Equal(t, int64(1), result.Bytes)
+
+	_, ok := d.file("/out/b")
+	assert.True(t, ok)
+	_, ok = d.file("/out/a")
+	assert.False(t, ok)
+}
+
+func TestPushDirCanceled(t *testing.T) {
+	local := writeLocalTree(t, map[string]string{"a": "a", "b": "b"})
+	d := newFakeDevice()
+	device := newFakeDeviceClient(d)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := device.PushDir(ctx, local, "/out", TransferOptions{Concurrency: 2})
+	assert.True(t, HasErrCode(err, CommandCanceled))
+	require.Len(t, result.Files, 2)
+	for _, f := range result.Files {
+		assert.True(t, HasErrCode(f.Err, CommandCanceled))
+	}
+	assert.Equal(t, 0, d.dials)
+}
+
+func TestSyncDirSkipsUpToDateFiles(t *testing.T) {
+	local := writeLocalTree(t, map[string]string{"a": "aaa", "sub/b": "bbb"})
+	d := newFakeDevice()
+	device := newFakeDeviceClient(d)
+
+	result, err := device.SyncDir(context.Background(), local, "/out", TransferOptions{Concurrency: 2})
+	require.NoError(t, err)
+	assert.False(t, result.Files[0].Skipped)
+	assert.Equal(t, int64(6), result.Bytes)
+
+	changed := filepath.Join(local, "sub", "b")
+	require.NoError(t, os.WriteFile(changed, []byte("changed"), 0640))
+	require.NoError(t, os.Chtimes(changed, someTime.Add(time.Hour), someTime.Add(time.Hour)))
+
+	result, err = device.SyncDir(context.Background(), local, "/out", TransferOptions{Concurrency: 2})
+	require.NoError(t, err)
+	assert.True(t, result.Files[0].Skipped)
+	assert.False(t, result.Files[1].Skipped)
+	assert.Equal(t, int64(7), result.Bytes)
+	d.wait()
+
+	f, _ := d.file("/out/sub/b")
+	assert.Equal(t, "changed", string(f.data))
+}
+
+func TestPullDir(t *testing.T) {
+	d := newFakeDevice()
+	d.addFile("/sdcard/in/a.txt", "hello", 0644)
+	d.addFile("/sdcard/in/sub/b.txt", "world", 0600)
+	d.addDir("/sdcard/in/empty")
+	device := newFakeDeviceClient(d)
+	local := t.TempDir()
+
+	result, err := device.PullDir(context.Background(), "/sdcard/in", local, TransferOptions{Concurrency: 4})
+	require.NoError(t, err)
+	d.wait()
+
+	require.Len(t, result.Files, 2)
+	assert.Equal(t, "/sdcard/in/a.txt", result.Files[0].RemotePath)
+	assert.Equal(t, int64(10), result.Bytes)
+
+	data, err := os.ReadFile(filepath.Join(local, "sub", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(data))
+
+	info, err := os.Stat(filepath.Join(local, "a.txt"))
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(someTime))
+
+	info, err = os.Stat(filepath.Join(local, "empty"))
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestPullDirMissing(t *testing.T) {
+	d := newFakeDevice()
+	device := newFakeDeviceClient(d)
+
+	_, err := device.PullDir(context.Background(), "/nope", t.TempDir(), TransferOptions{})
+	assert.True(t, HasErrCode(err, FileNoExistError))
+}
+
+func TestRunTransfersWrapsPlainErrors(t *testing.T) {
+	d := newFakeDevice()
+	device := newFakeDeviceClient(d)
+	jobs := []*transferJob{
+		{file: TransferredFile{RemotePath: "/out/a", Size: 1}},
+		{file: TransferredFile{RemotePath: "/out/b", Size: 2}},
+		{file: TransferredFile{RemotePath: "/out/c", Size: 4}},
+	}
+
+	var last TransferProgress
+	opts := TransferOptions{Concurrency: 1, Progress: func(p TransferProgress) { last = p }}
+	result, err := device.runTransfers(context.Background(), jobs, opts, func(session *SyncSession, job *transferJob) (bool, error) {
+		switch job.file.RemotePath {
+		case "/out/a":
+			return false, io.ErrUnexpectedEOF
+		case "/out/b":
+			return true, nil
+		}
+		return false, nil
+	})
+	assert.True(t, HasErrCode(err, NetworkError), "%v", err)
+	require.Len(t, result.Failed(), 1)
+	assert.True(t, HasErrCode(result.Failed()[0].Err, NetworkError))
+
+	assert.Equal(t, 3, last.FilesDone)
+	assert.Equal(t, int64(4), last.BytesDone)
+	assert.Equal(t, int64(7), last.BytesTotal)
+}
@@ -0,0 +1,192 @@
+package adb
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// Tombstone is the header and crashing thread's backtrace of a tombstone, the report
+// debuggerd writes when a native process crashes.
+type Tombstone struct {
+	Fingerprint string
+	ABI         string
+	// Timestamp is as printed by debuggerd, e.g. "2024-01-02 10:11:12.345678900+0000". Older
+	// devices don't print it.
+	Timestamp string
+
+	Pid int
+	Tid int
+	// ThreadName is the name of the crashing thread.
+	ThreadName string
+	// ProcessName is the process's command line, e.g. "/system/bin/surfaceflinger" or
+	// "com.example.app".
+	ProcessName string
+
+	Signal     int
+	SignalName string
+	// Code is the signal code, e.g. "1 (SEGV_MAPERR)".
+	Code string
+	// FaultAddress is the address that caused the fault, e.g. "0x0", or "--------" for
+	// signals that don't have one.
+	FaultAddress string
+
+	// AbortMessage is the message passed to abort(), e.g. by a failed CHECK, if any.
+	AbortMessage string
+
+	// Backtrace is the crashing thread's backtrace, innermost frame first.
+	Backtrace []StackFrame
+}
+
+// StackFrame is a frame of a tombstone backtrace, e.g.
+//
+//	#00 pc 000000000005d0f8  /apex/com.android.runtime/lib64/bionic/libc.so (abort+184) (BuildId: 0123abcd)
+type StackFrame struct {
+	Index int
+	// PC is relative to the start of the mapped file, or absolute for anonymous mappings.
+	PC uint64
+	// Path is the mapped file, e.g. a shared library, or a name such as "[vdso]".
+	Path string
+	// Offset is the offset of Path in the APK it's loaded from, or 0.
+	Offset uint64
+	// Function and FunctionOffset are the symbol PC is in, if known, e.g. "abort" and 184.
+	Function       string
+	FunctionOffset uint64
+	BuildID        string
+}
+
+var (
+	tombstonePidRegex    = regexp.MustCompile(`^pid: (\d+), tid: (\d+), name: (.*?)\s+>>> (.*) <<<$`)
+	tombstoneSignalRegex = regexp.MustCompile(`^signal (\d+) \((\w+)\), code (.+?), fault addr (\S+)`)
+	stackFrameRegex      = regexp.MustCompile(`^#(\d+) pc ([0-9a-fA-F]+)\s+(.*)$`)
+	stackFrameBuildID    = regexp.MustCompile(` \(BuildId: ([0-9a-fA-F]+)\)$`)
+	stackFrameOffset     = regexp.MustCompile(`^\(offset (0x[0-9a-fA-F]+)\)\s*`)
+)
+
+/*
+ParseTombstone parses the header and the crashing thread's backtrace of a tombstone's text,
+as read from /data/tombstones:
+
+	*** *** *** *** *** *** *** *** *** *** *** *** *** *** *** ***
+	Build fingerprint: 'google/sdk_gphone64_x86_64/emu64xa:14/UE1A.230829.036/10747587:userdebug/dev-keys'
+	ABI: 'x86_64'
+	Timestamp: 2024-01-02 10:11:12.345678900+0000
+	pid: 1234, tid: 1235, name: RenderThread  >>> com.example.app <<<
+	signal 6 (SIGABRT), code -1 (SI_QUEUE), fault addr --------
+	Abort message: 'Check failed: x != nullptr'
+	...
+	backtrace:
+	      #00 pc 000000000005d0f8  /apex/com.android.runtime/lib64/bionic/libc.so (abort+184) (BuildId: 0123abcd)
+
+The rest of the tombstone, registers, memory maps and other threads, is skipped.
+*/
+func ParseTombstone(text string) (*Tombstone, error) {
+	t := &Tombstone{}
+	foundPid, inBacktrace := false, false
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if inBacktrace {
+			if line == "" && len(t.Backtrace) > 0 {
+				break
+			}
+			if frame, ok := parseStackFrame(line); ok {
+				t.Backtrace = append(t.Backtrace, frame)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "Build fingerprint: "):
+			t.Fingerprint = unquoteTombstoneValue(strings.TrimPrefix(line, "Build fingerprint: "))
+		case strings.HasPrefix(line, "ABI: "):
+			t.ABI = unquoteTombstoneValue(strings.TrimPrefix(line, "ABI: "))
+		case strings.HasPrefix(line, "Timestamp: "):
+			t.Timestamp = strings.TrimPrefix(line, "Timestamp: ")
+		case strings.HasPrefix(line, "Abort message: "):
+			t.AbortMessage = unquoteTombstoneValue(strings.TrimPrefix(line, "Abort message: "))
+		case line == "backtrace:":
+			inBacktrace = true
+		}
+		if strings.HasPrefix(line, "--- --- ---") && foundPid {
+			// The other threads follow the crashing thread.
+			break
+		}
+
+		if m := tombstonePidRegex.FindStringSubmatch(line); m != nil && !foundPid {
+			foundPid = true
+			t.Pid, _ = strconv.Atoi(m[1])
+			t.Tid, _ = strconv.Atoi(m[2])
+			t.ThreadName, t.ProcessName = m[3], m[4]
+		}
+		if m := tombstoneSignalRegex.FindStringSubmatch(line); m != nil && t.SignalName == "" {
+			t.Signal, _ = strconv.Atoi(m[1])
+			t.SignalName, t.Code, t.FaultAddress = m[2], m[3], m[4]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "error reading tombstone")
+	}
+	if !foundPid {
+		return nil, errors.Errorf(errors.ParseError, "tombstone doesn't have a pid line")
+	}
+	return t, nil
+}
+
+// unquoteTombstoneValue removes the single quotes debuggerd puts around some values.
+func unquoteTombstoneValue(value string) string {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseStackFrame parses a backtrace line. After the path, the optional parts are, in order:
+//
+//	(offset 0x1000) (function+123) (BuildId: 0123abcd)
+func parseStackFrame(line string) (StackFrame, bool) {
+	m := stackFrameRegex.FindStringSubmatch(line)
+	if m == nil {
+		return StackFrame{}, false
+	}
+	var frame StackFrame
+	frame.Index, _ = strconv.Atoi(m[1])
+	pc, err := strconv.ParseUint(m[2], 16, 64)
+	if err != nil {
+		return StackFrame{}, false
+	}
+	frame.PC = pc
+
+	rest := m[3]
+	if b := stackFrameBuildID.FindStringSubmatchIndex(rest); b != nil {
+		frame.BuildID = rest[b[2]:b[3]]
+		rest = rest[:b[0]]
+	}
+
+	// The path ends at the first of the optional parts, so paths with spaces are kept whole.
+	path, extra := rest, ""
+	if i := strings.Index(rest, " ("); i >= 0 {
+		path, extra = rest[:i], strings.TrimSpace(rest[i:])
+	}
+	frame.Path = strings.TrimSpace(path)
+
+	if o := stackFrameOffset.FindStringSubmatch(extra); o != nil {
+		frame.Offset, _ = strconv.ParseUint(strings.TrimPrefix(o[1], "0x"), 16, 64)
+		extra = extra[len(o[0]):]
+	}
+	if len(extra) >= 2 && extra[0] == '(' && extra[len(extra)-1] == ')' {
+		function := extra[1 : len(extra)-1]
+		if i := strings.LastIndexByte(function, '+'); i >= 0 {
+			if offset, err := strconv.ParseUint(function[i+1:], 10, 64); err == nil {
+				function, frame.FunctionOffset = function[:i], offset
+			}
+		}
+		frame.Function = function
+	}
+	return frame, true
+}
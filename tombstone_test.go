@@ -0,0 +1,84 @@
+package adb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTombstone = `*** *** *** *** *** *** *** *** *** *** *** *** *** *** *** ***
+Build fingerprint: 'google/sdk_gphone64_x86_64/emu64xa:14/UE1A.230829.036/10747587:userdebug/dev-keys'
+Revision: '0'
+ABI: 'x86_64'
+Timestamp: 2024-01-02 10:11:12.345678900+0000
+Process uptime: 12s
+Cmdline: com.example.app
+pid: 1234, tid: 1250, name: RenderThread  >>> com.example.app <<<
+uid: 10123
+signal 6 (SIGABRT), code -1 (SI_QUEUE), fault addr --------
+Abort message: 'Check failed: surface != nullptr'
+    rax 0000000000000000  rbx 00000000000004d2  rcx 00007f8a3c5d0f8  rdx 0000000000000006
+
+backtrace:
+      #00 pc 000000000005d0f8  /apex/com.android.runtime/lib64/bionic/libc.so (abort+184) (BuildId: 0123abcd)
+      #01 pc 0000000000012340  /data/app/~~x==/com.example.app-y==/base.apk (offset 0x1000) (Renderer::draw(Surface*)+20)
+      #02 pc 00000000000a1b2c  [anon:dalvik-jit-code-cache]
+
+memory near rax:
+    0000000000000000 ----------------  ----------------  ................
+
+--- --- --- --- --- --- --- --- --- --- --- --- --- --- --- ---
+pid: 1234, tid: 1234, name: example.app  >>> com.example.app <<<
+backtrace:
+      #00 pc 0000000000099999  /apex/com.android.runtime/lib64/bionic/libc.so (__epoll_pwait+10)
+`
+
+func TestParseTombstone(t *testing.T) {
+	tombstone, err := ParseTombstone(testTombstone)
+	require.NoError(t, err)
+	assert.Equal(t, &Tombstone{
+		Fingerprint:  "google/sdk_gphone64_x86_64/emu64xa:14/UE1A.230829.036/10747587:userdebug/dev-keys",
+		ABI:          "x86_64",
+		Timestamp:    "2024-01-02 10:11:12.345678900+0000",
+		Pid:          1234,
+		Tid:          1250,
+		ThreadName:   "RenderThread",
+		ProcessName:  "com.example.app",
+		Signal:       6,
+		SignalName:   "SIGABRT",
+		Code:         "-1 (SI_QUEUE)",
+		FaultAddress: "--------",
+		AbortMessage: "Check failed: surface != nullptr",
+		Backtrace: []StackFrame{
+			{
+				Index: 0, PC: 0x5d0f8, Path: "/apex/com.android.runtime/lib64/bionic/libc.so",
+				Function: "abort", FunctionOffset: 184, BuildID: "0123abcd",
+			},
+			{
+				Index: 1, PC: 0x12340, Path: "/data/app/~~x==/com.example.app-y==/base.apk", Offset: 0x1000,
+				Function: "Renderer::draw(Surface*)", FunctionOffset: 20,
+			},
+			{Index: 2, PC: 0xa1b2c, Path: "[anon:dalvik-jit-code-cache]"},
+		},
+	}, tombstone)
+}
+
+func TestParseTombstoneSegfault(t *testing.T) {
+	tombstone, err := ParseTombstone(`pid: 42, tid: 42, name: crasher  >>> /system/bin/crasher <<<
+signal 11 (SIGSEGV), code 1 (SEGV_MAPERR), fault addr 0x0
+backtrace:
+    #00 pc 00001234  /system/bin/crasher
+`)
+	require.NoError(t, err)
+	assert.Equal(t, 11, tombstone.Signal)
+	assert.Equal(t, "SIGSEGV", tombstone.SignalName)
+	assert.Equal(t, "0x0", tombstone.FaultAddress)
+	assert.Empty(t, tombstone.AbortMessage)
+	assert.Equal(t, []StackFrame{{PC: 0x1234, Path: "/system/bin/crasher"}}, tombstone.Backtrace)
+}
+
+func TestParseTombstoneInvalid(t *testing.T) {
+	_, err := ParseTombstone("not a tombstone")
+	assert.True(t, HasErrCode(err, ParseError), "%v", err)
+}
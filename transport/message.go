@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// Commands of the adb transport protocol.
+const (
+	CommandSYNC uint32 = 0x434e5953
+	CommandCNXN uint32 = 0x4e584e43
+	CommandAUTH uint32 = 0x48545541
+	CommandOPEN uint32 = 0x4e45504f
+	CommandOKAY uint32 = 0x59414b4f
+	CommandCLSE uint32 = 0x45534c43
+	CommandWRTE uint32 = 0x45545257
+	CommandSTLS uint32 = 0x534c5453
+)
+
+// Arguments of AUTH messages.
+const (
+	AuthToken        uint32 = 1
+	AuthSignature    uint32 = 2
+	AuthRSAPublicKey uint32 = 3
+)
+
+const (
+	// Version is the protocol version sent in CNXN. Devices at this version or later don't
+	// check data checksums.
+	Version uint32 = 0x01000001
+	// STLSVersion is the version sent in STLS.
+	STLSVersion uint32 = 0x01000000
+	// MaxPayload is the largest payload this package accepts, and advertises in CNXN.
+	MaxPayload = 1024 * 1024
+
+	headerSize = 24
+)
+
+// Message is a message of the adb transport protocol.
+type Message struct {
+	Command uint32
+	Arg0    uint32
+	Arg1    uint32
+	Data    []byte
+}
+
+func (m *Message) String() string {
+	var name [4]byte
+	binary.LittleEndian.PutUint32(name[:], m.Command)
+	return fmt.Sprintf("%s(%#x, %#x, %d bytes)", name[:], m.Arg0, m.Arg1, len(m.Data))
+}
+
+/*
+WriteMessage writes m to w. The header is six little-endian uint32s:
+
+	command
+	arg0
+	arg1
+	data length
+	data checksum, the sum of its bytes
+	magic, command ^ 0xffffffff
+*/
+func WriteMessage(w io.Writer, m *Message) error {
+	if len(m.Data) > MaxPayload {
+		return errors.AssertionErrorf("message payload of %d bytes is too large", len(m.Data))
+	}
+	buf := make([]byte, headerSize, headerSize+len(m.Data))
+	binary.LittleEndian.PutUint32(buf[0:], m.Command)
+	binary.LittleEndian.PutUint32(buf[4:], m.Arg0)
+	binary.LittleEndian.PutUint32(buf[8:], m.Arg1)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(len(m.Data)))
+	binary.LittleEndian.PutUint32(buf[16:], checksum(m.Data))
+	binary.LittleEndian.PutUint32(buf[20:], m.Command^0xffffffff)
+	if _, err := w.Write(append(buf, m.Data...)); err != nil {
+		return errors.WrapErrorf(err, errors.NetworkError, "error writing %s", m)
+	}
+	return nil
+}
+
+// ReadMessage reads a message from r. Checksums aren't checked, since devices stopped
+// sending them at Version.
+func ReadMessage(r io.Reader) (*Message, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, readError(err, "message header")
+	}
+	m := &Message{
+		Command: binary.LittleEndian.Uint32(header[0:]),
+		Arg0:    binary.LittleEndian.Uint32(header[4:]),
+		Arg1:    binary.LittleEndian.Uint32(header[8:]),
+	}
+	if magic := binary.LittleEndian.Uint32(header[20:]); magic != m.Command^0xffffffff {
+		return nil, errors.Errorf(errors.ParseError, "invalid message magic %#x for command %#x", magic, m.Command)
+	}
+
+	length := binary.LittleEndian.Uint32(header[12:])
+	if length > MaxPayload {
+		return nil, errors.Errorf(errors.ParseError, "message payload of %d bytes is too large", length)
+	}
+	m.Data = make([]byte, length)
+	if _, err := io.ReadFull(r, m.Data); err != nil {
+		return nil, readError(err, "message payload")
+	}
+	return m, nil
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for _, b := range data {
+		sum += uint32(b)
+	}
+	return sum
+}
+
+func readError(err error, part string) error {
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.WrapErrorf(err, errors.ConnectionResetError, "connection closed reading %s", part)
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error reading %s", part)
+}
@@ -0,0 +1,294 @@
+/*
+Package transport connects directly to adbd, the daemon on the device, without going through
+an adb server. It implements the connection handshake of the adb transport protocol,
+including the TLS upgrade used by wireless debugging on Android 11 and later, and the framing
+of the messages exchanged after it.
+
+The handshake goes:
+
+	host:   CNXN(version, max payload, "host::features=...")
+	device: STLS(version, 0)                       wireless debugging
+	host:   STLS(version, 0)
+	        TLS 1.3 handshake, both sides presenting a certificate
+	device: CNXN(version, max payload, "device::...")
+
+Devices that don't use TLS send AUTH instead of STLS: the host signs the token in it with its
+adb key, and if the device doesn't know the key, can send the public key for the user to
+accept.
+
+The device authenticates the host by its key, which must have been authorized by the user or
+by pairing. The host accepts any device certificate unless Config.VerifyPeer is set, the same
+as the adb server.
+*/
+package transport
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/basiooo/goadb/adbkey"
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// DefaultFeatures are the features advertised to the device if Config.Features is nil.
+var DefaultFeatures = []string{"shell_v2", "cmd", "stat_v2", "ls_v2", "fixed_push_mkdir", "abb", "abb_exec"}
+
+// Config configures the handshake.
+type Config struct {
+	// Key is the host's adb key. Use adbkey.Load(adbkey.DefaultPath()) to connect with the same
+	// key as the adb server, which devices have already authorized.
+	Key *rsa.PrivateKey
+
+	// Features are advertised to the device in the CNXN banner.
+	Features []string
+
+	// VerifyPeer is called with the device's certificate when the connection is upgraded to
+	// TLS. Returning an error aborts the handshake with an AuthenticationFailed error. Use it
+	// to pin the certificate seen when the device was first connected to.
+	VerifyPeer func(cert *x509.Certificate) error
+
+	// SendPublicKey sends the host's public key if a device that doesn't use TLS rejects
+	// Key's signature, which makes the device ask the user to authorize it. The handshake
+	// then waits until the user answers or ctx is done. If false, an AuthenticationFailed
+	// error is returned instead.
+	SendPublicKey bool
+}
+
+// Conn is a connection to adbd that has completed the handshake.
+type Conn struct {
+	conn net.Conn
+
+	// Banner is the device's CNXN banner, e.g. "device::ro.product.name=x;features=shell_v2,cmd".
+	Banner string
+	// Version is the protocol version of the device.
+	Version uint32
+	// MaxPayload is the largest payload the device accepts.
+	MaxPayload uint32
+
+	// PeerCertificate is the device's certificate if the connection uses TLS, else nil.
+	PeerCertificate *x509.Certificate
+
+	writeMu sync.Mutex
+}
+
+// Dial connects to adbd at addr, e.g. the address of a device's wireless debugging service,
+// and performs the handshake.
+func Dial(ctx context.Context, addr string, config Config) (*Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, errors.WrapErrorf(ctxErr, errors.CommandCanceled, "handshake canceled")
+		}
+		return nil, errors.WrapErrorf(err, errors.ServerNotAvailable, "error connecting to %s", addr)
+	}
+
+	c, err := Handshake(ctx, conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Handshake performs the handshake over conn. If it fails, conn should be closed.
+func Handshake(ctx context.Context, conn net.Conn, config Config) (*Conn, error) {
+	if config.Key == nil {
+		return nil, errors.AssertionErrorf("no key")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapErrorf(err, errors.CommandCanceled, "handshake canceled")
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	c, err := handshake(ctx, conn, config)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, errors.WrapErrorf(ctxErr, errors.CommandCanceled, "handshake canceled")
+	}
+	return c, err
+}
+
+func handshake(ctx context.Context, conn net.Conn, config Config) (*Conn, error) {
+	features := config.Features
+	if features == nil {
+		features = DefaultFeatures
+	}
+	banner := "host::features=" + strings.Join(features, ",")
+	if err := WriteMessage(conn, &Message{Command: CommandCNXN, Arg0: Version, Arg1: MaxPayload, Data: []byte(banner)}); err != nil {
+		return nil, err
+	}
+
+	c := &Conn{conn: conn}
+	signed, sentKey := false, false
+	for {
+		m, err := ReadMessage(c.conn)
+		if err != nil && c.TLS() {
+			// With TLS 1.3, the client finishes its handshake before the device checks the
+			// client certificate, so an unauthorized key is only reported by the next read.
+			return nil, errors.WrapErrorf(err, errors.AuthenticationFailed, "device closed the connection, the key is probably not authorized")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch m.Command {
+		case CommandCNXN:
+			c.Banner = strings.TrimRight(string(m.Data), "\x00")
+			c.Version = m.Arg0
+			c.MaxPayload = m.Arg1
+			return c, nil
+
+		case CommandSTLS:
+			if c.PeerCertificate != nil {
+				return nil, errors.Errorf(errors.ParseError, "device sent STLS twice")
+			}
+			if err := WriteMessage(c.conn, &Message{Command: CommandSTLS, Arg0: STLSVersion}); err != nil {
+				return nil, err
+			}
+			if err := c.upgradeToTLS(ctx, config); err != nil {
+				return nil, err
+			}
+
+		case CommandAUTH:
+			if m.Arg0 != AuthToken {
+				return nil, errors.Errorf(errors.ParseError, "unexpected AUTH type %d", m.Arg0)
+			}
+			reply, err := authReply(config, m.Data, signed, sentKey)
+			if err != nil {
+				return nil, err
+			}
+			if reply.Arg0 == AuthSignature {
+				signed = true
+			} else {
+				sentKey = true
+			}
+			if err := WriteMessage(c.conn, reply); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, errors.Errorf(errors.ParseError, "unexpected message during handshake: %s", m)
+		}
+	}
+}
+
+/*
+authReply returns the reply to an AUTH token. The first token is signed with the key. The
+device sends another token if it doesn't accept the signature, in which case the public key
+is sent if config allows it.
+*/
+func authReply(config Config, token []byte, signed, sentKey bool) (*Message, error) {
+	if !signed {
+		// adbd verifies the signature as if the token were a SHA-1 digest.
+		signature, err := rsa.SignPKCS1v15(nil, config.Key, crypto.SHA1, token)
+		if err != nil {
+			return nil, errors.WrapErrorf(err, errors.ParseError, "error signing AUTH token of %d bytes", len(token))
+		}
+		return &Message{Command: CommandAUTH, Arg0: AuthSignature, Data: signature}, nil
+	}
+
+	if !config.SendPublicKey || sentKey {
+		return nil, errors.Errorf(errors.AuthenticationFailed, "device rejected the key")
+	}
+	publicKey, err := adbkey.EncodePublicKey(&config.Key.PublicKey, adbkey.DefaultName())
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Command: CommandAUTH, Arg0: AuthRSAPublicKey, Data: append([]byte(publicKey), 0)}, nil
+}
+
+func (c *Conn) upgradeToTLS(ctx context.Context, config Config) error {
+	cert, err := adbkey.Certificate(config.Key)
+	if err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(c.conn, &tls.Config{
+		// adbd lists the keys it has authorized as acceptable CAs, which a self-signed
+		// certificate never matches, so the certificate is always sent.
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &cert, nil
+		},
+		// The device's certificate is self-signed, so it's checked by VerifyPeer instead.
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return errors.WrapErrorf(err, errors.AuthenticationFailed, "TLS handshake failed, the key is probably not authorized")
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errors.Errorf(errors.AuthenticationFailed, "device didn't present a certificate")
+	}
+	if config.VerifyPeer != nil {
+		if err := config.VerifyPeer(certs[0]); err != nil {
+			return errors.WrapErrorf(err, errors.AuthenticationFailed, "device certificate rejected")
+		}
+	}
+	c.conn, c.PeerCertificate = tlsConn, certs[0]
+	return nil
+}
+
+// State returns the device's state from its banner, e.g. "device" or "recovery".
+func (c *Conn) State() string {
+	state, _, _ := strings.Cut(c.Banner, ":")
+	return state
+}
+
+// Properties returns the properties the device lists in its banner, e.g. ro.product.model.
+func (c *Conn) Properties() map[string]string {
+	props := make(map[string]string)
+	_, list, ok := strings.Cut(c.Banner, "::")
+	if !ok {
+		return props
+	}
+	for _, prop := range strings.Split(list, ";") {
+		if key, value, ok := strings.Cut(prop, "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// Features returns the features the device lists in its banner.
+func (c *Conn) Features() []string {
+	features := c.Properties()["features"]
+	if features == "" {
+		return nil
+	}
+	return strings.Split(features, ",")
+}
+
+// TLS returns true if the connection is encrypted.
+func (c *Conn) TLS() bool {
+	return c.PeerCertificate != nil
+}
+
+// ReadMessage reads the next message from the device. It must not be called concurrently.
+func (c *Conn) ReadMessage() (*Message, error) {
+	return ReadMessage(c.conn)
+}
+
+// WriteMessage sends m to the device. It can be called concurrently.
+func (c *Conn) WriteMessage(m *Message) error {
+	if len(m.Data) > int(c.MaxPayload) {
+		return errors.AssertionErrorf("message payload of %d bytes exceeds the device's maximum of %d", len(m.Data), c.MaxPayload)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return WriteMessage(c.conn, m)
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
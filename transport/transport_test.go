@@ -0,0 +1,322 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/basiooo/goadb/adbkey"
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testKeysOnce          sync.Once
+	hostKey, otherKey     *rsa.PrivateKey
+	errGeneratingTestKeys error
+)
+
+func testKeys(t *testing.T) (*rsa.PrivateKey, *rsa.PrivateKey) {
+	testKeysOnce.Do(func() {
+		if hostKey, errGeneratingTestKeys = adbkey.Generate(); errGeneratingTestKeys == nil {
+			otherKey, errGeneratingTestKeys = adbkey.Generate()
+		}
+	})
+	require.NoError(t, errGeneratingTestKeys)
+	return hostKey, otherKey
+}
+
+const testBanner = "device::ro.product.name=sdk;ro.product.model=Pixel;features=shell_v2,cmd"
+
+// fakeAdbd stands in for adbd on a device.
+type fakeAdbd struct {
+	authorized *rsa.PublicKey
+	cert       tls.Certificate
+	useTLS     bool
+	// acceptPublicKey makes the device accept a public key sent after a rejected signature,
+	// as if the user allowed it.
+	acceptPublicKey bool
+
+	hostBanner string
+	publicKey  string
+}
+
+func (d *fakeAdbd) serve(conn net.Conn) error {
+	m, err := ReadMessage(conn)
+	if err != nil {
+		return err
+	}
+	if m.Command != CommandCNXN {
+		return fmt.Errorf("expected CNXN, got %s", m)
+	}
+	d.hostBanner = string(m.Data)
+
+	var rw net.Conn = conn
+	if d.useTLS {
+		if rw, err = d.upgrade(conn); err != nil {
+			return err
+		}
+	} else if err := d.authenticate(conn); err != nil {
+		return err
+	}
+
+	if err := WriteMessage(rw, &Message{Command: CommandCNXN, Arg0: Version, Arg1: 4096, Data: []byte(testBanner)}); err != nil {
+		return err
+	}
+
+	// Echo a message, to check messages can be exchanged after the handshake.
+	m, err = ReadMessage(rw)
+	if err != nil {
+		return err
+	}
+	return WriteMessage(rw, m)
+}
+
+func (d *fakeAdbd) upgrade(conn net.Conn) (net.Conn, error) {
+	if err := WriteMessage(conn, &Message{Command: CommandSTLS, Arg0: STLSVersion}); err != nil {
+		return nil, err
+	}
+	m, err := ReadMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	if m.Command != CommandSTLS {
+		return nil, fmt.Errorf("expected STLS, got %s", m)
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		Certificates: []tls.Certificate{d.cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MinVersion:   tls.VersionTLS13,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if !d.authorized.Equal(cert.PublicKey) {
+				return fmt.Errorf("key not authorized")
+			}
+			return nil
+		},
+	})
+	return tlsConn, tlsConn.Handshake()
+}
+
+func (d *fakeAdbd) authenticate(conn net.Conn) error {
+	for attempt := 0; ; attempt++ {
+		token := make([]byte, 20)
+		rand.Read(token)
+		if err := WriteMessage(conn, &Message{Command: CommandAUTH, Arg0: AuthToken, Data: token}); err != nil {
+			return err
+		}
+
+		m, err := ReadMessage(conn)
+		if err != nil {
+			return err
+		}
+		switch {
+		case m.Command == CommandAUTH && m.Arg0 == AuthSignature:
+			if rsa.VerifyPKCS1v15(d.authorized, crypto.SHA1, token, m.Data) == nil {
+				return nil
+			}
+		case m.Command == CommandAUTH && m.Arg0 == AuthRSAPublicKey:
+			d.publicKey = string(m.Data)
+			if d.acceptPublicKey {
+				return nil
+			}
+			return fmt.Errorf("user rejected key")
+		default:
+			return fmt.Errorf("unexpected message %s", m)
+		}
+	}
+}
+
+// startAdbd serves one connection with d on a local port.
+func startAdbd(t *testing.T, d *fakeAdbd) (string, <-chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		errs <- d.serve(conn)
+	}()
+	return listener.Addr().String(), errs
+}
+
+func newTLSAdbd(t *testing.T, authorized *rsa.PublicKey) *fakeAdbd {
+	_, deviceKey := testKeys(t)
+	cert, err := adbkey.Certificate(deviceKey)
+	require.NoError(t, err)
+	return &fakeAdbd{authorized: authorized, cert: cert, useTLS: true}
+}
+
+func checkEcho(t *testing.T, conn *Conn) {
+	sent := &Message{Command: CommandOPEN, Arg0: 1, Data: []byte("shell:echo hi\x00")}
+	require.NoError(t, conn.WriteMessage(sent))
+	received, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, sent, received)
+}
+
+func TestDialTLS(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	d := newTLSAdbd(t, &hostKey.PublicKey)
+	addr, errs := startAdbd(t, d)
+
+	var verified *x509.Certificate
+	conn, err := Dial(context.Background(), addr, Config{
+		Key:      hostKey,
+		Features: []string{"shell_v2"},
+		VerifyPeer: func(cert *x509.Certificate) error {
+			verified = cert
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.True(t, conn.TLS())
+	assert.Equal(t, d.cert.Certificate[0], conn.PeerCertificate.Raw)
+	assert.Equal(t, conn.PeerCertificate, verified)
+	assert.Equal(t, testBanner, conn.Banner)
+	assert.Equal(t, "device", conn.State())
+	assert.Equal(t, "Pixel", conn.Properties()["ro.product.model"])
+	assert.Equal(t, []string{"shell_v2", "cmd"}, conn.Features())
+	assert.Equal(t, uint32(4096), conn.MaxPayload)
+
+	checkEcho(t, conn)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, "host::features=shell_v2", d.hostBanner)
+}
+
+func TestDialTLSUnauthorizedKey(t *testing.T) {
+	hostKey, otherKey := testKeys(t)
+	addr, _ := startAdbd(t, newTLSAdbd(t, &otherKey.PublicKey))
+
+	_, err := Dial(context.Background(), addr, Config{Key: hostKey})
+	assert.True(t, errors.HasErrCode(err, errors.AuthenticationFailed), "%v", err)
+}
+
+func TestDialTLSPeerRejected(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	addr, _ := startAdbd(t, newTLSAdbd(t, &hostKey.PublicKey))
+
+	_, err := Dial(context.Background(), addr, Config{
+		Key: hostKey,
+		VerifyPeer: func(cert *x509.Certificate) error {
+			return fmt.Errorf("certificate changed")
+		},
+	})
+	assert.True(t, errors.HasErrCode(err, errors.AuthenticationFailed), "%v", err)
+	assert.Contains(t, errors.ErrorWithCauseChain(err), "certificate changed")
+}
+
+func TestDialAuthSignature(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	addr, errs := startAdbd(t, &fakeAdbd{authorized: &hostKey.PublicKey})
+
+	conn, err := Dial(context.Background(), addr, Config{Key: hostKey})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.False(t, conn.TLS())
+	assert.Equal(t, testBanner, conn.Banner)
+	checkEcho(t, conn)
+	assert.NoError(t, <-errs)
+}
+
+func TestDialAuthRejected(t *testing.T) {
+	hostKey, otherKey := testKeys(t)
+	addr, _ := startAdbd(t, &fakeAdbd{authorized: &otherKey.PublicKey})
+
+	_, err := Dial(context.Background(), addr, Config{Key: hostKey})
+	assert.True(t, errors.HasErrCode(err, errors.AuthenticationFailed), "%v", err)
+}
+
+func TestDialAuthSendPublicKey(t *testing.T) {
+	hostKey, otherKey := testKeys(t)
+	d := &fakeAdbd{authorized: &otherKey.PublicKey, acceptPublicKey: true}
+	addr, errs := startAdbd(t, d)
+
+	conn, err := Dial(context.Background(), addr, Config{Key: hostKey, SendPublicKey: true})
+	require.NoError(t, err)
+	defer conn.Close()
+	checkEcho(t, conn)
+	assert.NoError(t, <-errs)
+
+	publicKey, err := adbkey.EncodePublicKey(&hostKey.PublicKey, adbkey.DefaultName())
+	require.NoError(t, err)
+	assert.Equal(t, publicKey+"\x00", d.publicKey)
+}
+
+func TestHandshakeCanceled(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	host, device := net.Pipe()
+	defer device.Close()
+	go func() {
+		// Read the CNXN, but never reply, like a device waiting for the user to accept the key.
+		ReadMessage(device)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := Handshake(ctx, host, Config{Key: hostKey})
+	assert.True(t, errors.HasErrCode(err, errors.CommandCanceled), "%v", err)
+}
+
+func TestHandshakeUnexpectedMessage(t *testing.T) {
+	hostKey, _ := testKeys(t)
+	host, device := net.Pipe()
+	defer device.Close()
+	go func() {
+		ReadMessage(device)
+		WriteMessage(device, &Message{Command: CommandOKAY})
+	}()
+
+	_, err := Handshake(context.Background(), host, Config{Key: hostKey})
+	assert.True(t, errors.HasErrCode(err, errors.ParseError), "%v", err)
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	m := &Message{Command: CommandWRTE, Arg0: 1, Arg1: 2, Data: []byte{1, 2, 3}}
+	require.NoError(t, WriteMessage(&buf, m))
+	assert.Equal(t, []byte{
+		'W', 'R', 'T', 'E', 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 6, 0, 0, 0, 0xa8, 0xad, 0xab, 0xba, 1, 2, 3,
+	}, buf.Bytes())
+
+	read, err := ReadMessage(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, m, read)
+	assert.Equal(t, "WRTE(0x1, 0x2, 3 bytes)", read.String())
+}
+
+func TestReadMessageInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteMessage(&buf, &Message{Command: CommandOKAY}))
+	data := buf.Bytes()
+	data[20] ^= 1
+	_, err := ReadMessage(bytes.NewReader(data))
+	assert.True(t, errors.HasErrCode(err, errors.ParseError), "%v", err)
+
+	_, err = ReadMessage(strings.NewReader("CNXN"))
+	assert.True(t, errors.HasErrCode(err, errors.ConnectionResetError), "%v", err)
+}
@@ -13,10 +13,6 @@ var (
 	whitespaceRegex = regexp.MustCompile(`^\s*$`)
 )
 
-func containsWhitespace(str string) bool {
-	return strings.ContainsAny(str, " \t\v")
-}
-
 func isBlank(str string) bool {
 	return whitespaceRegex.MatchString(str)
 }
@@ -38,3 +34,26 @@ func wrapClientError(err error, client any, operation string, args ...any) error
 		Details: client,
 	}
 }
+
+// shellQuote quotes arg for the device's POSIX shell, so that it reaches the command
+// verbatim. Arguments made only of characters the shell doesn't treat specially are
+// returned unchanged. Everything else is wrapped in single quotes, inside of which the
+// shell interprets nothing. An embedded single quote ends the quoted string, is escaped
+// with a backslash, and starts a new quoted string.
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if strings.IndexFunc(arg, isShellSpecial) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func isShellSpecial(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("@%+=:,./-_", r)
+}
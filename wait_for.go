@@ -0,0 +1,129 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// waitForStates maps the states that can be waited for to their names in wait-for requests.
+var waitForStates = map[DeviceState]string{
+	StateOnline:       "device",
+	StateRecovery:     "recovery",
+	StateRescue:       "rescue",
+	StateSideload:     "sideload",
+	StateBootloader:   "bootloader",
+	StateDisconnected: "disconnect",
+}
+
+/*
+WaitFor blocks until a device matching descriptor is in state, which must be one of
+StateOnline, StateRecovery, StateRescue, StateSideload, StateBootloader or
+StateDisconnected.
+
+Returns a CommandCanceled error if ctx is done first.
+
+Corresponds to the commands:
+
+	adb wait-for-device
+	adb -s <serial> wait-for-recovery
+	adb wait-for-usb-disconnect
+*/
+func (c *Adb) WaitFor(ctx context.Context, descriptor DeviceDescriptor, state DeviceState) error {
+	return wrapClientError(waitFor(ctx, c.Server, descriptor, state), c, "WaitFor(%s, %s)", descriptor, state)
+}
+
+// WaitFor blocks until the device is in state. See Adb.WaitFor.
+func (c *Device) WaitFor(ctx context.Context, state DeviceState) error {
+	return wrapClientError(waitFor(ctx, c.server, c.descriptor, state), c, "WaitFor(%s)", state)
+}
+
+func waitFor(ctx context.Context, server server, descriptor DeviceDescriptor, state DeviceState) error {
+	stateName, ok := waitForStates[state]
+	if !ok {
+		return errors.AssertionErrorf("can't wait for state %s", state)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.WrapErrorf(err, errors.CommandCanceled, "wait canceled")
+	}
+
+	conn, err := server.Dial()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("[Adb] error closing connection: %s", err)
+		}
+	}()
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	// The server replies once when it accepts the request, and again once the device is in state.
+	req := fmt.Sprintf("%s:wait-for-%s-%s", descriptor.getHostPrefix(), descriptor.getWaitForTransport(), stateName)
+	if err = conn.SendMessage([]byte(req)); err == nil {
+		if _, err = conn.ReadStatus(req); err == nil {
+			_, err = conn.ReadStatus(req)
+		}
+	}
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return errors.WrapErrorf(ctxErr, errors.CommandCanceled, "wait canceled")
+	}
+	return err
+}
+
+/*
+WaitForBootComplete blocks until the device is online and has finished booting: the
+sys.boot_completed property is set, the package manager is running, and the keyguard isn't
+showing. The last check means a device with a secure lock screen has to be unlocked before
+this returns.
+
+Returns a CommandCanceled error if ctx is done first.
+*/
+func (c *Device) WaitForBootComplete(ctx context.Context) error {
+	if err := c.WaitFor(ctx, StateOnline); err != nil {
+		return err
+	}
+
+	err := c.WatchProperty(ctx, PropBootComplete, func(value string) bool {
+		return value == "1"
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.pollUntil(ctx, "WaitForBootComplete", c.packageManagerReady); err != nil {
+		return err
+	}
+	return c.pollUntil(ctx, "WaitForBootComplete", c.keyguardDismissed)
+}
+
+// packageManagerReady returns true once the package manager can answer queries.
+func (c *Device) packageManagerReady() (bool, error) {
+	result, err := c.runShellCommand("pm path android")
+	if err != nil {
+		return false, err
+	}
+	return result.ExitCode == 0 && strings.HasPrefix(string(result.Stdout), "package:"), nil
+}
+
+// keyguardDismissed returns true if the keyguard isn't showing. Android 10 and later report
+// it in the KeyguardServiceDelegate section of the window policy dump, older versions as
+// mShowingLockscreen.
+func (c *Device) keyguardDismissed() (bool, error) {
+	result, err := c.runShellCommand("dumpsys window policy")
+	if err != nil {
+		return false, err
+	}
+	if result.ExitCode != 0 {
+		return false, nil
+	}
+	output := string(result.Stdout)
+	return !strings.Contains(output, "showingAndNotOccluded=true") &&
+		!strings.Contains(output, "mShowingLockscreen=true"), nil
+}
@@ -0,0 +1,102 @@
+package adb
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitFor(t *testing.T) {
+	d := newFakeDevice()
+	d.services["host-usb:wait-for-usb-recovery"] = func(conn net.Conn, req string) {
+		writeFakeOkay(conn)
+	}
+	d.services["host-serial:serial:wait-for-any-device"] = func(conn net.Conn, req string) {
+		writeFakeOkay(conn)
+	}
+	client := &Adb{d}
+
+	require.NoError(t, client.WaitFor(context.Background(), AnyUsbDevice(), StateRecovery))
+	require.NoError(t, newFakeDeviceClient(d).WaitFor(context.Background(), StateOnline))
+	d.wait()
+	assert.Equal(t, []string{"host-usb:wait-for-usb-recovery", "host-serial:serial:wait-for-any-device"}, d.requests)
+}
+
+func TestWaitForFailure(t *testing.T) {
+	d := newFakeDevice()
+	d.services["host-local:wait-for-local-sideload"] = func(conn net.Conn, req string) {
+		writeFakeFail(conn, "device offline")
+	}
+	client := &Adb{d}
+
+	err := client.WaitFor(context.Background(), AnyLocalDevice(), StateSideload)
+	assert.True(t, HasErrCode(err, AdbError))
+	assert.Contains(t, ErrorWithCauseChain(err), "device offline")
+
+	err = client.WaitFor(context.Background(), AnyDevice(), StateUnauthorized)
+	assert.True(t, HasErrCode(err, AssertionError))
+	d.wait()
+}
+
+func TestWaitForCanceled(t *testing.T) {
+	d := newFakeDevice()
+	ctx, cancel := context.WithCancel(context.Background())
+	d.services["host:wait-for-any-disconnect"] = func(conn net.Conn, req string) {
+		cancel()
+		conn.Read(make([]byte, 1))
+	}
+	client := &Adb{d}
+
+	err := client.WaitFor(ctx, AnyDevice(), StateDisconnected)
+	assert.True(t, HasErrCode(err, CommandCanceled))
+	d.wait()
+}
+
+func TestWaitForBootComplete(t *testing.T) {
+	defer func(interval time.Duration) { devicePollInterval = interval }(devicePollInterval)
+	devicePollInterval = time.Millisecond
+
+	var mu sync.Mutex
+	responses := map[string][]string{
+		"getprop sys.boot_completed": {"\n", "1\n"},
+		"pm path android":            {"Error: Could not access the Package Manager.\n", "package:/system/framework/framework-res.apk\n"},
+		"dumpsys window policy":      {"    KeyguardServiceDelegate\n      showing=true\n      showingAndNotOccluded=true\n", "    KeyguardServiceDelegate\n      showing=false\n      showingAndNotOccluded=false\n"},
+	}
+	var cmdlines []string
+	d, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		cmdlines = append(cmdlines, cmdline)
+		values := responses[cmdline]
+		value := values[0]
+		if len(values) > 1 {
+			responses[cmdline] = values[1:]
+		}
+		return value, "", 0
+	})
+	d.services["host-serial:serial:wait-for-any-device"] = func(conn net.Conn, req string) {
+		writeFakeOkay(conn)
+	}
+
+	require.NoError(t, device.WaitForBootComplete(context.Background()))
+	d.wait()
+	assert.Equal(t, []string{
+		"getprop sys.boot_completed", "getprop sys.boot_completed",
+		"pm path android", "pm path android",
+		"dumpsys window policy", "dumpsys window policy",
+	}, cmdlines)
+}
+
+func TestKeyguardDismissedOldDevice(t *testing.T) {
+	_, device := newFakeShellDevice([]string{"shell_v2"}, func(cmdline string) (string, string, int) {
+		return "    mShowingLockscreen=true mShowingDream=false mDreamingLockscreen=true\n", "", 0
+	})
+	dismissed, err := device.keyguardDismissed()
+	require.NoError(t, err)
+	assert.False(t, dismissed)
+}
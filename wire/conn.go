@@ -1,11 +1,17 @@
 package wire
 
-import "github.com/basiooo/goadb/internal/errors"
+import (
+	"io"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
 
 const (
-	// The official implementation of adb imposes an undocumented 255-byte limit
-	// on messages.
-	MaxMessageLength = 255
+	// MaxMessageLength is the longest request adb accepts. Device services, e.g. shell:, are
+	// opened with a packet of at most 4096 bytes on devices with the original protocol,
+	// holding the request and a terminating NUL. Responses, e.g. the device feature list,
+	// can be longer.
+	MaxMessageLength = 4095
 )
 
 /*
@@ -63,6 +69,37 @@ func (conn *Conn) RoundTripSingleResponse(req []byte) (resp []byte, err error) {
 	return conn.ReadMessage()
 }
 
+// Read reads raw bytes from the connection, for services whose output isn't framed, e.g.
+// shell: and exec:. Returns io.EOF when the service closes the stream. The Scanner must
+// implement io.Reader, as the one returned by NewScanner does.
+func (conn *Conn) Read(p []byte) (int, error) {
+	r, ok := conn.Scanner.(io.Reader)
+	if !ok {
+		return 0, errors.AssertionErrorf("%T doesn't support reading raw bytes", conn.Scanner)
+	}
+	return r.Read(p)
+}
+
+// Write writes raw bytes to the connection, e.g. the stdin of an exec: service. The Sender
+// must implement io.Writer, as the one returned by NewSender does.
+func (conn *Conn) Write(p []byte) (int, error) {
+	w, ok := conn.Sender.(io.Writer)
+	if !ok {
+		return 0, errors.AssertionErrorf("%T doesn't support writing raw bytes", conn.Sender)
+	}
+	return w.Write(p)
+}
+
+// CloseWrite shuts down the sending side of the connection, if it supports it, so the
+// other end reads EOF. Reading is unaffected.
+func (conn *Conn) CloseWrite() error {
+	cw, ok := conn.Sender.(interface{ CloseWrite() error })
+	if !ok {
+		return errors.AssertionErrorf("%T doesn't support closing only the write side", conn.Sender)
+	}
+	return cw.CloseWrite()
+}
+
 func (conn *Conn) Close() error {
 	errs := struct {
 		SenderErr  error
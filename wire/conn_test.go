@@ -133,6 +133,17 @@ func TestNewConn(t *testing.T) {
 	assert.Equal(t, sender, conn.Sender)
 }
 
+func TestConn_RawUnsupported(t *testing.T) {
+	conn := NewConn(&mockConnScanner{}, &mockConnSender{})
+
+	_, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, adbErrors.AssertionError, err.(*adbErrors.Err).Code)
+	_, err = conn.Write([]byte("x"))
+	assert.Equal(t, adbErrors.AssertionError, err.(*adbErrors.Err).Code)
+	err = conn.CloseWrite()
+	assert.Equal(t, adbErrors.AssertionError, err.(*adbErrors.Err).Code)
+}
+
 func TestConn_NewSyncConn(t *testing.T) {
 	// Create mocks
 	syncScanner := &mockConnSyncScanner{}
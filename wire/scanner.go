@@ -76,6 +76,14 @@ func (s *realScanner) ReadUntilEof() ([]byte, error) {
 	return data, nil
 }
 
+func (s *realScanner) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = errors.WrapErrorf(err, errors.NetworkError, "error reading from connection")
+	}
+	return n, err
+}
+
 func (s *realScanner) NewSyncScanner() SyncScanner {
 	return NewSyncScanner(s.reader)
 }
@@ -162,11 +170,6 @@ func readHexLength(r io.Reader) (int, error) {
 		return 0, errors.WrapErrorf(err, errors.NetworkError, "could not parse hex length %v", lengthHex)
 	}
 
-	// Clip the length to 255, as per the Google implementation.
-	if length > MaxMessageLength {
-		length = MaxMessageLength
-	}
-
 	return int(length), nil
 }
 
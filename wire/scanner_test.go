@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/basiooo/goadb/internal/errors"
@@ -50,6 +51,24 @@ func TestReadFailureStatus(t *testing.T) {
 	assertEof(t, s)
 }
 
+func TestReadMessageLongerThanMaxRequest(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	s := newEofReader("012c" + long)
+	msg, err := readMessage(s, readHexLength)
+	assert.NoError(t, err)
+	assert.Equal(t, long, string(msg))
+	assertEof(t, s)
+}
+
+func TestReadRaw(t *testing.T) {
+	s := NewScanner(newEofReader("OKAY\r\n\x00"))
+	_, err := s.ReadStatus("")
+	assert.NoError(t, err)
+	data, err := io.ReadAll(NewConn(s, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "\r\n\x00", string(data))
+}
+
 func TestReadMessage(t *testing.T) {
 	s := newEofReader("0005hello")
 	msg, err := readMessage(s, readHexLength)
@@ -30,13 +30,32 @@ func SendMessageString(s Sender, msg string) error {
 
 func (s *realSender) SendMessage(msg []byte) error {
 	if len(msg) > MaxMessageLength {
-		return errors.AssertionErrorf("message length exceeds maximum: %d", len(msg))
+		return errors.AssertionErrorf("request of %d bytes exceeds the maximum of %d", len(msg), MaxMessageLength)
 	}
 
 	lengthAndMsg := fmt.Sprintf("%04x%s", len(msg), msg)
 	return writeFully(s.writer, []byte(lengthAndMsg))
 }
 
+func (s *realSender) Write(p []byte) (int, error) {
+	if err := writeFully(s.writer, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *realSender) CloseWrite() error {
+	cw, ok := s.writer.(interface{ CloseWrite() error })
+	if !ok {
+		return errors.AssertionErrorf("connection doesn't support closing only the write side")
+	}
+	err := cw.CloseWrite()
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error closing sender for writing")
+}
+
 func (s *realSender) NewSyncSender() SyncSender {
 	return NewSyncSender(s.writer)
 }
@@ -2,8 +2,11 @@ package wire
 
 import (
 	"bytes"
+	"io"
+	"strings"
 	"testing"
 
+	"github.com/basiooo/goadb/internal/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,6 +24,49 @@ func TestWriteEmptyMessage(t *testing.T) {
 	assert.Equal(t, "0000", b.String())
 }
 
+func TestWriteMessageTooLong(t *testing.T) {
+	s, b := NewTestSender()
+	assert.NoError(t, SendMessageString(s, strings.Repeat("x", MaxMessageLength)))
+	assert.Equal(t, 4+MaxMessageLength, b.Len())
+
+	err := SendMessageString(s, strings.Repeat("x", MaxMessageLength+1))
+	assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+}
+
+func TestWriteRaw(t *testing.T) {
+	s, b := NewTestSender()
+	n, err := NewConn(nil, s).Write([]byte("\r\n\x00"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "\r\n\x00", b.String())
+}
+
+func TestCloseWrite(t *testing.T) {
+	s, _ := NewTestSender()
+	err := NewConn(nil, s).CloseWrite()
+	assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+
+	w := &halfClosingWriter{}
+	s = NewSender(MultiCloseable(w))
+	assert.NoError(t, NewConn(nil, s).CloseWrite())
+	assert.True(t, w.writeClosed)
+}
+
+// halfClosingWriter is a TestWriter that implements CloseWrite, like *net.TCPConn.
+type halfClosingWriter struct {
+	TestWriter
+	writeClosed bool
+}
+
+func (w *halfClosingWriter) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (w *halfClosingWriter) CloseWrite() error {
+	w.writeClosed = true
+	return nil
+}
+
 func NewTestSender() (Sender, *TestWriter) {
 	w := new(TestWriter)
 	return NewSender(w), w
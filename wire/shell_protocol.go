@@ -0,0 +1,61 @@
+package wire
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/basiooo/goadb/internal/errors"
+)
+
+// ShellPacketID identifies the stream a shell protocol packet belongs to.
+type ShellPacketID byte
+
+/*
+Packet ids used by version 2 of the shell protocol, which adbd speaks for services
+requested as "shell,v2,...:" when the device has the "shell_v2" feature.
+
+Each packet is a one-byte id, a little-endian 32-bit length and that many bytes of data.
+Unlike the v1 shell service, stdout and stderr are kept apart and the exit status of the
+command is reported in a final ShellExit packet with a single byte of data.
+*/
+const (
+	ShellStdin            ShellPacketID = 0
+	ShellStdout           ShellPacketID = 1
+	ShellStderr           ShellPacketID = 2
+	ShellExit             ShellPacketID = 3
+	ShellCloseStdin       ShellPacketID = 4
+	ShellWindowSizeChange ShellPacketID = 5
+)
+
+// ReadShellPacket reads a single shell protocol packet from r.
+// Returns io.EOF if r is at EOF before the packet starts.
+func ReadShellPacket(r io.Reader) (ShellPacketID, []byte, error) {
+	header := make([]byte, 5)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF {
+		return 0, nil, io.EOF
+	} else if err == io.ErrUnexpectedEOF {
+		return 0, nil, errIncompleteMessage("shell packet header", n, len(header))
+	} else if err != nil {
+		return 0, nil, errors.WrapErrorf(err, errors.NetworkError, "error reading shell packet header")
+	}
+
+	length := binary.LittleEndian.Uint32(header[1:])
+	data := make([]byte, length)
+	n, err = io.ReadFull(r, data)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, nil, errIncompleteMessage("shell packet data", n, int(length))
+	} else if err != nil {
+		return 0, nil, errors.WrapErrorf(err, errors.NetworkError, "error reading shell packet data")
+	}
+	return ShellPacketID(header[0]), data, nil
+}
+
+// WriteShellPacket writes data to w as a single shell protocol packet with the given id.
+func WriteShellPacket(w io.Writer, id ShellPacketID, data []byte) error {
+	packet := make([]byte, 5+len(data))
+	packet[0] = byte(id)
+	binary.LittleEndian.PutUint32(packet[1:], uint32(len(data)))
+	copy(packet[5:], data)
+	return writeFully(w, packet)
+}
@@ -0,0 +1,49 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/basiooo/goadb/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteShellPacket(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteShellPacket(&buf, ShellStdout, []byte("hi")))
+	assert.Equal(t, []byte{1, 2, 0, 0, 0, 'h', 'i'}, buf.Bytes())
+}
+
+func TestReadShellPackets(t *testing.T) {
+	var buf bytes.Buffer
+	WriteShellPacket(&buf, ShellStdout, []byte("out"))
+	WriteShellPacket(&buf, ShellStderr, []byte("err"))
+	WriteShellPacket(&buf, ShellExit, []byte{3})
+
+	id, data, err := ReadShellPacket(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ShellStdout, id)
+	assert.Equal(t, "out", string(data))
+
+	id, data, err = ReadShellPacket(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ShellStderr, id)
+	assert.Equal(t, "err", string(data))
+
+	id, data, err = ReadShellPacket(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ShellExit, id)
+	assert.Equal(t, []byte{3}, data)
+
+	_, _, err = ReadShellPacket(&buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadShellPacketIncomplete(t *testing.T) {
+	_, _, err := ReadShellPacket(bytes.NewReader([]byte{1, 5, 0}))
+	assert.True(t, errors.HasErrCode(err, errors.ConnectionResetError))
+
+	_, _, err = ReadShellPacket(bytes.NewReader([]byte{1, 5, 0, 0, 0, 'a'}))
+	assert.True(t, errors.HasErrCode(err, errors.ConnectionResetError))
+}
@@ -83,6 +83,7 @@ func writeFully(w io.Writer, data []byte) error {
 }
 
 // MultiCloseable wraps c in a ReadWriteCloser that can be safely closed multiple times.
+// Its CloseWrite method calls c's CloseWrite, if c has one (as *net.TCPConn does).
 func MultiCloseable(c io.ReadWriteCloser) io.ReadWriteCloser {
 	return &multiCloseable{ReadWriteCloser: c}
 }
@@ -99,3 +100,10 @@ func (c *multiCloseable) Close() error {
 	})
 	return c.err
 }
+
+func (c *multiCloseable) CloseWrite() error {
+	if cw, ok := c.ReadWriteCloser.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return errors.AssertionErrorf("%T doesn't support closing only the write side", c.ReadWriteCloser)
+}